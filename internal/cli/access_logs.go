@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/server"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	accessLogsTail    string
+	accessLogsFollow  bool
+	accessLogsStatus  string
+	accessLogsPath    string
+	accessLogsSince   string
+	accessLogsSummary bool
+	accessLogsKey     string
+)
+
+var accessLogsCmd = &cobra.Command{
+	Use:   "access-logs",
+	Short: "Stream HTTP access logs from the proxy",
+	Long: `Access-logs streams the requests Caddy proxied to the application, with
+status code, method, path and latency. Logs are read from /var/log/caddy/<app>.log
+on the server and can be filtered by status, path and time.
+
+Examples:
+  mushak access-logs                       # Follow the latest requests
+  mushak access-logs --status 5xx          # Only server errors
+  mushak access-logs --status 404,5xx --path /api
+  mushak access-logs --summary --since 1h  # Request rate and error percentage`,
+	RunE: withTimer(runAccessLogs),
+}
+
+func init() {
+	rootCmd.AddCommand(accessLogsCmd)
+
+	accessLogsCmd.Flags().StringVarP(&accessLogsTail, "tail", "n", "100", "Number of lines to show from the end of the log")
+	accessLogsCmd.Flags().BoolVarP(&accessLogsFollow, "follow", "f", true, "Follow log output")
+	accessLogsCmd.Flags().StringVarP(&accessLogsStatus, "status", "s", "", "Filter by status code or class (e.g. 404, 5xx, 4xx,5xx)")
+	accessLogsCmd.Flags().StringVarP(&accessLogsPath, "path", "p", "", "Filter by request path prefix")
+	accessLogsCmd.Flags().StringVar(&accessLogsSince, "since", "", "Only show requests newer than this duration (e.g. 15m, 2h)")
+	accessLogsCmd.Flags().BoolVar(&accessLogsSummary, "summary", false, "Show request rate, status breakdown and error percentage instead of requests")
+	accessLogsCmd.Flags().StringVar(&accessLogsKey, "key", "", "SSH key path (default: ~/.ssh/id_rsa)")
+}
+
+func runAccessLogs(cmd *cobra.Command, args []string) error {
+	filter, err := buildAccessLogFilter(accessLogsStatus, accessLogsPath, accessLogsSince, time.Now())
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	fmt.Printf("→ Connecting to %s@%s...\n", cfg.User, cfg.Host)
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host:    cfg.Host,
+		User:    cfg.User,
+		KeyPath: accessLogsKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	logPath := server.AccessLogPath(cfg.AppName)
+	// The log is owned by the caddy user, so check with sudo
+	if _, err := executor.RunSudo(fmt.Sprintf("test -f %s", logPath)); err != nil {
+		return fmt.Errorf("no access log found at %s. Deploy once to enable access logging", logPath)
+	}
+
+	// Time based filters and summaries need the whole file, not just the tail
+	tail := accessLogsTail
+	if accessLogsSince != "" || accessLogsSummary {
+		tail = "+1"
+	}
+
+	if accessLogsSummary {
+		output, err := executor.RunSudo(fmt.Sprintf("tail -n %s %s", tail, logPath))
+		if err != nil {
+			return fmt.Errorf("failed to read access log: %w", err)
+		}
+
+		var entries []*server.AccessLogEntry
+		for _, line := range strings.Split(output, "\n") {
+			if entry, err := server.ParseAccessLogLine(line); err == nil && filter.Matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+
+		printAccessLogSummary(server.SummarizeAccessLog(entries))
+		return nil
+	}
+
+	tailCmd := fmt.Sprintf("sudo tail -n %s", tail)
+	if accessLogsFollow {
+		tailCmd += " -f"
+	}
+	tailCmd += " " + logPath
+
+	fmt.Printf("→ Streaming access logs from %s...\n", logPath)
+	fmt.Println()
+
+	// Filter and format lines locally as they arrive
+	reader, writer := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			entry, err := server.ParseAccessLogLine(scanner.Text())
+			if err != nil || !filter.Matches(entry) {
+				continue
+			}
+			fmt.Println(formatAccessLogEntry(entry))
+		}
+	}()
+
+	err = executor.StreamRun(tailCmd, writer, os.Stderr)
+	writer.Close()
+	<-done
+
+	if err != nil {
+		return fmt.Errorf("log streaming ended: %w", err)
+	}
+
+	return nil
+}
+
+// buildAccessLogFilter converts command line flags into an access log filter
+func buildAccessLogFilter(status, path, since string, now time.Time) (server.AccessLogFilter, error) {
+	var filter server.AccessLogFilter
+
+	statuses, err := server.ParseStatusFilter(status)
+	if err != nil {
+		return filter, err
+	}
+	filter.Status = statuses
+	filter.Path = path
+
+	if since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --since value %q: %w", since, err)
+		}
+		filter.Since = now.Add(-d)
+	}
+
+	return filter, nil
+}
+
+// formatAccessLogEntry renders an entry as a single colored line
+func formatAccessLogEntry(e *server.AccessLogEntry) string {
+	status := fmt.Sprintf("%d", e.Status)
+	switch {
+	case e.Status >= 500:
+		status = ui.Error(status)
+	case e.Status >= 400:
+		status = ui.Warning(status)
+	default:
+		status = ui.Success(status)
+	}
+
+	return fmt.Sprintf("%s %s %-6s %s %s %s",
+		ui.Muted(e.Time.Format("2006-01-02 15:04:05")),
+		status,
+		e.Method,
+		e.URI,
+		ui.Muted(e.Duration.Round(time.Millisecond).String()),
+		ui.Muted(e.RemoteIP),
+	)
+}
+
+func printAccessLogSummary(s server.AccessLogSummary) {
+	ui.PrintHeader("Access Log Summary")
+
+	if s.Requests == 0 {
+		ui.PrintWarning("No matching requests")
+		return
+	}
+
+	ui.PrintKeyValue("Window", fmt.Sprintf("%s → %s", s.First.Format("2006-01-02 15:04:05"), s.Last.Format("2006-01-02 15:04:05")))
+	ui.PrintKeyValue("Requests", fmt.Sprintf("%d (%.1f/min)", s.Requests, s.RequestsPerMinute()))
+	ui.PrintKeyValue("Error rate", fmt.Sprintf("%.2f%% (5xx)", s.ErrorRate()))
+	ui.PrintKeyValue("Latency", fmt.Sprintf("avg %s, p95 %s", s.AvgDuration.Round(time.Millisecond), s.P95Duration.Round(time.Millisecond)))
+
+	classes := make([]string, 0, len(s.StatusClasses))
+	for class := range s.StatusClasses {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		ui.PrintKeyValue(class, fmt.Sprintf("%d", s.StatusClasses[class]))
+	}
+
+	if len(s.TopPaths) > 0 {
+		println()
+		ui.PrintInfo("Top paths:")
+		for _, p := range s.TopPaths {
+			fmt.Printf("  %6d  %s\n", p.Count, p.Path)
+		}
+	}
+	println()
+}
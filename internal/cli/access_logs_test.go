@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessLogsCommandFlags(t *testing.T) {
+	if accessLogsCmd == nil {
+		t.Fatal("accessLogsCmd should not be nil")
+	}
+
+	if accessLogsCmd.Use != "access-logs" {
+		t.Errorf("accessLogsCmd.Use = %v, want access-logs", accessLogsCmd.Use)
+	}
+
+	flags := []struct {
+		name      string
+		shorthand string
+	}{
+		{"tail", "n"},
+		{"follow", "f"},
+		{"status", "s"},
+		{"path", "p"},
+		{"since", ""},
+		{"summary", ""},
+	}
+
+	for _, f := range flags {
+		flag := accessLogsCmd.Flags().Lookup(f.name)
+		if flag == nil {
+			t.Errorf("access-logs command should have --%s flag", f.name)
+			continue
+		}
+		if flag.Shorthand != f.shorthand {
+			t.Errorf("%s flag shorthand = %v, want %v", f.name, flag.Shorthand, f.shorthand)
+		}
+	}
+}
+
+func TestBuildAccessLogFilter(t *testing.T) {
+	now := time.Now()
+
+	filter, err := buildAccessLogFilter("5xx", "/api", "15m", now)
+	if err != nil {
+		t.Fatalf("buildAccessLogFilter() error = %v", err)
+	}
+	if len(filter.Status) != 1 || filter.Status[0] != "5xx" {
+		t.Errorf("Status = %v, want [5xx]", filter.Status)
+	}
+	if filter.Path != "/api" {
+		t.Errorf("Path = %v, want /api", filter.Path)
+	}
+	if !filter.Since.Equal(now.Add(-15 * time.Minute)) {
+		t.Errorf("Since = %v, want 15 minutes ago", filter.Since)
+	}
+
+	if _, err := buildAccessLogFilter("", "", "yesterday", now); err == nil {
+		t.Error("buildAccessLogFilter() should reject invalid --since")
+	}
+	if _, err := buildAccessLogFilter("oops", "", "", now); err == nil {
+		t.Error("buildAccessLogFilter() should reject invalid --status")
+	}
+}
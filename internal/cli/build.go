@@ -0,0 +1,504 @@
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/server"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+	"github.com/hmontazeri/mushak/internal/utils"
+)
+
+// buildLocally builds the images of commit on this machine and ships them to
+// the server, where the post-receive hook deploys them instead of building
+func buildLocally(cfg *config.DeployConfig, appCfg *config.AppConfig, commit string) error {
+	if err := appCfg.Build.Check(); err != nil {
+		return fmt.Errorf("invalid mushak.yaml: %w", err)
+	}
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	// Build for the server, not for this machine, unless mushak.yaml sets the platform
+	platform := appCfg.Build.Platform
+	if platform == "" {
+		if platform, err = server.DockerPlatform(executor); err != nil {
+			return err
+		}
+	}
+
+	buildArgs, err := resolveBuildArgs(executor, cfg.AppName, appCfg.Build)
+	if err != nil {
+		return err
+	}
+
+	// Secrets are kept out of the build directory, so they can't end up in the context
+	secretsDir, err := os.MkdirTemp("", "mushak-secrets-*")
+	if err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+	defer os.RemoveAll(secretsDir)
+
+	secretFiles, err := writeBuildSecrets(executor, cfg.AppName, appCfg.Build, secretsDir)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "mushak-build-*")
+	if err != nil {
+		return fmt.Errorf("failed to create build directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := utils.ExportCommit(".", commit, dir); err != nil {
+		return err
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Building %s locally for %s...", commit[:7], platform))
+	println()
+
+	images, err := buildImages(dir, cfg.AppName, commit, platform, appCfg.Build, buildArgs, secretFiles)
+	if err != nil {
+		return err
+	}
+
+	println()
+	if appCfg.Build.Registry != "" {
+		return shipImagesViaRegistry(executor, appCfg.Build.Registry, images)
+	}
+	return shipImagesOverSSH(executor, images)
+}
+
+// resolveBuildArgs returns the build arguments from mushak.yaml as NAME=VALUE,
+// looking up args_from_env in the environment the release is deployed with
+func resolveBuildArgs(executor *ssh.Executor, appName string, build config.BuildConfig) ([]string, error) {
+	var args []string
+	for _, name := range build.ArgNames() {
+		args = append(args, name+"="+build.Args[name])
+	}
+	if len(build.ArgsFromEnv) == 0 {
+		return args, nil
+	}
+
+	vars, err := deployEnvironment(executor, appName)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range build.ArgsFromEnv {
+		value, ok := vars[name]
+		if !ok || value == "" {
+			ui.PrintWarning(fmt.Sprintf("Build argument %s is not set in the environment", name))
+			continue
+		}
+		args = append(args, name+"="+value)
+	}
+	return args, nil
+}
+
+// writeBuildSecrets writes the build secrets from mushak.yaml to files in dir,
+// taking them from the environment the release is deployed with or from the
+// server, and returns the file of each secret by id
+func writeBuildSecrets(executor *ssh.Executor, appName string, build config.BuildConfig, dir string) (map[string]string, error) {
+	if len(build.Secrets) == 0 {
+		return nil, nil
+	}
+
+	var vars map[string]string
+	files := make(map[string]string, len(build.Secrets))
+	for _, secret := range build.Secrets {
+		var content string
+		if secret.Env != "" {
+			if vars == nil {
+				var err error
+				if vars, err = deployEnvironment(executor, appName); err != nil {
+					return nil, err
+				}
+			}
+			value, ok := vars[secret.Env]
+			if !ok || value == "" {
+				return nil, fmt.Errorf("build secret %s: %s is not set in the environment", secret.ID, secret.Env)
+			}
+			content = value
+		} else {
+			var err error
+			if content, err = server.ReadBuildSecretFile(executor, appName, secret.File); err != nil {
+				return nil, err
+			}
+		}
+
+		file := filepath.Join(dir, secret.ID)
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write build secret %s: %w", secret.ID, err)
+		}
+		files[secret.ID] = file
+	}
+	return files, nil
+}
+
+// buildImages builds the app in dir with the build settings from mushak.yaml
+// and returns the images, named the way the post-receive hook looks for them.
+// buildArgs are NAME=VALUE pairs, secretFiles the files of build.secrets by id.
+// SSH mounts use the local SSH agent.
+func buildImages(dir, appName, commit, platform string, build config.BuildConfig, buildArgs []string, secretFiles map[string]string) ([]string, error) {
+	var argFlags []string
+	for _, arg := range buildArgs {
+		argFlags = append(argFlags, "--build-arg", arg)
+	}
+	var sshFlags []string
+	if build.SSH != "" {
+		sshFlags = []string{"--ssh", "default"}
+	}
+
+	composeFile := ""
+	for _, name := range []string{"docker-compose.yml", "docker-compose.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			composeFile = name
+			break
+		}
+	}
+
+	if composeFile == "" {
+		dockerfile := build.DockerfilePath()
+
+		// Projects without a Dockerfile are built with a generated one, as on the server
+		generated, stack, err := generatedDockerfile(dir, build)
+		if err != nil {
+			return nil, err
+		}
+		if stack != "" {
+			ui.PrintInfo(fmt.Sprintf("No Dockerfile found, building with one generated for a %s project", stack.Name()))
+			if err := os.WriteFile(filepath.Join(dir, dockerfile), []byte(generated), 0644); err != nil {
+				return nil, fmt.Errorf("failed to write generated Dockerfile: %w", err)
+			}
+			if _, err := utils.AddGeneratedDockerignore(filepath.Join(dir, build.ContextPath())); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, dockerfile)); err != nil {
+			return nil, fmt.Errorf("no %s or docker-compose.yml found in %s", dockerfile, commit[:7])
+		}
+
+		image := server.PrebuiltImage(appName, commit, "")
+		args := []string{"build", "--platform", platform, "-f", dockerfile, "-t", image}
+		if build.Target != "" {
+			args = append(args, "--target", build.Target)
+		}
+		if deployNoCache {
+			args = append(args, "--no-cache")
+		}
+		args = append(args, argFlags...)
+		for _, secret := range build.Secrets {
+			args = append(args, "--secret", fmt.Sprintf("id=%s,src=%s", secret.ID, secretFiles[secret.ID]))
+		}
+		args = append(args, sshFlags...)
+		if err := runDocker(dir, nil, append(args, build.ContextPath())...); err != nil {
+			return nil, fmt.Errorf("failed to build image: %w", err)
+		}
+		return []string{image}, nil
+	}
+
+	services, err := utils.ComposeBuildServices(filepath.Join(dir, composeFile))
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no service in %s is built from source", composeFile)
+	}
+
+	// Dockerfile, context and target from mushak.yaml apply to the web service, as on the server
+	webService, err := utils.ComposeWebService(filepath.Join(dir, composeFile))
+	if err != nil {
+		return nil, err
+	}
+
+	// Name each built image the way the hook expects instead of compose's default
+	var override strings.Builder
+	override.WriteString("services:\n")
+	images := make([]string, 0, len(services))
+	for _, service := range services {
+		image := server.PrebuiltImage(appName, commit, service)
+		fmt.Fprintf(&override, "  %s:\n    image: %s\n", service, image)
+		isWeb := service == webService && build.Dockerfile+build.Context+build.Target != ""
+		if isWeb || len(build.Secrets) > 0 {
+			override.WriteString("    build:\n")
+		}
+		if isWeb {
+			if build.Context != "" {
+				fmt.Fprintf(&override, "      context: %s\n", build.Context)
+			}
+			if build.Dockerfile != "" {
+				fmt.Fprintf(&override, "      dockerfile: %s\n", build.Dockerfile)
+			}
+			if build.Target != "" {
+				fmt.Fprintf(&override, "      target: %s\n", build.Target)
+			}
+		}
+		if len(build.Secrets) > 0 {
+			override.WriteString("      secrets:\n")
+			for _, secret := range build.Secrets {
+				fmt.Fprintf(&override, "        - %s\n", secret.ID)
+			}
+		}
+		images = append(images, image)
+	}
+	if len(build.Secrets) > 0 {
+		override.WriteString("secrets:\n")
+		for _, secret := range build.Secrets {
+			fmt.Fprintf(&override, "  %s:\n    file: %s\n", secret.ID, secretFiles[secret.ID])
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "mushak-build.override.yml"), []byte(override.String()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write compose override: %w", err)
+	}
+
+	args := []string{"compose", "-p", fmt.Sprintf("mushak-%s-build", appName), "-f", composeFile, "-f", "mushak-build.override.yml", "build"}
+	if deployNoCache {
+		args = append(args, "--no-cache")
+	}
+	args = append(args, argFlags...)
+	args = append(args, sshFlags...)
+	env := append(os.Environ(), "DOCKER_DEFAULT_PLATFORM="+platform)
+	if err := runDocker(dir, env, append(args, services...)...); err != nil {
+		return nil, fmt.Errorf("failed to build images: %w", err)
+	}
+	return images, nil
+}
+
+// generatedDockerfile returns a Dockerfile for the project in dir if it has
+// neither the Dockerfile from mushak.yaml nor a docker-compose.yml, and the
+// stack it was generated for. Both are empty if the project has its own or
+// isn't one Mushak recognizes.
+func generatedDockerfile(dir string, build config.BuildConfig) (string, utils.Stack, error) {
+	for _, name := range []string{build.DockerfilePath(), "docker-compose.yml", "docker-compose.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return "", "", nil
+		}
+	}
+
+	context := filepath.Join(dir, build.ContextPath())
+	stack := utils.DetectStack(context)
+	if stack == "" {
+		return "", "", nil
+	}
+	content, err := utils.GenerateDockerfile(context, stack)
+	if err != nil {
+		return "", "", err
+	}
+	return content, stack, nil
+}
+
+// shipImagesOverSSH streams the images the server doesn't have yet with
+// 'docker save | docker load'. Images the server already has are only tagged.
+// Layers the server has in the same position of one of its images, such as
+// unchanged base image and dependency layers, are left out of the upload.
+func shipImagesOverSSH(executor *ssh.Executor, images []string) error {
+	ids := make(map[string]string, len(images))
+	idList := make([]string, 0, len(images))
+	for _, image := range images {
+		out, err := exec.Command("docker", "image", "inspect", "--format", "{{.Id}}", image).Output()
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", image, err)
+		}
+		ids[image] = strings.TrimSpace(string(out))
+		idList = append(idList, ids[image])
+	}
+
+	existing, err := server.ExistingImages(executor, idList)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, image := range images {
+		if !existing[ids[image]] {
+			missing = append(missing, image)
+			continue
+		}
+		if err := server.TagImage(executor, ids[image], image); err != nil {
+			return err
+		}
+		ui.PrintInfo(fmt.Sprintf("Server already has %s", image))
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	chains, err := server.LayerChains(executor)
+	if err != nil {
+		return err
+	}
+	layers := make(map[string][]string, len(missing))
+	for _, image := range missing {
+		out, err := exec.Command("docker", "image", "inspect", "--format", "{{range .RootFS.Layers}}{{.}} {{end}}", image).Output()
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", image, err)
+		}
+		layers[image] = strings.Fields(string(out))
+	}
+	skip := layersToSkip(layers, chains)
+
+	ui.PrintInfo(fmt.Sprintf("Uploading %d image%s to the server...", len(missing), pluralize(len(missing))))
+	if len(skip) > 0 {
+		ui.PrintInfo(fmt.Sprintf("Server already has %d of their layers, sending the rest", len(skip)))
+	}
+
+	err = uploadImages(executor, missing, skip)
+	if err != nil && len(skip) > 0 {
+		// Docker engines that need every layer in the archive, e.g. with the
+		// containerd image store, get them all
+		ui.PrintWarning(fmt.Sprintf("%v. Uploading the images with all their layers", err))
+		err = uploadImages(executor, missing, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	ui.PrintSuccess("Images uploaded")
+	return nil
+}
+
+// layersToSkip returns the layers of images that can be left out of the
+// upload: those the server has on top of the same layers, per chains from
+// server.LayerChains. A layer another image needs uploaded is kept.
+func layersToSkip(layers map[string][]string, chains map[string]bool) map[string]bool {
+	skip := make(map[string]bool)
+	needed := make(map[string]bool)
+	for _, imageLayers := range layers {
+		present := 0
+		for present < len(imageLayers) && chains[strings.Join(imageLayers[:present+1], ",")] {
+			present++
+		}
+		for _, layer := range imageLayers[:present] {
+			skip[layer] = true
+		}
+		for _, layer := range imageLayers[present:] {
+			needed[layer] = true
+		}
+	}
+	for layer := range needed {
+		delete(skip, layer)
+	}
+	return skip
+}
+
+// uploadImages streams 'docker save' of images to 'docker load' on the
+// server, leaving out the layers in skip
+func uploadImages(executor *ssh.Executor, images []string, skip map[string]bool) error {
+	save := exec.Command("docker", append([]string{"save"}, images...)...)
+	save.Stderr = os.Stderr
+	out, err := save.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+	if err := save.Start(); err != nil {
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(writer)
+		err := filterImageArchive(gz, out, skip)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		writer.CloseWithError(err)
+		// Let docker save finish if the upload stopped early
+		io.Copy(io.Discard, out)
+	}()
+
+	loadErr := server.LoadImages(executor, reader)
+	reader.Close()
+	if err := save.Wait(); err != nil {
+		return fmt.Errorf("failed to save images: %w", err)
+	}
+	return loadErr
+}
+
+// filterImageArchive copies a 'docker save' archive, leaving out the layers in
+// skip. Docker stores layers uncompressed under blobs/sha256/<digest>, so the
+// digest is the layer's. 'docker load' only reads the layers it doesn't have.
+// Archives in the older format, with layers in <id>/layer.tar, are copied whole.
+func filterImageArchive(dst io.Writer, src io.Reader, skip map[string]bool) error {
+	if len(skip) == 0 {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+
+	tr := tar.NewReader(src)
+	tw := tar.NewWriter(dst)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read image archive: %w", err)
+		}
+		if digest, ok := strings.CutPrefix(header.Name, "blobs/sha256/"); ok && skip["sha256:"+digest] {
+			continue
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// shipImagesViaRegistry pushes the images to the registry configured in
+// mushak.yaml and has the server pull them
+func shipImagesViaRegistry(executor *ssh.Executor, registry string, images []string) error {
+	for _, image := range images {
+		// Keep the commit (and service) tag under the registry's repository
+		ref := registry + image[strings.LastIndex(image, ":"):]
+
+		ui.PrintInfo(fmt.Sprintf("Pushing %s...", ref))
+		if err := runDocker("", nil, "tag", image, ref); err != nil {
+			return fmt.Errorf("failed to tag %s: %w", ref, err)
+		}
+		if err := runDocker("", nil, "push", ref); err != nil {
+			return fmt.Errorf("failed to push %s: %w", ref, err)
+		}
+
+		ui.PrintInfo(fmt.Sprintf("Pulling %s on the server...", ref))
+		if err := server.PullImage(executor, ref, image); err != nil {
+			return err
+		}
+	}
+
+	ui.PrintSuccess("Images shipped through the registry")
+	return nil
+}
+
+// runDocker runs a local docker command in dir, streaming its output
+func runDocker(dir string, env []string, args ...string) error {
+	cmd := exec.Command("docker", args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -2,8 +2,10 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/hmontazeri/mushak/internal/config"
 	"github.com/hmontazeri/mushak/internal/hooks"
@@ -25,12 +27,27 @@ the post-receive hook to build and deploy your application with zero downtime.`,
 
 var deployForce bool
 var deployNoCache bool
+var deployWatch string
+var deployRef string
+var deploySHA string
+var deployDirty bool
+var deployArchive bool
+var deployBuild string
+var deployImage string
 
 func init() {
 	rootCmd.AddCommand(deployCmd)
 
 	deployCmd.Flags().BoolVarP(&deployForce, "force", "f", false, "Force push to server")
 	deployCmd.Flags().BoolVar(&deployNoCache, "no-cache", false, "Do not use cache when building the image")
+	deployCmd.Flags().StringVar(&deployRef, "ref", "", "Deploy a tag, branch or commit instead of HEAD, without checking it out")
+	deployCmd.Flags().StringVar(&deploySHA, "sha", "", "Deploy a commit by SHA, including one only the server has")
+	deployCmd.Flags().BoolVar(&deployDirty, "dirty", false, "Deploy the working tree including uncommitted changes, pushed to a scratch ref")
+	deployCmd.Flags().BoolVar(&deployArchive, "archive", false, "Deploy the working tree including uncommitted changes by uploading a tarball over SSH")
+	deployCmd.MarkFlagsMutuallyExclusive("ref", "sha", "dirty", "archive")
+	deployCmd.Flags().StringVar(&deployImage, "image", "", "Deploy a prebuilt image from a registry (e.g. ghcr.io/acme/app:1.2.3) instead of source")
+	deployCmd.Flags().StringVar(&deployBuild, "build", "server", "Where to build images: server, or local to build on this machine and ship the images to the server")
+	deployCmd.Flags().StringVar(&deployWatch, "watch", "", "Watch the new release for this long and roll back if it fails (e.g. 2m, 0 to disable)")
 }
 
 func runDeploy(cmd *cobra.Command, args []string) error {
@@ -51,10 +68,69 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get current branch: %w", err)
 	}
 
+	// Deploy HEAD, or the commit given with --ref or --sha
+	target := deployRef
+	if deploySHA != "" {
+		target = deploySHA
+	}
+	localCommit := ""
+	if target != "" {
+		localCommit = resolveLocalCommit(target)
+		if localCommit == "" && deployRef != "" {
+			return fmt.Errorf("unknown ref %s", deployRef)
+		}
+	}
+
+	if deployBuild != "server" && deployBuild != "local" {
+		return fmt.Errorf("invalid --build %q: use server or local", deployBuild)
+	}
+	buildLocal := deployBuild == "local"
+	if buildLocal && deployArchive {
+		return fmt.Errorf("--build local can't be combined with --archive")
+	}
+	if buildLocal && target != "" && localCommit == "" {
+		return fmt.Errorf("--build local needs commit %s locally to build it", target)
+	}
+
+	appCfg, err := loadDeployAppConfig(localCommit)
+	if err != nil {
+		return err
+	}
+
+	// Static sites are built on the server and have no image to deploy or build locally
+	static := appCfg.IsStatic()
+	if static && (deployImage != "" || buildLocal) {
+		return fmt.Errorf("static sites are built on the server and can't be combined with --image or --build local")
+	}
+
+	// Prebuilt images from a registry are deployed without any source
+	image := deployImage
+	if image == "" {
+		image = appCfg.Image
+	}
+	uncommitted := deployDirty || deployArchive
+	if image != "" && (target != "" || uncommitted || buildLocal) {
+		return fmt.Errorf("image %s is deployed as is and can't be combined with --ref, --sha, --dirty, --archive or --build local", image)
+	}
+
 	ui.PrintHeader("Mushak Deployment")
 	ui.PrintKeyValue("App", cfg.AppName)
 	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
-	ui.PrintKeyValue("Branch", fmt.Sprintf("%s -> %s", currentBranch, cfg.Branch))
+	if image != "" {
+		ui.PrintKeyValue("Image", image)
+	} else if uncommitted {
+		ui.PrintKeyValue("Source", "Working tree with uncommitted changes")
+	} else if target != "" {
+		ui.PrintKeyValue("Ref", fmt.Sprintf("%s -> %s", target, cfg.Branch))
+	} else {
+		ui.PrintKeyValue("Branch", fmt.Sprintf("%s -> %s", currentBranch, cfg.Branch))
+	}
+	if cfg.RootDir != "" {
+		ui.PrintKeyValue("Directory", cfg.RootDir)
+	}
+	if static {
+		ui.PrintKeyValue("Type", "Static site")
+	}
 	ui.PrintKeyValue("Domain", fmt.Sprintf("https://%s", cfg.Domain))
 	if deployNoCache {
 		ui.PrintKeyValue("Cache", "Disabled")
@@ -62,14 +138,14 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 	println()
 
 	// Check if current branch matches configured branch
-	if currentBranch != cfg.Branch {
+	if image == "" && target == "" && !uncommitted && currentBranch != cfg.Branch {
 		ui.PrintWarning(fmt.Sprintf("You're on branch '%s' but configured to deploy '%s'", currentBranch, cfg.Branch))
 		println()
 	}
 
-	// Verify git remote exists
+	// Verify git remote exists. Archives and images are deployed over SSH instead
 	checkCmd := exec.Command("git", "remote", "get-url", cfg.RemoteName)
-	if err := checkCmd.Run(); err != nil {
+	if err := checkCmd.Run(); err != nil && !deployArchive && image == "" {
 		return fmt.Errorf("git remote '%s' not found. Please run 'mushak init' first", cfg.RemoteName)
 	}
 
@@ -78,35 +154,403 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		ui.PrintWarning(fmt.Sprintf("%v", err))
 	}
 
-	// Load application configuration (optional mushak.yaml in repo root)
-	appCfg, _ := config.LoadConfig("mushak.yaml")
+	// Fail before pushing if required variables are missing or invalid
+	if err := checkEnvSchema(cfg, appCfg); err != nil {
+		return err
+	}
+
+	watch := resolveWatchConfig(appCfg)
+	if _, err := watch.Window(); err != nil {
+		return err
+	}
 
 	// Update post-receive hook on server to ensure it has the latest logic
+	hookUpdated := true
 	if err := UpdateServerHook(cfg, appCfg); err != nil {
 		ui.PrintWarning(fmt.Sprintf("Failed to update deployment hook: %v", err))
+		hookUpdated = false
 	}
 	println()
 
+	// Older hooks don't know the scratch ref and would skip the deployment
+	if uncommitted && !hookUpdated {
+		return fmt.Errorf("deploying uncommitted changes needs an up-to-date deployment hook")
+	}
+	if buildLocal && !hookUpdated {
+		return fmt.Errorf("building locally needs an up-to-date deployment hook")
+	}
+	if static && !hookUpdated {
+		return fmt.Errorf("deploying a static site needs an up-to-date deployment hook")
+	}
+
+	// Commit the working tree up front, so a local build builds exactly what is pushed
+	dirtyCommit := ""
+	if deployDirty {
+		dirtyCommit, err = utils.WorkingTreeCommit(".", uncommittedMessage())
+		if err != nil {
+			return err
+		}
+	}
+
+	if buildLocal {
+		commit := localCommit
+		if deployDirty {
+			commit = dirtyCommit
+		} else if commit == "" {
+			commit = resolveLocalCommit("HEAD")
+		}
+		if commit == "" {
+			return fmt.Errorf("nothing to build: the repository has no commits")
+		}
+		if err := buildLocally(cfg, appCfg, commit); err != nil {
+			return err
+		}
+		println()
+	}
+
+	// Builds on the server that mount the SSH agent use the local one
+	forwardAgent := appCfg != nil && appCfg.Build.SSH == config.ForwardSSHAgent && !buildLocal
+
+	deployed := true
+	switch {
+	case image != "":
+		if err := deployRegistryImage(cfg, appCfg, image); err != nil {
+			return err
+		}
+	case deployArchive:
+		if err := deployWorkingTreeArchive(cfg, forwardAgent); err != nil {
+			return err
+		}
+	case target != "":
+		// Commits the server already has are deployed without pushing
+		var push bool
+		push, deployed, err = deployServerCommit(cfg, target, localCommit, forwardAgent)
+		if err != nil {
+			return err
+		}
+		if !push && !deployed {
+			return nil
+		}
+		if push {
+			// Forced, since hotfixes and older commits don't descend from the deployed branch
+			if deployed, err = pushDeploy(cfg, "+"+localCommit, "refs/heads/"+cfg.Branch, hookUpdated, forwardAgent); err != nil {
+				return err
+			}
+		}
+	case deployDirty:
+		// The scratch ref is overwritten on every dirty deploy
+		if deployed, err = pushDeploy(cfg, "+"+dirtyCommit, server.DirtyRef, hookUpdated, forwardAgent); err != nil {
+			return err
+		}
+	default:
+		if deployed, err = pushDeploy(cfg, "HEAD", "refs/heads/"+cfg.Branch, hookUpdated, forwardAgent); err != nil {
+			return err
+		}
+	}
+
+	if window, _ := watch.Window(); window > 0 {
+		// A monorepo push that changes nothing in the app leaves the live release as it was
+		if !deployed {
+			ui.PrintInfo("No new release was deployed, skipping the release watch")
+			return nil
+		}
+		// Caddy serves static sites itself, there is no container to watch
+		if static {
+			ui.PrintInfo("Static sites are served by Caddy, skipping the release watch")
+			return nil
+		}
+		println()
+		return watchDeployment(cfg, watch)
+	}
+
+	return nil
+}
+
+// pushDeploy pushes src to the ref dst on the server, which runs the deployment.
+// With forwardAgent the local SSH agent is forwarded to the hook. It reports
+// whether a new release was deployed: the hook skips monorepo pushes that
+// change nothing in the app's directory.
+func pushDeploy(cfg *config.DeployConfig, src, dst string, hookUpdated, forwardAgent bool) (bool, error) {
 	// Build push command
-	pushArgs := []string{"push", cfg.RemoteName, fmt.Sprintf("HEAD:refs/heads/%s", cfg.Branch)}
+	pushArgs := []string{"push", cfg.RemoteName, fmt.Sprintf("%s:%s", src, dst)}
 	if deployForce {
 		pushArgs = append(pushArgs, "--force")
 	}
+	// The hook records who deployed. Push options are enabled when the hook is installed
+	if hookUpdated {
+		pushArgs = append(pushArgs, "-o", "deploy-user="+utils.DeployUser())
+	}
 
 	ui.PrintInfo("Pushing to server...")
 	println()
 
-	// Execute git push with output streaming
+	// Execute git push with output streaming. The hook's output arrives on stderr
 	pushCmd := exec.Command("git", pushArgs...)
 	pushCmd.Stdout = os.Stdout
-	pushCmd.Stderr = os.Stderr
+	stderr := utils.NewOutputWatcher(os.Stderr, hooks.DeploymentSkippedNotice)
+	pushCmd.Stderr = stderr
 	pushCmd.Stdin = os.Stdin
+	if forwardAgent {
+		sshCommand := os.Getenv("GIT_SSH_COMMAND")
+		if sshCommand == "" {
+			sshCommand = "ssh"
+		}
+		pushCmd.Env = append(os.Environ(), "GIT_SSH_COMMAND="+sshCommand+" -o ForwardAgent=yes")
+	}
 
 	if err := pushCmd.Run(); err != nil {
-		return fmt.Errorf("deployment failed: %w", err)
+		return false, fmt.Errorf("deployment failed: %w", err)
 	}
+	return !stderr.Found(), nil
+}
 
-	return nil
+// deployWorkingTreeArchive uploads a tarball of the working tree over SSH and
+// deploys it through the same pipeline as a push
+func deployWorkingTreeArchive(cfg *config.DeployConfig, forwardAgent bool) error {
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+	if forwardAgent {
+		if err := executor.ForwardAgent(); err != nil {
+			return err
+		}
+	}
+
+	// Stream the archive instead of building it in memory
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(utils.WriteWorkingTreeArchive(".", writer))
+	}()
+
+	ui.PrintInfo("Uploading working tree...")
+	println()
+
+	err = server.DeployArchive(executor, cfg, reader, uncommittedMessage())
+	reader.Close()
+	return err
+}
+
+// deployRegistryImage has the server pull image and deploy it
+func deployRegistryImage(cfg *config.DeployConfig, appCfg *config.AppConfig, image string) error {
+	if err := server.ValidateImageRef(image); err != nil {
+		return err
+	}
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	ui.PrintInfo(fmt.Sprintf("Deploying image %s...", image))
+	println()
+
+	return server.DeployImage(executor, cfg, image, imageDeploySettings(cfg, appCfg))
+}
+
+// imageDeploySettings returns the settings an image release runs with. Like in
+// the hook, mushak.yaml takes precedence over the overrides in .mushak/mushak.yaml.
+func imageDeploySettings(cfg *config.DeployConfig, appCfg *config.AppConfig) server.ImageDeploySettings {
+	defaults := config.DefaultConfig()
+	settings := server.ImageDeploySettings{
+		InternalPort:    appCfg.InternalPort,
+		HealthPath:      appCfg.HealthPath,
+		HealthTimeout:   appCfg.HealthTimeout,
+		DrainSeconds:    appCfg.DrainSeconds,
+		StopGracePeriod: appCfg.StopGracePeriod,
+	}
+
+	if settings.InternalPort == defaults.InternalPort && cfg.InternalPort > 0 {
+		settings.InternalPort = cfg.InternalPort
+	}
+	if settings.HealthPath == defaults.HealthPath && cfg.HealthPath != "" {
+		settings.HealthPath = cfg.HealthPath
+	}
+	if settings.HealthTimeout == defaults.HealthTimeout && cfg.HealthTimeout > 0 {
+		settings.HealthTimeout = cfg.HealthTimeout
+	}
+	return settings
+}
+
+// uncommittedMessage describes a release of uncommitted work, used as its commit subject
+func uncommittedMessage() string {
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "Uncommitted changes"
+	}
+	return fmt.Sprintf("Uncommitted changes on top of %s", strings.TrimSpace(string(out)))
+}
+
+// resolveLocalCommit returns the full SHA of the commit rev points to in the
+// local repository, or "" if it doesn't exist locally
+func resolveLocalCommit(rev string) string {
+	out, err := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// isLocalAncestor reports whether commit is an ancestor of other in the local
+// repository. It is false if either commit is unknown locally.
+func isLocalAncestor(commit, other string) bool {
+	return exec.Command("git", "merge-base", "--is-ancestor", commit, other).Run() == nil
+}
+
+// loadDeployAppConfig loads the application configuration (optional
+// mushak.yaml in the repo root), from localCommit if the deployed commit
+// isn't HEAD. Defaults are returned without a mushak.yaml.
+func loadDeployAppConfig(localCommit string) (*config.AppConfig, error) {
+	var appCfg *config.AppConfig
+	var err error
+	if localCommit != "" {
+		appCfg, err = loadConfigAtCommit(localCommit)
+	} else {
+		appCfg, err = config.LoadConfig("mushak.yaml")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid mushak.yaml: %w", err)
+	}
+	if err := appCfg.CheckType(); err != nil {
+		return nil, fmt.Errorf("invalid mushak.yaml: %w", err)
+	}
+	return appCfg, nil
+}
+
+// loadConfigAtCommit loads mushak.yaml as of commit, or the defaults if the
+// commit has none. The path is relative to the current directory, which is the
+// app's directory in a monorepo.
+func loadConfigAtCommit(commit string) (*config.AppConfig, error) {
+	data, err := exec.Command("git", "show", commit+":./mushak.yaml").Output()
+	if err != nil {
+		return config.DefaultConfig(), nil
+	}
+	return config.ParseConfig(data)
+}
+
+// deployServerCommit deploys target through the hook if the server's
+// repository already has it, asking first if it is older than the live
+// release. push is true if the user confirmed and the commit needs to be
+// pushed, deployed is false if the user declined or the hook found nothing to
+// deploy.
+func deployServerCommit(cfg *config.DeployConfig, target, localCommit string, forwardAgent bool) (push, deployed bool, err error) {
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return false, false, fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return false, false, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	rev := target
+	if localCommit != "" {
+		rev = localCommit
+	}
+	commit, err := server.ResolveServerCommit(executor, cfg.AppName, rev)
+	if err != nil {
+		return false, false, err
+	}
+
+	// Commits the server doesn't have are compared with the live release locally
+	var behind bool
+	var live string
+	if commit != "" {
+		behind, live, err = server.IsBehindLive(executor, cfg.AppName, commit)
+	} else {
+		if localCommit == "" {
+			return false, false, fmt.Errorf("commit %s not found locally or on the server", target)
+		}
+		var liveCommit string
+		live, liveCommit, err = server.LiveCommit(executor, cfg.AppName)
+		behind = liveCommit != "" && isLocalAncestor(localCommit, liveCommit)
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if behind {
+		ui.PrintWarning(fmt.Sprintf("%s is older than the live release %s", target, live))
+		confirm, err := utils.Confirm("Deploy it anyway?")
+		if err != nil {
+			return false, false, err
+		}
+		if !confirm {
+			ui.PrintInfo("Deployment cancelled.")
+			return false, false, nil
+		}
+	}
+
+	if commit == "" {
+		return true, false, nil
+	}
+
+	if forwardAgent {
+		if err := executor.ForwardAgent(); err != nil {
+			return false, false, err
+		}
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Deploying %s from the server's repository...", commit[:7]))
+	println()
+	deployed, err = server.DeployCommit(executor, cfg, commit)
+	return false, deployed, err
+}
+
+// resolveWatchConfig returns the watch settings from mushak.yaml, with the
+// window overridden by --watch
+func resolveWatchConfig(appCfg *config.AppConfig) config.WatchConfig {
+	var watch config.WatchConfig
+	if appCfg != nil {
+		watch = appCfg.Watch
+	}
+	if deployWatch != "" {
+		watch.Duration = deployWatch
+	}
+	return watch
+}
+
+// watchDeployment monitors the release just deployed and rolls back if it fails
+func watchDeployment(cfg *config.DeployConfig, watch config.WatchConfig) error {
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	return server.WatchRelease(ssh.NewExecutor(client), cfg, watch)
 }
 
 func getCurrentBranch() (string, error) {
@@ -160,14 +604,154 @@ func UpdateServerHook(cfg *config.DeployConfig, appCfg *config.AppConfig) error
 		healthTimeout = appCfg.HealthTimeout
 	}
 
-	hookScript := hooks.GeneratePostReceiveHook(cfg.AppName, cfg.Domain, cfg.Branch, deployNoCache, internalPort, healthPath, healthTimeout)
+	hookScript := hooks.GeneratePostReceiveHook(cfg.AppName, cfg.Domain, cfg.Branch, cfg.RootDir, cfg.SkipUnchanged, deployNoCache, internalPort, healthPath, healthTimeout)
 	if err := server.InstallPostReceiveHook(executor, cfg.AppName, hookScript); err != nil {
 		return fmt.Errorf("failed to install post-receive hook: %w", err)
 	}
 
+	// Sync TLS settings so the site block written by the hook picks them up
+	tlsMode, tlsProvider := resolveTLSConfig(cfg, appCfg)
+	if err := server.ApplyTLSConfig(executor, cfg.AppName, tlsMode, tlsProvider); err != nil {
+		return fmt.Errorf("failed to apply TLS settings: %w", err)
+	}
+
+	// Sync rate limiting and request blocking from mushak.yaml
+	if appCfg != nil {
+		if err := server.ApplyProtectionConfig(executor, cfg.AppName, appCfg.Protection); err != nil {
+			return fmt.Errorf("failed to apply protection settings: %w", err)
+		}
+	}
+
+	// Sync env.schema so the hook validates the environment before building
+	if appCfg != nil {
+		if err := server.SyncEnvSchema(executor, cfg.AppName, appCfg.Env); err != nil {
+			return err
+		}
+	}
+
+	// Sync retention so old releases are cleaned up as configured
+	retention := config.RetentionConfig{}
+	if appCfg != nil {
+		retention = appCfg.Retention
+	}
+	if err := server.SyncRetention(executor, cfg.AppName, retention); err != nil {
+		return err
+	}
+
+	// Sync the build settings so the hook builds the configured Dockerfile and stage
+	build := config.BuildConfig{}
+	if appCfg != nil {
+		build = appCfg.Build
+	}
+	if err := server.SyncBuildSettings(executor, cfg.AppName, build); err != nil {
+		return err
+	}
+
+	// Static sites are built by the hook without an image
+	if err := server.SyncStaticSettings(executor, cfg.AppName, appCfg); err != nil {
+		return err
+	}
+
+	// Apps without a Dockerfile are built with one generated for their stack
+	dockerfile, stack := "", utils.Stack("")
+	if !appCfg.IsStatic() {
+		dockerfile, stack, err = generatedDockerfile(".", build)
+		if err != nil {
+			return err
+		}
+	}
+	if stack != "" {
+		ui.PrintInfo(fmt.Sprintf("No Dockerfile found, building with one generated for a %s project", stack.Name()))
+	}
+	if err := server.SyncGeneratedDockerfile(executor, cfg.AppName, dockerfile); err != nil {
+		return err
+	}
+
+	// Give the hook the team key so it can decrypt secrets.env.enc
+	if key, err := utils.LoadSecretsKey(); err == nil {
+		if err := server.SyncSecretsKey(executor, cfg.AppName, key); err != nil {
+			return err
+		}
+	} else if _, statErr := os.Stat(utils.SecretsFile); statErr == nil {
+		ui.PrintWarning(fmt.Sprintf("%s found but no secrets key available locally. The server's existing key will be used", utils.SecretsFile))
+	}
+
 	return nil
 }
 
+// checkEnvSchema validates the server's environment file, together with the
+// local secrets, against env.schema in mushak.yaml
+func checkEnvSchema(cfg *config.DeployConfig, appCfg *config.AppConfig) error {
+	if appCfg == nil || len(appCfg.Env.Schema) == 0 {
+		return nil
+	}
+
+	if err := appCfg.Env.Check(); err != nil {
+		return fmt.Errorf("invalid mushak.yaml: %w", err)
+	}
+
+	ui.PrintInfo("Validating environment variables...")
+
+	executor, client, err := connectEnv(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	vars, err := deployEnvironment(executor, cfg.AppName)
+	if err != nil {
+		return err
+	}
+
+	problems, defaulted := appCfg.Env.Validate(vars)
+	for _, key := range defaulted {
+		ui.PrintInfo(fmt.Sprintf("%s not set, using default %s", key, appCfg.Env.Schema[key].Default))
+	}
+
+	if len(problems) > 0 {
+		ui.PrintError("Environment does not match env.schema in mushak.yaml:")
+		for _, problem := range problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+		return fmt.Errorf("environment validation failed. Use 'mushak env set KEY=VALUE' to fix it")
+	}
+
+	ui.PrintSuccess("Environment variables valid")
+	return nil
+}
+
+// deployEnvironment returns the variables a release is deployed with: the
+// server's env file, overridden by the local secrets
+func deployEnvironment(executor *ssh.Executor, appName string) (map[string]string, error) {
+	vars := make(map[string]string)
+	if content, path, err := readServerEnvFile(executor, appName, ""); err == nil {
+		f, err := utils.ParseEnv(content, serverEnvFormat(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		vars = f.Vars()
+	}
+
+	// Secrets take precedence over the env file, as in the deploy hook
+	if _, err := os.Stat(utils.SecretsFile); err == nil {
+		if key, err := utils.LoadSecretsKey(); err == nil {
+			content, err := utils.ReadSecretsFile(utils.SecretsFile, key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", utils.SecretsFile, err)
+			}
+			secrets, err := utils.ParseEnvContent(content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", utils.SecretsFile, err)
+			}
+			for k, v := range secrets {
+				vars[k] = v
+			}
+		}
+	}
+
+	return vars, nil
+}
+
 // checkAndUploadEnvFile checks if env file exists on server, if not prompts to upload local
 func checkAndUploadEnvFile(cfg *config.DeployConfig) error {
 	// Check if local env file exists
@@ -249,7 +833,7 @@ func checkAndUploadEnvFile(cfg *config.DeployConfig) error {
 		}
 
 		targetPath := fmt.Sprintf("/var/www/%s/%s", cfg.AppName, targetFile)
-		if err := executor.WriteFileSudo(targetPath, string(content)); err != nil {
+		if err := server.WriteEnvFile(executor, cfg.AppName, targetPath, string(content), envAuthor()); err != nil {
 			return fmt.Errorf("failed to upload: %w", err)
 		}
 
@@ -1,9 +1,16 @@
 package cli
 
 import (
+	"archive/tar"
+	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/utils"
 )
 
 func TestDeployCommand(t *testing.T) {
@@ -177,3 +184,249 @@ func TestPluralize(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveWatchConfig(t *testing.T) {
+	defer func() { deployWatch = "" }()
+
+	appCfg := &config.AppConfig{Watch: config.WatchConfig{Duration: "2m", MaxErrorRate: 1}}
+
+	if got := resolveWatchConfig(appCfg); got.Duration != "2m" {
+		t.Errorf("resolveWatchConfig() duration = %q, want 2m from mushak.yaml", got.Duration)
+	}
+
+	deployWatch = "0"
+	got := resolveWatchConfig(appCfg)
+	if window, err := got.Window(); err != nil || window != 0 {
+		t.Errorf("--watch 0 should disable the watch, got %v, %v", window, err)
+	}
+	if got.MaxErrorRate != 1 {
+		t.Error("--watch should keep the thresholds from mushak.yaml")
+	}
+
+	deployWatch = "30s"
+	if got := resolveWatchConfig(nil); got.Duration != "30s" {
+		t.Errorf("resolveWatchConfig(nil) duration = %q, want 30s", got.Duration)
+	}
+}
+
+func TestDeployRefFlags(t *testing.T) {
+	for _, name := range []string{"ref", "sha", "dirty", "archive"} {
+		if deployCmd.Flags().Lookup(name) == nil {
+			t.Errorf("deploy command should have --%s flag", name)
+		}
+	}
+
+	cmd := *deployCmd
+	cmd.ResetFlags()
+	cmd.Flags().AddFlagSet(deployCmd.Flags())
+	if err := cmd.ParseFlags([]string{"--ref", "v1.0.0", "--sha", "abc123"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	if err := cmd.ValidateFlagGroups(); err == nil {
+		t.Error("--ref and --sha should be mutually exclusive")
+	}
+	deployRef, deploySHA = "", ""
+	deployCmd.Flags().Lookup("ref").Changed = false
+	deployCmd.Flags().Lookup("sha").Changed = false
+
+	if err := cmd.ParseFlags([]string{"--dirty", "--archive"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	if err := cmd.ValidateFlagGroups(); err == nil {
+		t.Error("--dirty and --archive should be mutually exclusive")
+	}
+	deployDirty, deployArchive = false, false
+	deployCmd.Flags().Lookup("dirty").Changed = false
+	deployCmd.Flags().Lookup("archive").Changed = false
+}
+
+func TestResolveLocalCommit(t *testing.T) {
+	if commit := resolveLocalCommit("HEAD"); len(commit) != 40 {
+		t.Errorf("resolveLocalCommit(HEAD) = %q, want a full SHA", commit)
+	}
+	if commit := resolveLocalCommit("no-such-ref-xyz"); commit != "" {
+		t.Errorf("resolveLocalCommit(no-such-ref-xyz) = %q, want empty", commit)
+	}
+}
+
+func TestIsLocalAncestor(t *testing.T) {
+	head, parent := resolveLocalCommit("HEAD"), resolveLocalCommit("HEAD~1")
+	if parent == "" {
+		t.Skip("repository has a single commit")
+	}
+	if !isLocalAncestor(parent, head) {
+		t.Error("isLocalAncestor(HEAD~1, HEAD) = false, want true")
+	}
+	if isLocalAncestor(head, parent) {
+		t.Error("isLocalAncestor(HEAD, HEAD~1) = true, want false")
+	}
+	if isLocalAncestor(parent, "0123456789012345678901234567890123456789") {
+		t.Error("isLocalAncestor() with an unknown commit = true, want false")
+	}
+}
+
+func TestBuildImagesWithoutDockerfile(t *testing.T) {
+	_, err := buildImages(t.TempDir(), "myapp", "abc1234def", "linux/amd64", config.BuildConfig{}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "no Dockerfile or docker-compose.yml") {
+		t.Errorf("buildImages() error = %v, want missing Dockerfile error", err)
+	}
+
+	build := config.BuildConfig{Context: "app", Dockerfile: "Dockerfile.prod"}
+	_, err = buildImages(t.TempDir(), "myapp", "abc1234def", "linux/amd64", build, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "no app/Dockerfile.prod or docker-compose.yml") {
+		t.Errorf("buildImages() error = %v, want missing app/Dockerfile.prod error", err)
+	}
+}
+
+func TestGeneratedDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content, stack, err := generatedDockerfile(dir, config.BuildConfig{})
+	if err != nil || stack != utils.StackGo || !strings.Contains(content, "FROM golang:") {
+		t.Errorf("generatedDockerfile() = %q, %q, %v, want a Go Dockerfile", content, stack, err)
+	}
+
+	// The stack is detected in the build context
+	if _, stack, _ := generatedDockerfile(dir, config.BuildConfig{Context: "app"}); stack != "" {
+		t.Errorf("generatedDockerfile() stack = %q, want none for an empty context", stack)
+	}
+
+	// Projects with their own Dockerfile or compose file are left alone
+	for _, name := range []string{"Dockerfile", "docker-compose.yml"} {
+		dir := t.TempDir()
+		for _, file := range []string{"go.mod", name} {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(""), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if content, stack, err := generatedDockerfile(dir, config.BuildConfig{}); content != "" || stack != "" || err != nil {
+			t.Errorf("generatedDockerfile() with %s = %q, %q, %v, want nothing", name, content, stack, err)
+		}
+	}
+}
+
+func TestImageDeploySettings(t *testing.T) {
+	// .mushak/mushak.yaml overrides apply where mushak.yaml keeps the defaults
+	cfg := &config.DeployConfig{InternalPort: 3000, HealthPath: "/up", HealthTimeout: 90}
+	appCfg := config.DefaultConfig()
+	appCfg.HealthPath = "/health"
+
+	settings := imageDeploySettings(cfg, appCfg)
+	if settings.InternalPort != 3000 {
+		t.Errorf("InternalPort = %d, want 3000", settings.InternalPort)
+	}
+	if settings.HealthPath != "/health" {
+		t.Errorf("HealthPath = %q, want /health from mushak.yaml", settings.HealthPath)
+	}
+	if settings.HealthTimeout != 90 {
+		t.Errorf("HealthTimeout = %d, want 90", settings.HealthTimeout)
+	}
+	if settings.DrainSeconds != 10 || settings.StopGracePeriod != 10 {
+		t.Errorf("DrainSeconds, StopGracePeriod = %d, %d, want defaults", settings.DrainSeconds, settings.StopGracePeriod)
+	}
+}
+
+func TestLoadDeployAppConfig(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Defaults without a mushak.yaml
+	appCfg, err := loadDeployAppConfig("")
+	if err != nil || appCfg == nil {
+		t.Fatalf("loadDeployAppConfig() = %v, %v, want the defaults", appCfg, err)
+	}
+
+	// A malformed mushak.yaml fails the deployment instead of being ignored
+	if err := os.WriteFile("mushak.yaml", []byte("image: [unterminated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if appCfg, err := loadDeployAppConfig(""); err == nil || appCfg != nil {
+		t.Errorf("loadDeployAppConfig() = %v, %v, want an error for invalid YAML", appCfg, err)
+	}
+
+	if err := os.WriteFile("mushak.yaml", []byte("type: lambda\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadDeployAppConfig(""); err == nil {
+		t.Error("loadDeployAppConfig() should fail for an invalid type")
+	}
+}
+
+func TestLayersToSkip(t *testing.T) {
+	chains := map[string]bool{
+		"sha256:base": true, "sha256:base,sha256:deps": true, "sha256:base,sha256:deps,sha256:old": true,
+		"sha256:other": true,
+	}
+	layers := map[string][]string{
+		// Code changed on top of the same base and dependencies
+		"mushak-app-prebuilt:new": {"sha256:base", "sha256:deps", "sha256:code"},
+		// deps in another position doesn't match the server's chain
+		"mushak-app-prebuilt:new-worker": {"sha256:other2", "sha256:deps"},
+		// other is on the server but at the bottom of a different chain
+		"mushak-app-prebuilt:new-cron": {"sha256:base", "sha256:other"},
+	}
+
+	skip := layersToSkip(layers, chains)
+	if !skip["sha256:base"] {
+		t.Error("layersToSkip() should skip the base layer the server has")
+	}
+	for _, layer := range []string{"sha256:deps", "sha256:code", "sha256:other", "sha256:other2"} {
+		if skip[layer] {
+			t.Errorf("layersToSkip() should upload %s, an image needs it", layer)
+		}
+	}
+
+	if skip := layersToSkip(map[string][]string{"img": {"sha256:base", "sha256:deps", "sha256:code"}}, chains); !skip["sha256:deps"] || skip["sha256:code"] {
+		t.Errorf("layersToSkip() = %v, want base and deps only", skip)
+	}
+}
+
+func TestFilterImageArchive(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, name := range []string{"blobs/sha256/aaa", "blobs/sha256/bbb", "blobs/sha256/cfg", "manifest.json", "index.json"} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var filtered bytes.Buffer
+	if err := filterImageArchive(&filtered, bytes.NewReader(archive.Bytes()), map[string]bool{"sha256:aaa": true}); err != nil {
+		t.Fatalf("filterImageArchive() error = %v", err)
+	}
+
+	var names []string
+	tr := tar.NewReader(&filtered)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	if got := strings.Join(names, " "); got != "blobs/sha256/bbb blobs/sha256/cfg manifest.json index.json" {
+		t.Errorf("filterImageArchive() entries = %q, want all but the skipped layer", got)
+	}
+
+	// Nothing to skip copies the archive as is
+	filtered.Reset()
+	if err := filterImageArchive(&filtered, bytes.NewReader(archive.Bytes()), nil); err != nil || !bytes.Equal(filtered.Bytes(), archive.Bytes()) {
+		t.Errorf("filterImageArchive() without layers to skip should copy the archive, error = %v", err)
+	}
+}
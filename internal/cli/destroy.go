@@ -151,7 +151,8 @@ func runDestroy(cmd *cobra.Command, args []string) error {
 	// Remove deployment files
 	ui.PrintInfo("Removing deployment files...")
 	deployPath := fmt.Sprintf("/var/www/%s", destroyApp)
-	if _, err := executor.RunSudo(fmt.Sprintf("rm -rf %s", deployPath)); err != nil {
+	// Decrypted secrets of the app's releases are kept in memory-backed storage
+	if _, err := executor.RunSudo(fmt.Sprintf("rm -rf %s /dev/shm/mushak-%s", deployPath, destroyApp)); err != nil {
 		ui.PrintWarning(fmt.Sprintf("Failed to remove deployment files: %v", err))
 	}
 	ui.PrintSuccess("Deployment files removed")
@@ -124,7 +124,7 @@ func runDomain(cmd *cobra.Command, args []string) error {
 
 	// Update post-receive hook
 	ui.PrintInfo("Updating deployment hook...")
-	hookScript := hooks.GeneratePostReceiveHook(cfg.AppName, newDomain, cfg.Branch, false, internalPort, healthPath, healthTimeout)
+	hookScript := hooks.GeneratePostReceiveHook(cfg.AppName, newDomain, cfg.Branch, cfg.RootDir, cfg.SkipUnchanged, false, internalPort, healthPath, healthTimeout)
 	if err := server.InstallPostReceiveHook(executor, cfg.AppName, hookScript); err != nil {
 		return fmt.Errorf("failed to install post-receive hook: %w", err)
 	}
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"sort"
 	"strings"
 
@@ -18,17 +19,22 @@ import (
 var envCmd = &cobra.Command{
 	Use:   "env",
 	Short: "Manage environment variables",
-	Long:  `Manage environment variables for your application.`,
+	Long: `Manage environment variables for your application.
+
+For docker compose apps, --service manages variables that only one service
+receives, e.g. a database password only the postgres service needs.`,
 }
 
 var envSetCmd = &cobra.Command{
 	Use:   "set [KEY=VALUE]...",
-	Short: "Set environment variables and redeploy",
-	Long: `Set environment variables for the application and trigger a redeployment/restart.
-This will update the .env file on the server and restart the application to apply changes.
+	Short: "Set environment variables and restart the app",
+	Long: `Set environment variables for the application and restart it to apply changes.
+The running release is restarted from its existing images with zero downtime.
+Use --rebuild to run a full redeploy instead.
 
 Example:
-  mushak env set DB_HOST=localhost DB_PORT=5432`,
+  mushak env set DB_HOST=localhost DB_PORT=5432
+  mushak env set --service worker QUEUE_CONCURRENCY=10`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: withTimer(runEnvSet),
 }
@@ -65,16 +71,101 @@ Example:
 	RunE: withTimer(runEnvDiff),
 }
 
+var envHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List previous versions of the environment file",
+	Long: `List the versions of the server's environment file, newest first, with the
+variables each version added, removed or changed. Values are masked.
+
+Every 'mushak env set', 'env push' and 'env rollback' records a version.
+
+Example:
+  mushak env history
+  mushak env history --limit 20`,
+	Args: cobra.NoArgs,
+	RunE: withTimer(runEnvHistory),
+}
+
+var envRollbackCmd = &cobra.Command{
+	Use:   "rollback [version]",
+	Short: "Restore a previous version of the environment file and restart the app",
+	Long: `Restore a version listed by 'mushak env history' and restart the app.
+The restored content is recorded as a new version, so a rollback can be undone.
+
+Example:
+  mushak env rollback 12`,
+	Args: cobra.ExactArgs(1),
+	RunE: withTimer(runEnvRollback),
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset [KEY]...",
+	Short: "Remove environment variables and restart the app",
+	Long: `Remove environment variables from the server's environment file and restart the app.
+Comments and the order of the remaining variables are preserved.
+
+Example:
+  mushak env unset DEBUG LEGACY_API_URL`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: withTimer(runEnvUnset),
+}
+
+var envListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List environment variables on the server",
+	Long: `List the variables in the server's environment file in file order.
+Values are masked unless --reveal is given.
+
+Example:
+  mushak env list
+  mushak env list --reveal`,
+	Args: cobra.NoArgs,
+	RunE: withTimer(runEnvList),
+}
+
+var envGetCmd = &cobra.Command{
+	Use:   "get [KEY]",
+	Short: "Print the value of an environment variable",
+	Long: `Print the raw value of a variable from the server's environment file.
+Only the value is written to stdout, so the command can be used in scripts.
+Exits with an error if the variable is not set.
+
+Example:
+  mushak env get DATABASE_URL
+  psql "$(mushak env get DATABASE_URL)"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEnvGet,
+}
+
 var envPushDeploy bool
-	
+var envHistoryLimit int
+var envListReveal bool
+var envService string
+var envRebuild bool
+
 func init() {
 	rootCmd.AddCommand(envCmd)
 	envCmd.AddCommand(envSetCmd)
 	envCmd.AddCommand(envPushCmd)
 	envCmd.AddCommand(envPullCmd)
 	envCmd.AddCommand(envDiffCmd)
+	envCmd.AddCommand(envHistoryCmd)
+	envCmd.AddCommand(envRollbackCmd)
+	envCmd.AddCommand(envUnsetCmd)
+	envCmd.AddCommand(envListCmd)
+	envCmd.AddCommand(envGetCmd)
+
+	envPushCmd.Flags().BoolVarP(&envPushDeploy, "deploy", "d", false, "Restart the app after pushing environment file")
+	envHistoryCmd.Flags().IntVarP(&envHistoryLimit, "limit", "n", 10, "Number of versions to show")
+	envListCmd.Flags().BoolVar(&envListReveal, "reveal", false, "Show values in plain text")
+
+	for _, c := range []*cobra.Command{envSetCmd, envUnsetCmd, envListCmd, envGetCmd, envPushCmd} {
+		c.Flags().StringVarP(&envService, "service", "s", "", "Compose service whose own env file to use")
+	}
 
-	envPushCmd.Flags().BoolVarP(&envPushDeploy, "deploy", "d", false, "Trigger a redeployment after pushing environment file")
+	for _, c := range []*cobra.Command{envSetCmd, envUnsetCmd, envRollbackCmd, envPushCmd} {
+		c.Flags().BoolVar(&envRebuild, "rebuild", false, "Run a full redeploy instead of restarting from existing images")
+	}
 }
 
 
@@ -89,6 +180,10 @@ func runEnvSet(cmd *cobra.Command, args []string) error {
 		updates[parts[0]] = parts[1]
 	}
 
+	if err := validateEnvService(); err != nil {
+		return err
+	}
+
 	// Load setup
 	cfg, err := config.LoadDeployConfig()
 	if err != nil {
@@ -98,6 +193,9 @@ func runEnvSet(cmd *cobra.Command, args []string) error {
 	ui.PrintHeader("Mushak Env Set")
 	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
 	ui.PrintKeyValue("App", cfg.AppName)
+	if envService != "" {
+		ui.PrintKeyValue("Service", envService)
+	}
 	println()
 
 	// Connect SSH
@@ -127,7 +225,15 @@ func runEnvSet(cmd *cobra.Command, args []string) error {
 	var targetPath string
 
 	// Check which env file exists, prefer .env.prod
-	if out, err := executor.Run("cat " + envProdPath); err == nil {
+	if envService != "" {
+		targetPath = server.ServiceEnvPath(cfg.AppName, envService)
+		if out, err := executor.Run("cat " + targetPath); err == nil {
+			currentContent = out
+			ui.PrintInfo(fmt.Sprintf("Using existing env file for %s", envService))
+		} else {
+			ui.PrintInfo(fmt.Sprintf("Creating new env file for %s", envService))
+		}
+	} else if out, err := executor.Run("cat " + envProdPath); err == nil {
 		currentContent = out
 		targetPath = envProdPath
 		ui.PrintInfo("Using existing .env.prod")
@@ -141,12 +247,24 @@ func runEnvSet(cmd *cobra.Command, args []string) error {
 		ui.PrintInfo("Creating new .env.prod")
 	}
 
-	newContent := updateEnvFile(currentContent, updates)
+	format := serverEnvFormat(targetPath)
+	if format == utils.EnvFormatDocker {
+		for k, v := range updates {
+			if err := utils.CheckDockerEnvValue(k, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	newContent, err := updateEnvFile(currentContent, updates, format)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", targetPath, err)
+	}
 
 	// Write back
 	ui.PrintInfo("Updating environment file...")
-	if err := executor.WriteFileSudo(targetPath, newContent); err != nil {
-		return fmt.Errorf("failed to write environment file: %w", err)
+	if err := server.WriteEnvFile(executor, cfg.AppName, targetPath, newContent, envAuthor()); err != nil {
+		return err
 	}
 	ui.PrintSuccess(fmt.Sprintf("Updated %s", targetPath))
 
@@ -171,81 +289,53 @@ func runEnvSet(cmd *cobra.Command, args []string) error {
 
 	// Update deployment hook (to ensure it supports .env)
 	ui.PrintInfo("Updating deployment hook...")
-	hookScript := hooks.GeneratePostReceiveHook(cfg.AppName, cfg.Domain, cfg.Branch, false, internalPort, healthPath, healthTimeout)
+	hookScript := hooks.GeneratePostReceiveHook(cfg.AppName, cfg.Domain, cfg.Branch, cfg.RootDir, cfg.SkipUnchanged, false, internalPort, healthPath, healthTimeout)
 	if err := server.InstallPostReceiveHook(executor, cfg.AppName, hookScript); err != nil {
 		return fmt.Errorf("failed to update hook: %w", err)
 	}
 	ui.PrintSuccess("Updated deployment hook")
 
-	// Trigger Redeploy
-	ui.PrintInfo("Triggering redeploy...")
-	if err := server.TriggerRedeploy(executor, cfg); err != nil {
+	// Apply the new environment
+	if err := applyEnvChanges(executor, cfg); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// updateEnvFile updates environment variables in the content string
-func updateEnvFile(content string, updates map[string]string) string {
-	lines := strings.Split(content, "\n")
-	// If the file ends with a newline, Split returns an empty string at the end.
-	// We want to process that only if it's not the only empty string of an empty file.
-	if len(lines) > 0 && lines[len(lines)-1] == "" {
-		lines = lines[:len(lines)-1]
-	}
-
-	seen := make(map[string]bool)
-	var newLines []string
-
-	// Process existing lines
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Keep empty lines and comments
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			newLines = append(newLines, line)
-			continue
-		}
-
-		// Check if line is KEY=VALUE
-		parts := strings.SplitN(trimmed, "=", 2)
-		if len(parts) == 2 {
-			key := parts[0]
-			if val, ok := updates[key]; ok {
-				newLines = append(newLines, fmt.Sprintf("%s=%s", key, val))
-				seen[key] = true
-			} else {
-				// Keep existing
-				newLines = append(newLines, line)
-			}
-		} else {
-			// Not a valid env line (maybe malformed), keep it
-			newLines = append(newLines, line)
-		}
+// updateEnvFile updates environment variables in the content string.
+// Existing variables are changed in place; new ones are appended in sorted order.
+func updateEnvFile(content string, updates map[string]string, format utils.EnvFormat) (string, error) {
+	f, err := utils.ParseEnv(content, format)
+	if err != nil {
+		return "", err
 	}
 
-	// Append new keys
-	var newKeys []string
+	keys := make([]string, 0, len(updates))
 	for k := range updates {
-		if !seen[k] {
-			newKeys = append(newKeys, k)
-		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		f.Set(k, updates[k])
 	}
-	sort.Strings(newKeys)
 
-	for _, k := range newKeys {
-		newLines = append(newLines, fmt.Sprintf("%s=%s", k, updates[k]))
+	return f.String(), nil
+}
+
+// unsetEnvVars removes environment variables from the content string
+func unsetEnvVars(content string, keys []string, format utils.EnvFormat) (string, error) {
+	f, err := utils.ParseEnv(content, format)
+	if err != nil {
+		return "", err
 	}
 
-	// Join with newlines
-	result := strings.Join(newLines, "\n")
-	// Ensure single trailing newline
-	if result != "" {
-		result += "\n"
+	for _, k := range keys {
+		f.Unset(k)
 	}
 
-	return result
+	return f.String(), nil
 }
 
 func runEnvPush(cmd *cobra.Command, args []string) error {
@@ -267,6 +357,10 @@ func runEnvPush(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if err := validateEnvService(); err != nil {
+		return err
+	}
+
 	// Load config
 	cfg, err := config.LoadDeployConfig()
 	if err != nil {
@@ -277,6 +371,9 @@ func runEnvPush(cmd *cobra.Command, args []string) error {
 	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
 	ui.PrintKeyValue("App", cfg.AppName)
 	ui.PrintKeyValue("Local file", envFile)
+	if envService != "" {
+		ui.PrintKeyValue("Service", envService)
+	}
 	println()
 
 	// Read file
@@ -285,8 +382,11 @@ func runEnvPush(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to read %s: %w", envFile, err)
 	}
 
-	// Show preview
-	count, _ := utils.CountEnvVars(envFile)
+	// Show preview (and refuse files the deploy could not read)
+	count, err := utils.CountEnvVars(envFile)
+	if err != nil {
+		return fmt.Errorf("failed to parse %w", err)
+	}
 	ui.PrintInfo(fmt.Sprintf("Uploading %d variable%s...", count, pluralizeEnv(count)))
 
 	// Connect SSH
@@ -314,7 +414,24 @@ func runEnvPush(cmd *cobra.Command, args []string) error {
 	}
 
 	targetPath := fmt.Sprintf("/var/www/%s/%s", cfg.AppName, targetFile)
-	if err := executor.WriteFileSudo(targetPath, string(content)); err != nil {
+	if envService != "" {
+		targetPath = server.ServiceEnvPath(cfg.AppName, envService)
+	}
+
+	// Local files are dotenv; Dockerfile apps need values docker run --env-file
+	// passes through unchanged
+	upload := string(content)
+	if serverEnvFormat(targetPath) == utils.EnvFormatDocker {
+		f, err := utils.ParseDotenv(upload)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", envFile, err)
+		}
+		if upload, err = f.Format(utils.EnvFormatDocker); err != nil {
+			return fmt.Errorf("%s: %w", envFile, err)
+		}
+	}
+
+	if err := server.WriteEnvFile(executor, cfg.AppName, targetPath, upload, envAuthor()); err != nil {
 		return fmt.Errorf("failed to upload: %w", err)
 	}
 
@@ -323,7 +440,7 @@ func runEnvPush(cmd *cobra.Command, args []string) error {
 	println()
 
 	if envPushDeploy {
-		if err := server.TriggerRedeploy(executor, cfg); err != nil {
+		if err := applyEnvChanges(executor, cfg); err != nil {
 			return err
 		}
 	} else {
@@ -380,6 +497,13 @@ func runEnvPull(cmd *cobra.Command, args []string) error {
 
 	ui.PrintInfo(fmt.Sprintf("Downloading from %s...", sourcePath))
 
+	// Local files are dotenv, so quote the literal values of Dockerfile apps
+	if serverEnvFormat(sourcePath) == utils.EnvFormatDocker {
+		if content, err = utils.ParseDockerEnv(content).Format(utils.EnvFormatDotenv); err != nil {
+			return err
+		}
+	}
+
 	// Write to local .env.prod
 	localPath := ".env.prod"
 	if err := os.WriteFile(localPath, []byte(content), 0600); err != nil {
@@ -394,8 +518,9 @@ func runEnvPull(cmd *cobra.Command, args []string) error {
 
 func runEnvDiff(cmd *cobra.Command, args []string) error {
 	// Detect local env file
-	localFile, err := utils.DetectLocalEnvFile()
-	if err != nil {
+	localFile, localErr := utils.DetectLocalEnvFile()
+	_, secretsErr := os.Stat(utils.SecretsFile)
+	if localErr != nil && secretsErr != nil {
 		return fmt.Errorf("no local environment file found")
 	}
 
@@ -406,14 +531,31 @@ func runEnvDiff(cmd *cobra.Command, args []string) error {
 	}
 
 	ui.PrintHeader("Mushak Env Diff")
-	ui.PrintKeyValue("Local", localFile)
+	if localErr == nil {
+		ui.PrintKeyValue("Local", localFile)
+	}
+	if secretsErr == nil {
+		ui.PrintKeyValue("Secrets", utils.SecretsFile)
+	}
 	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s (%s)", cfg.User, cfg.Host, cfg.AppName))
 	println()
 
 	// Read local
-	localVars, err := utils.ParseEnvFile(localFile)
-	if err != nil {
-		return fmt.Errorf("failed to parse local file: %w", err)
+	var localVars map[string]string
+	if localErr == nil {
+		localVars, err = utils.ParseEnvFile(localFile)
+		if err != nil {
+			return fmt.Errorf("failed to parse local file: %w", err)
+		}
+	}
+
+	// Secrets are decrypted locally with the team key, on both sides
+	var secretsKey string
+	if secretsErr == nil {
+		secretsKey, err = utils.LoadSecretsKey()
+		if err != nil {
+			return err
+		}
 	}
 
 	// Connect and read remote
@@ -432,33 +574,80 @@ func runEnvDiff(cmd *cobra.Command, args []string) error {
 
 	executor := ssh.NewExecutor(client)
 
-	// Try .env.prod first, then .env
-	envProdPath := fmt.Sprintf("/var/www/%s/.env.prod", cfg.AppName)
-	envPath := fmt.Sprintf("/var/www/%s/.env", cfg.AppName)
+	hasChanges := false
 
-	var remoteContent string
-	if out, err := executor.Run(fmt.Sprintf("cat %s", envProdPath)); err == nil {
-		remoteContent = out
-	} else if out, err := executor.Run(fmt.Sprintf("cat %s", envPath)); err == nil {
-		remoteContent = out
-	} else {
-		return fmt.Errorf("no environment file found on server")
+	if localErr == nil {
+		// Try .env.prod first, then .env
+		envProdPath := fmt.Sprintf("/var/www/%s/.env.prod", cfg.AppName)
+		envPath := fmt.Sprintf("/var/www/%s/.env", cfg.AppName)
+
+		var remoteContent, remotePath string
+		if out, err := executor.Run(fmt.Sprintf("cat %s", envProdPath)); err == nil {
+			remoteContent, remotePath = out, envProdPath
+		} else if out, err := executor.Run(fmt.Sprintf("cat %s", envPath)); err == nil {
+			remoteContent, remotePath = out, envPath
+		} else {
+			return fmt.Errorf("no environment file found on server")
+		}
+
+		remote, err := utils.ParseEnv(remoteContent, serverEnvFormat(remotePath))
+		if err != nil {
+			return fmt.Errorf("failed to parse server environment file: %w", err)
+		}
+
+		hasChanges = printEnvDiff(localVars, remote.Vars()) || hasChanges
 	}
 
-	// Parse remote
-	remoteVars := make(map[string]string)
-	for _, line := range strings.Split(remoteContent, "\n") {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	if secretsErr == nil {
+		localSecrets, err := utils.ReadSecretsFile(utils.SecretsFile, secretsKey)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", utils.SecretsFile, err)
 		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			remoteVars[parts[0]] = parts[1]
+
+		// Compare with the secrets of the running release
+		remoteSecrets := ""
+		if out, err := executor.Run(fmt.Sprintf("cat /var/www/%s/current/%s", cfg.AppName, utils.SecretsFile)); err == nil {
+			decrypted, err := utils.DecryptSecrets([]byte(out), secretsKey)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt deployed secrets: %w", err)
+			}
+			remoteSecrets = string(decrypted)
+		}
+
+		if localErr == nil {
+			println()
+		}
+		ui.PrintInfo("Secrets (local vs deployed):")
+		localSecretVars, err := utils.ParseEnvContent(localSecrets)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", utils.SecretsFile, err)
+		}
+		remoteSecretVars, err := utils.ParseEnvContent(remoteSecrets)
+		if err != nil {
+			return fmt.Errorf("failed to parse deployed secrets: %w", err)
+		}
+
+		hasChanges = printEnvDiff(localSecretVars, remoteSecretVars) || hasChanges
+	}
+
+	if !hasChanges {
+		ui.PrintSuccess("No differences found")
+	} else {
+		println()
+		if localErr == nil {
+			ui.PrintInfo("Use 'mushak env push' to upload local changes")
+			ui.PrintInfo("Use 'mushak env pull' to download server version")
+		}
+		if secretsErr == nil {
+			ui.PrintInfo("Commit secrets.env.enc and run 'mushak deploy' to apply secret changes")
 		}
 	}
 
-	// Compare
+	return nil
+}
+
+// printEnvDiff prints the keys that differ between local and remote variables
+func printEnvDiff(localVars, remoteVars map[string]string) bool {
 	allKeys := make(map[string]bool)
 	for k := range localVars {
 		allKeys[k] = true
@@ -490,17 +679,479 @@ func runEnvDiff(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if !hasChanges {
-		ui.PrintSuccess("No differences found")
-	} else {
-		println()
-		ui.PrintInfo("Use 'mushak env push' to upload local changes")
-		ui.PrintInfo("Use 'mushak env pull' to download server version")
+	return hasChanges
+}
+
+func runEnvHistory(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	ui.PrintHeader("Mushak Env History")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+	println()
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
 	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	versions, err := server.ListEnvVersions(executor, cfg.AppName)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		ui.PrintInfo("No env history yet. Versions are recorded by 'mushak env set' and 'mushak env push'")
+		return nil
+	}
+
+	// Show the newest versions, diffing each against the one before it
+	start := 0
+	if envHistoryLimit > 0 && len(versions) > envHistoryLimit {
+		start = len(versions) - envHistoryLimit
+	}
+
+	// Each version is diffed against the previous version of the same file
+	// (the app's env file and per-service files have separate histories)
+	latest := make(map[string]int)
+	for i := 0; i < start; i++ {
+		latest[versions[i].File] = i
+	}
+
+	previous := make(map[string]map[string]string)
+	for file, i := range latest {
+		if content, err := server.ReadEnvVersion(executor, cfg.AppName, versions[i].ID); err == nil {
+			if f, err := utils.ParseEnv(content, serverEnvFormat(file)); err == nil {
+				previous[file] = f.Vars()
+			}
+		}
+	}
+
+	diffs := make([][]string, len(versions))
+	for i := start; i < len(versions); i++ {
+		content, err := server.ReadEnvVersion(executor, cfg.AppName, versions[i].ID)
+		if err != nil {
+			return err
+		}
+		f, err := utils.ParseEnv(content, serverEnvFormat(versions[i].File))
+		if err != nil {
+			return fmt.Errorf("failed to parse env version %s: %w", versions[i].ID, err)
+		}
+		current := f.Vars()
+		diffs[i] = formatEnvVersionDiff(previous[versions[i].File], current)
+		previous[versions[i].File] = current
+		latest[versions[i].File] = i
+	}
+
+	for i := len(versions) - 1; i >= start; i-- {
+		v := versions[i]
+		label := v.ID
+		if i == latest[v.File] {
+			label += " (current)"
+		}
+
+		recorded := "unknown time"
+		if !v.Time.IsZero() {
+			recorded = v.Time.Local().Format("2006-01-02 15:04:05")
+		}
+
+		fmt.Printf("%s  %s  %s  %s\n",
+			ui.BoldCyan(label),
+			ui.Muted(recorded),
+			v.Author,
+			ui.Muted(v.File),
+		)
+		for _, line := range diffs[i] {
+			fmt.Printf("    %s\n", line)
+		}
+		if len(diffs[i]) == 0 {
+			fmt.Printf("    %s\n", ui.Muted("no variable changes"))
+		}
+	}
+
+	println()
+	ui.PrintInfo("Use 'mushak env rollback <version>' to restore a version")
 
 	return nil
 }
 
+// formatEnvVersionDiff describes the variables changed between two versions, with masked values
+func formatEnvVersionDiff(before, after map[string]string) []string {
+	added, removed, changed := utils.DiffEnvVars(before, after)
+
+	var lines []string
+	for _, key := range added {
+		lines = append(lines, ui.Success(fmt.Sprintf("+ %s=%s", key, utils.MaskEnvValue(after[key]))))
+	}
+	for _, key := range removed {
+		lines = append(lines, ui.Error(fmt.Sprintf("- %s", key)))
+	}
+	for _, key := range changed {
+		lines = append(lines, ui.Warning(fmt.Sprintf("≠ %s=%s", key, utils.MaskEnvValue(after[key]))))
+	}
+	return lines
+}
+
+func runEnvRollback(cmd *cobra.Command, args []string) error {
+	id := args[0]
+
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	ui.PrintHeader("Mushak Env Rollback")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+	ui.PrintKeyValue("Version", id)
+	println()
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	versions, err := server.ListEnvVersions(executor, cfg.AppName)
+	if err != nil {
+		return err
+	}
+
+	var target *server.EnvVersion
+	for i := range versions {
+		if versions[i].ID == id {
+			target = &versions[i]
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("env version %s not found. Run 'mushak env history' to list versions", id)
+	}
+
+	content, err := server.ReadEnvVersion(executor, cfg.AppName, id)
+	if err != nil {
+		return err
+	}
+
+	targetPath := fmt.Sprintf("/var/www/%s/%s", cfg.AppName, target.File)
+
+	// Show what the rollback changes compared to the current file
+	format := serverEnvFormat(targetPath)
+	restored, err := utils.ParseEnv(content, format)
+	if err != nil {
+		return fmt.Errorf("failed to parse env version %s: %w", id, err)
+	}
+	currentVars := map[string]string{}
+	if current, err := executor.Run("cat " + targetPath); err == nil {
+		if f, err := utils.ParseEnv(current, format); err == nil {
+			currentVars = f.Vars()
+		}
+	}
+	changes := formatEnvVersionDiff(currentVars, restored.Vars())
+	if len(changes) == 0 {
+		ui.PrintInfo("Version matches the current environment file")
+	}
+	for _, line := range changes {
+		fmt.Println(line)
+	}
+	println()
+
+	ui.PrintInfo(fmt.Sprintf("Restoring %s...", target.File))
+	if err := server.WriteEnvFile(executor, cfg.AppName, targetPath, content, fmt.Sprintf("%s (rollback to %s)", envAuthor(), id)); err != nil {
+		return err
+	}
+	ui.PrintSuccess(fmt.Sprintf("Restored version %s", id))
+
+	return applyEnvChanges(executor, cfg)
+}
+
+// envAuthor identifies who changed the environment, from git config or the local user
+func envAuthor() string {
+	name, _ := exec.Command("git", "config", "user.name").Output()
+	email, _ := exec.Command("git", "config", "user.email").Output()
+
+	author := strings.TrimSpace(string(name))
+	if e := strings.TrimSpace(string(email)); e != "" {
+		if author == "" {
+			author = e
+		} else {
+			author = fmt.Sprintf("%s <%s>", author, e)
+		}
+	}
+
+	if author == "" {
+		author = os.Getenv("USER")
+	}
+	if author == "" {
+		author = "unknown"
+	}
+	return author
+}
+
+func runEnvUnset(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	if err := validateEnvService(); err != nil {
+		return err
+	}
+
+	ui.PrintHeader("Mushak Env Unset")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+	if envService != "" {
+		ui.PrintKeyValue("Service", envService)
+	}
+	println()
+
+	executor, client, err := connectEnv(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	content, targetPath, err := readServerEnvFile(executor, cfg.AppName, envService)
+	if err != nil {
+		return err
+	}
+
+	f, err := utils.ParseEnv(content, serverEnvFormat(targetPath))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", targetPath, err)
+	}
+	vars := f.Vars()
+
+	var keys []string
+	for _, key := range args {
+		if _, ok := vars[key]; ok {
+			keys = append(keys, key)
+		} else {
+			ui.PrintWarning(fmt.Sprintf("%s is not set", key))
+		}
+	}
+
+	if len(keys) == 0 {
+		ui.PrintInfo("Nothing to remove")
+		return nil
+	}
+
+	ui.PrintInfo("Updating environment file...")
+	newContent, err := unsetEnvVars(content, keys, serverEnvFormat(targetPath))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", targetPath, err)
+	}
+	if err := server.WriteEnvFile(executor, cfg.AppName, targetPath, newContent, envAuthor()); err != nil {
+		return err
+	}
+	ui.PrintSuccess(fmt.Sprintf("Removed %s from %s", strings.Join(keys, ", "), targetPath))
+
+	return applyEnvChanges(executor, cfg)
+}
+
+func runEnvList(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	if err := validateEnvService(); err != nil {
+		return err
+	}
+
+	ui.PrintHeader("Mushak Env List")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+
+	executor, client, err := connectEnv(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	content, targetPath, err := readServerEnvFile(executor, cfg.AppName, envService)
+	if err != nil {
+		return err
+	}
+	ui.PrintKeyValue("File", targetPath)
+	println()
+
+	f, err := utils.ParseEnv(content, serverEnvFormat(targetPath))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", targetPath, err)
+	}
+
+	keys := f.Keys()
+	if len(keys) == 0 {
+		ui.PrintInfo("No variables set. Use 'mushak env set KEY=VALUE' to add one")
+		return nil
+	}
+
+	vars := f.Vars()
+	for _, key := range keys {
+		value := vars[key]
+		if !envListReveal {
+			value = utils.MaskEnvValue(value)
+		}
+		fmt.Printf("%s=%s\n", ui.Bold(key), value)
+	}
+	println()
+
+	return nil
+}
+
+func runEnvGet(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	if err := validateEnvService(); err != nil {
+		return err
+	}
+
+	executor, client, err := connectEnv(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	content, targetPath, err := readServerEnvFile(executor, cfg.AppName, envService)
+	if err != nil {
+		return err
+	}
+
+	f, err := utils.ParseEnv(content, serverEnvFormat(targetPath))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", targetPath, err)
+	}
+
+	value, ok := f.Get(args[0])
+	if !ok {
+		return fmt.Errorf("%s is not set", args[0])
+	}
+
+	fmt.Println(value)
+	return nil
+}
+
+// applyEnvChanges restarts the app with the new environment without rebuilding,
+// or runs a full redeploy with --rebuild
+func applyEnvChanges(executor *ssh.Executor, cfg *config.DeployConfig) error {
+	if envRebuild {
+		ui.PrintInfo("Triggering redeploy...")
+		return server.TriggerRedeploy(executor, cfg)
+	}
+
+	ui.PrintInfo("Restarting with the new environment...")
+	return server.RestartWithEnv(executor, cfg)
+}
+
+// connectEnv opens an SSH connection to the app's server
+func connectEnv(cfg *config.DeployConfig) (*ssh.Executor, *ssh.Client, error) {
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	return ssh.NewExecutor(client), client, nil
+}
+
+// readServerEnvFile returns the content and path of the app's env file, preferring .env.prod,
+// or of the service's own env file if service is set
+func readServerEnvFile(executor *ssh.Executor, appName, service string) (string, string, error) {
+	if service != "" {
+		path := server.ServiceEnvPath(appName, service)
+		if out, err := executor.Run("cat " + path); err == nil {
+			return out, path, nil
+		}
+		return "", "", fmt.Errorf("no environment file for service %s on server", service)
+	}
+
+	for _, name := range []string{".env.prod", ".env"} {
+		path := fmt.Sprintf("/var/www/%s/%s", appName, name)
+		if out, err := executor.Run("cat " + path); err == nil {
+			return out, path, nil
+		}
+	}
+	return "", "", fmt.Errorf("no environment file found on server")
+}
+
+// serverEnvFormat returns the format the deploy reads an env file on the
+// server with. Per-service files and the env file of docker compose apps are
+// read by compose; Dockerfile, image and static apps pass the env file to
+// docker run --env-file, which takes values literally.
+func serverEnvFormat(path string) utils.EnvFormat {
+	if strings.Contains(path, ".env.d/") {
+		return utils.EnvFormatDotenv
+	}
+
+	appCfg, _ := config.LoadConfig("mushak.yaml")
+	if appCfg != nil && (appCfg.Image != "" || appCfg.IsStatic()) {
+		return utils.EnvFormatDocker
+	}
+	if utils.HasComposeFile() {
+		return utils.EnvFormatDotenv
+	}
+	return utils.EnvFormatDocker
+}
+
+// validateEnvService checks the --service flag against the local compose file
+func validateEnvService() error {
+	if envService == "" {
+		return nil
+	}
+
+	if err := server.ValidateServiceName(envService); err != nil {
+		return err
+	}
+
+	services, err := utils.ComposeServices()
+	if err != nil {
+		ui.PrintWarning(fmt.Sprintf("Could not check service name: %v", err))
+		return nil
+	}
+	if services == nil {
+		return fmt.Errorf("--service requires a docker-compose.yml")
+	}
+
+	for _, s := range services {
+		if s == envService {
+			return nil
+		}
+	}
+	return fmt.Errorf("service %s not found in docker-compose.yml. Available: %s", envService, strings.Join(services, ", "))
+}
+
 func pluralizeEnv(count int) string {
 	if count == 1 {
 		return ""
@@ -2,7 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"testing"
+
+	"github.com/hmontazeri/mushak/internal/utils"
+	"github.com/spf13/cobra"
 )
 
 func TestUpdateEnvFile(t *testing.T) {
@@ -52,7 +57,10 @@ func TestUpdateEnvFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := updateEnvFile(tt.content, tt.updates)
+			got, err := updateEnvFile(tt.content, tt.updates, utils.EnvFormatDotenv)
+			if err != nil {
+				t.Fatalf("updateEnvFile() error = %v", err)
+			}
 			if got != tt.expected {
 				t.Errorf("updateEnvFile() = %q, want %q", got, tt.expected)
 			}
@@ -96,3 +104,178 @@ func TestEnvPushCommandFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestEnvHistoryCommands(t *testing.T) {
+	if envHistoryCmd.Flags().Lookup("limit") == nil {
+		t.Error("env history command should have --limit flag")
+	}
+
+	if envRollbackCmd.Args == nil {
+		t.Error("env rollback command should validate its arguments")
+	} else if err := envRollbackCmd.Args(envRollbackCmd, []string{}); err == nil {
+		t.Error("env rollback should require a version")
+	}
+}
+
+func TestFormatEnvVersionDiff(t *testing.T) {
+	before := map[string]string{"KEEP": "same", "DATABASE_URL": "postgres://old", "OLD": "x"}
+	after := map[string]string{"KEEP": "same", "DATABASE_URL": "postgres://new", "NEW": "secret-value"}
+
+	lines := formatEnvVersionDiff(before, after)
+	if len(lines) != 3 {
+		t.Fatalf("formatEnvVersionDiff() returned %d lines, want 3: %v", len(lines), lines)
+	}
+
+	for _, line := range lines {
+		if strings.Contains(line, "secret-value") || strings.Contains(line, "postgres://new") {
+			t.Errorf("formatEnvVersionDiff() leaked a value: %q", line)
+		}
+	}
+}
+
+func TestUnsetEnvVars(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		keys     []string
+		expected string
+	}{
+		{
+			name:     "remove one",
+			content:  "A=1\nB=2\nC=3\n",
+			keys:     []string{"B"},
+			expected: "A=1\nC=3\n",
+		},
+		{
+			name:     "preserve comments and order",
+			content:  "# database\nDB_HOST=db\nDB_PORT=5432\n\n# app\nDEBUG=true\nPORT=3000\n",
+			keys:     []string{"DEBUG", "DB_PORT"},
+			expected: "# database\nDB_HOST=db\n\n# app\nPORT=3000\n",
+		},
+		{
+			name:     "missing key",
+			content:  "A=1\n",
+			keys:     []string{"B"},
+			expected: "A=1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unsetEnvVars(tt.content, tt.keys, utils.EnvFormatDotenv)
+			if err != nil {
+				t.Fatalf("unsetEnvVars() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("unsetEnvVars() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnvVariableCommands(t *testing.T) {
+	if envListCmd.Flags().Lookup("reveal") == nil {
+		t.Error("env list command should have --reveal flag")
+	}
+
+	if err := envUnsetCmd.Args(envUnsetCmd, []string{}); err == nil {
+		t.Error("env unset should require at least one key")
+	}
+
+	if err := envGetCmd.Args(envGetCmd, []string{"A", "B"}); err == nil {
+		t.Error("env get should accept exactly one key")
+	}
+
+	if envGetCmd.Use != "get [KEY]" {
+		t.Errorf("envGetCmd.Use = %v, want get [KEY]", envGetCmd.Use)
+	}
+}
+
+func TestEnvServiceFlag(t *testing.T) {
+	for _, c := range []*cobra.Command{envSetCmd, envUnsetCmd, envListCmd, envGetCmd, envPushCmd} {
+		if c.Flags().Lookup("service") == nil {
+			t.Errorf("env %s should have --service flag", c.Name())
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { envService = "" }()
+
+	envService = "worker"
+	if err := validateEnvService(); err == nil {
+		t.Error("validateEnvService() should fail without a compose file")
+	}
+
+	compose := "services:\n  web:\n    build: .\n  worker:\n    build: .\n"
+	if err := os.WriteFile("docker-compose.yml", []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := validateEnvService(); err != nil {
+		t.Errorf("validateEnvService() error = %v", err)
+	}
+
+	envService = "postgres"
+	if err := validateEnvService(); err == nil {
+		t.Error("validateEnvService() should fail for a service not in the compose file")
+	}
+
+	envService = "../web"
+	if err := validateEnvService(); err == nil {
+		t.Error("validateEnvService() should reject invalid names")
+	}
+}
+
+func TestEnvRebuildFlag(t *testing.T) {
+	for _, c := range []*cobra.Command{envSetCmd, envUnsetCmd, envRollbackCmd, envPushCmd} {
+		if c.Flags().Lookup("rebuild") == nil {
+			t.Errorf("env %s should have --rebuild flag", c.Name())
+		}
+	}
+}
+
+func TestUpdateEnvFile_Docker(t *testing.T) {
+	content := "# app\nA=it's # raw\n"
+	got, err := updateEnvFile(content, map[string]string{"B": "p$ss 'word'"}, utils.EnvFormatDocker)
+	if err != nil {
+		t.Fatalf("updateEnvFile() error = %v", err)
+	}
+
+	want := "# app\nA=it's # raw\nB=p$ss 'word'\n"
+	if got != want {
+		t.Errorf("updateEnvFile() = %q, want %q", got, want)
+	}
+}
+
+func TestServerEnvFormat(t *testing.T) {
+	dir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldWd)
+
+	if got := serverEnvFormat("/var/www/app/.env.prod"); got != utils.EnvFormatDocker {
+		t.Errorf("Dockerfile app: serverEnvFormat() = %v, want docker", got)
+	}
+	if got := serverEnvFormat("/var/www/app/.env.d/worker.env"); got != utils.EnvFormatDotenv {
+		t.Errorf("service file: serverEnvFormat() = %v, want dotenv", got)
+	}
+
+	os.WriteFile("docker-compose.yml", []byte("services:\n  web: {}\n"), 0644)
+	if got := serverEnvFormat("/var/www/app/.env.prod"); got != utils.EnvFormatDotenv {
+		t.Errorf("compose app: serverEnvFormat() = %v, want dotenv", got)
+	}
+
+	os.WriteFile("mushak.yaml", []byte("image: ghcr.io/acme/app:1.0\n"), 0644)
+	if got := serverEnvFormat("/var/www/app/.env.prod"); got != utils.EnvFormatDocker {
+		t.Errorf("image app: serverEnvFormat() = %v, want docker", got)
+	}
+}
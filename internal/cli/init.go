@@ -29,6 +29,9 @@ var initCmd = &cobra.Command{
 Usage:
   mushak init USER@HOST
 
+Run it in an app's subdirectory to deploy only that directory of a monorepo.
+Each app gets its own remote, mushak-<app>.
+
 Example:
   mushak init root@192.168.1.100`,
 	RunE: withTimer(runInit),
@@ -42,6 +45,9 @@ var (
 	initBranch string
 	initKey    string
 	initPort   string
+
+	initSkipUnchanged      bool
+	initGenerateDockerfile bool
 )
 
 func init() {
@@ -54,6 +60,8 @@ func init() {
 	initCmd.Flags().StringVar(&initBranch, "branch", "main", "Git branch to deploy")
 	initCmd.Flags().StringVar(&initKey, "key", "", "SSH key path (default: ~/.ssh/id_rsa)")
 	initCmd.Flags().StringVar(&initPort, "port", "22", "SSH port")
+	initCmd.Flags().BoolVar(&initSkipUnchanged, "skip-unchanged", false, "Skip deploys that change nothing in the app's directory (monorepos)")
+	initCmd.Flags().BoolVar(&initGenerateDockerfile, "generate-dockerfile", false, "Write a Dockerfile for the project's stack to review and commit")
 }
 
 func runInit(cmd *cobra.Command, args []string) error {
@@ -85,6 +93,15 @@ func runInit(cmd *cobra.Command, args []string) error {
 	}
 	defaultApp := filepath.Base(cwd)
 
+	// In a subdirectory, the app is that directory of a monorepo
+	rootDir, err := gitPrefix()
+	if err != nil {
+		return err
+	}
+	if initSkipUnchanged && rootDir == "" {
+		return fmt.Errorf("--skip-unchanged needs mushak init to run in the app's subdirectory")
+	}
+
 	// Prompt for domain if not provided
 	if initDomain == "" {
 		domain, err := utils.PromptString("Domain", "")
@@ -124,8 +141,18 @@ func runInit(cmd *cobra.Command, args []string) error {
 	ui.PrintKeyValue("App", initApp)
 	ui.PrintKeyValue("Domain", initDomain)
 	ui.PrintKeyValue("Branch", initBranch)
+	if rootDir != "" {
+		ui.PrintKeyValue("Directory", rootDir)
+	}
 	println()
 
+	if initGenerateDockerfile {
+		if err := writeGeneratedDockerfile(); err != nil {
+			return err
+		}
+		println()
+	}
+
 	// Create SSH client
 	ui.PrintInfo("Connecting to server...")
 	sshClient, err := ssh.NewClient(ssh.Config{
@@ -166,7 +193,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 	println()
 
 	// Generate and install post-receive hook
-	hookScript := hooks.GeneratePostReceiveHook(initApp, initDomain, initBranch, false, 0, "", 0)
+	hookScript := hooks.GeneratePostReceiveHook(initApp, initDomain, initBranch, rootDir, initSkipUnchanged, false, 0, "", 0)
 	if err := server.InstallPostReceiveHook(executor, initApp, hookScript); err != nil {
 		return fmt.Errorf("failed to install post-receive hook: %w", err)
 	}
@@ -190,8 +217,11 @@ func runInit(cmd *cobra.Command, args []string) error {
 		ui.PrintSuccess(fmt.Sprintf("Uploaded %s to server", envFile))
 	}
 
-	// Add Git remote
+	// Add Git remote. Apps sharing a monorepo need one each
 	remoteName := "mushak"
+	if rootDir != "" {
+		remoteName = "mushak-" + initApp
+	}
 	remoteURL := fmt.Sprintf("ssh://%s@%s:%s/var/repo/%s.git", initUser, initHost, initPort, initApp)
 
 	ui.PrintInfo(fmt.Sprintf("Adding Git remote '%s'...", remoteName))
@@ -223,19 +253,26 @@ func runInit(cmd *cobra.Command, args []string) error {
 		Domain:     initDomain,
 		Branch:     initBranch,
 		RemoteName: remoteName,
+
+		RootDir:       rootDir,
+		SkipUnchanged: initSkipUnchanged,
 	}
 
 	if err := config.SaveDeployConfig(deployConfig); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
-	// Detect internal port and create mushak.yaml if needed
-	internalPort := utils.DetectInternalPort()
+	// Detect internal port and create mushak.yaml if needed, honoring the
+	// Dockerfile and target stage from an existing mushak.yaml
+	appCfg, err := config.LoadConfig("mushak.yaml")
+	if err != nil {
+		appCfg = config.DefaultConfig()
+	}
+	internalPort := utils.DetectInternalPort(appCfg.Build.DockerfilePath(), appCfg.Build.Target)
 	if internalPort > 0 && internalPort != 80 {
 		ui.PrintInfo(fmt.Sprintf("Detected internal port: %d", internalPort))
 		confirmed, err := utils.Confirm(fmt.Sprintf("→ Use %d as internal port?", internalPort))
 		if err == nil && confirmed {
-			appCfg := config.DefaultConfig()
 			appCfg.InternalPort = internalPort
 			if err := config.SaveAppConfig(appCfg); err != nil {
 				ui.PrintWarning(fmt.Sprintf("Failed to save mushak.yaml: %v", err))
@@ -245,6 +282,12 @@ func runInit(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Projects without a Dockerfile are built with a generated one
+	if _, stack, err := generatedDockerfile(".", appCfg.Build); err == nil && stack != "" {
+		ui.PrintInfo(fmt.Sprintf("No Dockerfile found. Deploys build this %s project with a generated one", stack.Name()))
+		ui.PrintInfo("Run 'mushak init --generate-dockerfile' to review it and commit it")
+	}
+
 	// Success message
 	println()
 	ui.PrintSeparator()
@@ -261,11 +304,61 @@ func runInit(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// writeGeneratedDockerfile writes a Dockerfile for the project's stack where
+// mushak.yaml expects one, so it can be reviewed and committed
+func writeGeneratedDockerfile() error {
+	appCfg, err := config.LoadConfig("mushak.yaml")
+	if err != nil {
+		return err
+	}
+
+	dockerfile := appCfg.Build.DockerfilePath()
+	for _, name := range []string{dockerfile, "docker-compose.yml", "docker-compose.yaml"} {
+		if _, err := os.Stat(name); err == nil {
+			return fmt.Errorf("%s already exists, no Dockerfile generated", name)
+		}
+	}
+
+	content, stack, err := generatedDockerfile(".", appCfg.Build)
+	if err != nil {
+		return err
+	}
+	if stack == "" {
+		return fmt.Errorf("no Dockerfile can be generated: found no package.json, go.mod, requirements.txt, pyproject.toml or index.html in %s", appCfg.Build.ContextPath())
+	}
+
+	if err := os.WriteFile(dockerfile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dockerfile, err)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Generated %s for a %s project. Review it and commit it", dockerfile, stack.Name()))
+
+	// Its COPY . takes the whole context, including local env files
+	context := appCfg.Build.ContextPath()
+	changed, err := utils.AddGeneratedDockerignore(context)
+	if err != nil {
+		return err
+	}
+	if changed {
+		ui.PrintSuccess(fmt.Sprintf("Added env files and secrets to %s, so they stay out of the image", filepath.Join(context, ".dockerignore")))
+	}
+	return nil
+}
+
 func isGitRepo() bool {
 	cmd := exec.Command("git", "rev-parse", "--git-dir")
 	return cmd.Run() == nil
 }
 
+// gitPrefix returns the current directory relative to the repository root,
+// "" at the root
+func gitPrefix() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the repository root: %w", err)
+	}
+	return strings.TrimSuffix(strings.TrimSpace(string(out)), "/"), nil
+}
+
 // detectAndUploadEnvFile detects local env file and prompts user to upload
 func detectAndUploadEnvFile(executor *ssh.Executor, appName string) (string, error) {
 	envFile, err := detectLocalEnvFileWithFallback()
@@ -320,7 +413,7 @@ func detectAndUploadEnvFile(executor *ssh.Executor, appName string) (string, err
 	}
 
 	targetPath := fmt.Sprintf("/var/www/%s/%s", appName, targetFile)
-	if err := executor.WriteFileSudo(targetPath, string(content)); err != nil {
+	if err := server.WriteEnvFile(executor, appName, targetPath, string(content), envAuthor()); err != nil {
 		return "", fmt.Errorf("failed to upload environment file: %w", err)
 	}
 
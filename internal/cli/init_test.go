@@ -44,6 +44,8 @@ func TestInitCommandFlags(t *testing.T) {
 		{name: "branch", required: false},
 		{name: "key", required: false},
 		{name: "port", required: false},
+		{name: "skip-unchanged", required: false},
+		{name: "generate-dockerfile", required: false},
 	}
 
 	for _, flag := range requiredFlags {
@@ -213,6 +215,17 @@ func TestIsGitRepo(t *testing.T) {
 	// We can't fully test this without a git repository context
 }
 
+func TestGitPrefix(t *testing.T) {
+	// Tests run in internal/cli of this repository
+	prefix, err := gitPrefix()
+	if err != nil {
+		t.Fatalf("gitPrefix() error = %v", err)
+	}
+	if prefix != "internal/cli" {
+		t.Errorf("gitPrefix() = %q, want internal/cli", prefix)
+	}
+}
+
 func TestInitCommandRequiredFlags(t *testing.T) {
 	if initCmd == nil {
 		t.Fatal("initCmd should not be nil")
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/server"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance [on|off]",
+	Short: "Toggle maintenance mode for the application",
+	Long: `Take the application offline gracefully by serving a static maintenance page
+with a 503 status from Caddy. Containers keep running, so switching back is instant.
+Maintenance mode stays on across deployments until you turn it off.
+
+Without arguments, shows whether maintenance mode is enabled.
+
+Examples:
+  mushak maintenance on
+  mushak maintenance on --message "Upgrading the database, back at 14:00"
+  mushak maintenance on --page maintenance.html --allow 203.0.113.7
+  mushak maintenance off`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"on", "off"},
+	RunE:      withTimer(runMaintenance),
+}
+
+var (
+	maintenanceMessage string
+	maintenancePage    string
+	maintenanceAllow   []string
+)
+
+func init() {
+	rootCmd.AddCommand(maintenanceCmd)
+
+	maintenanceCmd.Flags().StringVarP(&maintenanceMessage, "message", "m", "", "Message shown on the default maintenance page")
+	maintenanceCmd.Flags().StringVar(&maintenancePage, "page", "", "Custom HTML file to serve as the maintenance page")
+	maintenanceCmd.Flags().StringSliceVar(&maintenanceAllow, "allow", nil, "IP addresses or CIDR ranges that bypass maintenance mode")
+	maintenanceCmd.MarkFlagsMutuallyExclusive("message", "page")
+}
+
+func runMaintenance(cmd *cobra.Command, args []string) error {
+	action := ""
+	if len(args) > 0 {
+		action = args[0]
+		if action != "on" && action != "off" {
+			return fmt.Errorf("invalid argument: %s. Must be 'on' or 'off'", action)
+		}
+	}
+
+	// Prepare the page before connecting so a bad path fails fast
+	var page string
+	if action == "on" && maintenancePage != "" {
+		content, err := os.ReadFile(maintenancePage)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", maintenancePage, err)
+		}
+		page = string(content)
+	}
+
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	if action == "on" && page == "" {
+		page = server.GenerateMaintenancePage(cfg.AppName, maintenanceMessage)
+	}
+
+	ui.PrintHeader("Mushak Maintenance")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+	println()
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	switch action {
+	case "on":
+		if err := server.EnableMaintenance(executor, cfg.AppName, page, maintenanceAllow); err != nil {
+			return err
+		}
+		if len(maintenanceAllow) > 0 {
+			ui.PrintKeyValue("Allowed", strings.Join(maintenanceAllow, ", "))
+		}
+		ui.PrintInfo("Run 'mushak maintenance off' to restore traffic")
+	case "off":
+		return server.DisableMaintenance(executor, cfg.AppName)
+	default:
+		if server.IsMaintenanceEnabled(executor, cfg.AppName) {
+			ui.PrintWarning("Maintenance mode is ON")
+		} else {
+			ui.PrintSuccess("Maintenance mode is off")
+		}
+	}
+
+	return nil
+}
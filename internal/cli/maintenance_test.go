@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"testing"
+)
+
+func TestMaintenanceCommand(t *testing.T) {
+	if maintenanceCmd == nil {
+		t.Fatal("maintenanceCmd should not be nil")
+	}
+
+	if maintenanceCmd.Use != "maintenance [on|off]" {
+		t.Errorf("maintenanceCmd.Use = %v, want maintenance [on|off]", maintenanceCmd.Use)
+	}
+
+	if maintenanceCmd.RunE == nil {
+		t.Error("maintenanceCmd.RunE should not be nil")
+	}
+}
+
+func TestMaintenanceCommandFlags(t *testing.T) {
+	messageFlag := maintenanceCmd.Flags().Lookup("message")
+	if messageFlag == nil {
+		t.Error("maintenance command should have --message flag")
+	} else if messageFlag.Shorthand != "m" {
+		t.Errorf("message flag shorthand = %v, want m", messageFlag.Shorthand)
+	}
+
+	if maintenanceCmd.Flags().Lookup("page") == nil {
+		t.Error("maintenance command should have --page flag")
+	}
+
+	if maintenanceCmd.Flags().Lookup("allow") == nil {
+		t.Error("maintenance command should have --allow flag")
+	}
+}
+
+func TestMaintenanceInvalidArgument(t *testing.T) {
+	if err := runMaintenance(maintenanceCmd, []string{"maybe"}); err == nil {
+		t.Error("runMaintenance() should reject arguments other than on/off")
+	}
+}
@@ -61,6 +61,13 @@ func runRedeploy(cmd *cobra.Command, args []string) error {
 		ui.PrintWarning(fmt.Sprintf("Failed to update deployment hook: %v", err))
 	}
 
+	// Builds that mount the SSH agent use the local one
+	if appCfg != nil && appCfg.Build.SSH == config.ForwardSSHAgent {
+		if err := executor.ForwardAgent(); err != nil {
+			return err
+		}
+	}
+
 	// Trigger Redeploy
 	if err := server.TriggerRedeploy(executor, cfg); err != nil {
 		return err
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/server"
+	"github.com/hmontazeri/mushak/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Manage container registry credentials on the server",
+	Long: `Manage the credentials the server uses to pull images from container
+registries, for deploys with 'mushak deploy --image' or 'image:' in mushak.yaml.
+
+Examples:
+  mushak registry login ghcr.io --username octocat
+  echo $TOKEN | mushak registry login ghcr.io --username octocat --password-stdin
+  mushak registry list
+  mushak registry logout ghcr.io`,
+	Args: cobra.NoArgs,
+	RunE: withTimer(runRegistryList),
+}
+
+var registryLoginCmd = &cobra.Command{
+	Use:   "login [REGISTRY]",
+	Short: "Store registry credentials on the server",
+	Long: `Log the server in to a container registry with 'docker login'. The
+password is sent over the SSH connection's stdin and never appears in a
+command line. Use a token with read access where the registry supports it.
+
+Example:
+  mushak registry login ghcr.io --username octocat`,
+	Args: cobra.ExactArgs(1),
+	RunE: withTimer(runRegistryLogin),
+}
+
+var registryLogoutCmd = &cobra.Command{
+	Use:   "logout [REGISTRY]",
+	Short: "Remove registry credentials from the server",
+	Args:  cobra.ExactArgs(1),
+	RunE:  withTimer(runRegistryLogout),
+}
+
+var registryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the registries the server has credentials for",
+	Args:  cobra.NoArgs,
+	RunE:  withTimer(runRegistryList),
+}
+
+var registryUsername string
+var registryPasswordStdin bool
+
+func init() {
+	rootCmd.AddCommand(registryCmd)
+	registryCmd.AddCommand(registryLoginCmd)
+	registryCmd.AddCommand(registryLogoutCmd)
+	registryCmd.AddCommand(registryListCmd)
+
+	registryLoginCmd.Flags().StringVarP(&registryUsername, "username", "u", "", "Registry username")
+	registryLoginCmd.Flags().BoolVar(&registryPasswordStdin, "password-stdin", false, "Read the password from stdin")
+	registryLoginCmd.MarkFlagRequired("username")
+}
+
+func runRegistryLogin(cmd *cobra.Command, args []string) error {
+	registry := args[0]
+	if err := server.ValidateRegistry(registry); err != nil {
+		return err
+	}
+
+	password, err := readRegistryPassword(os.Stdin, registryPasswordStdin)
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+
+	cfg, executor, client, err := connectReleases()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := server.RegistryLogin(executor, registry, registryUsername, password); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("%s can pull images from %s", cfg.Host, registry))
+	return nil
+}
+
+func runRegistryLogout(cmd *cobra.Command, args []string) error {
+	cfg, executor, client, err := connectReleases()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := server.RegistryLogout(executor, args[0]); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Removed credentials for %s from %s", args[0], cfg.Host))
+	return nil
+}
+
+func runRegistryList(cmd *cobra.Command, args []string) error {
+	_, executor, client, err := connectReleases()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	registries, err := server.RegistryLogins(executor)
+	if err != nil {
+		return err
+	}
+
+	if len(registries) == 0 {
+		ui.PrintInfo("The server has no registry credentials. Add some with 'mushak registry login'")
+		return nil
+	}
+
+	for _, registry := range registries {
+		fmt.Println("  " + registry)
+	}
+	return nil
+}
+
+// readRegistryPassword reads the password from stdin, prompting for it without
+// echo when stdin is a terminal
+func readRegistryPassword(stdin *os.File, fromStdin bool) (string, error) {
+	if !fromStdin && term.IsTerminal(int(stdin.Fd())) {
+		fmt.Print("Password: ")
+		password, err := term.ReadPassword(int(stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return string(password), nil
+	}
+
+	return readPasswordLine(stdin)
+}
+
+// readPasswordLine reads the first line of r
+func readPasswordLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
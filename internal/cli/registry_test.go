@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryCommands(t *testing.T) {
+	for _, name := range []string{"login", "logout", "list"} {
+		found := false
+		for _, cmd := range registryCmd.Commands() {
+			if cmd.Name() == name {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("registry command should have a %s subcommand", name)
+		}
+	}
+
+	if registryLoginCmd.Flags().Lookup("username") == nil {
+		t.Error("registry login should have --username flag")
+	}
+	if registryLoginCmd.Flags().Lookup("password-stdin") == nil {
+		t.Error("registry login should have --password-stdin flag")
+	}
+}
+
+func TestReadPasswordLine(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"s3cret\n", "s3cret"},
+		{"s3cret\r\nignored\n", "s3cret"},
+		{"s3cret", "s3cret"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := readPasswordLine(strings.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("readPasswordLine(%q) error = %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("readPasswordLine(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
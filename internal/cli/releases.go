@@ -0,0 +1,439 @@
+package cli
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/server"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+	"github.com/hmontazeri/mushak/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var releasesCmd = &cobra.Command{
+	Use:   "releases",
+	Short: "List releases kept on the server",
+	Long: `List the releases kept on the server for rollback.
+
+Old releases are removed after each deploy and rollback. How many are kept is
+configured with retention in mushak.yaml. Pinned releases are never removed.
+
+Examples:
+  mushak releases
+  mushak releases pin abc123d
+  mushak releases unpin abc123d
+  mushak releases diff abc123d def456e`,
+	Args: cobra.NoArgs,
+	RunE: withTimer(runReleases),
+}
+
+var releasesPinCmd = &cobra.Command{
+	Use:   "pin [SHA]",
+	Short: "Exempt a release from cleanup",
+	Long: `Pin a release so it is kept regardless of the retention settings,
+for example a known-good version you may want to roll back to later.
+
+Example:
+  mushak releases pin abc123d`,
+	Args: cobra.ExactArgs(1),
+	RunE: withTimer(runReleasesPin),
+}
+
+var releasesUnpinCmd = &cobra.Command{
+	Use:   "unpin [SHA]",
+	Short: "Let a pinned release be cleaned up again",
+	Long: `Unpin a release. It is removed by the next deploy or rollback if the
+retention settings don't keep it.
+
+Example:
+  mushak releases unpin abc123d`,
+	Args: cobra.ExactArgs(1),
+	RunE: withTimer(runReleasesUnpin),
+}
+
+var releasesDiffCmd = &cobra.Command{
+	Use:   "diff [SHA] [SHA]",
+	Short: "Show what changed between two releases",
+	Long: `Show the commits between two deployed releases and which mushak.yaml
+settings and environment variables differ. Values are masked.
+
+Example:
+  mushak releases diff abc123d def456e`,
+	Args: cobra.ExactArgs(2),
+	RunE: withTimer(runReleasesDiff),
+}
+
+func init() {
+	rootCmd.AddCommand(releasesCmd)
+	releasesCmd.AddCommand(releasesPinCmd)
+	releasesCmd.AddCommand(releasesUnpinCmd)
+	releasesCmd.AddCommand(releasesDiffCmd)
+}
+
+func runReleases(cmd *cobra.Command, args []string) error {
+	cfg, executor, client, err := connectReleases()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	versions, err := server.ListVersions(executor, cfg.AppName)
+	if err != nil {
+		return fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	if len(versions) == 0 {
+		ui.PrintWarning("No releases found")
+		return nil
+	}
+
+	printReleases(versions)
+	return nil
+}
+
+// printReleases lists releases with their commit and deploy details
+func printReleases(versions []server.DeploymentVersion) {
+	for _, v := range versions {
+		var status []string
+		if v.IsCurrent {
+			status = append(status, "current")
+		}
+		if v.IsPinned {
+			status = append(status, "pinned")
+		}
+
+		timestamp := v.Timestamp
+		if len(timestamp) > 19 {
+			timestamp = timestamp[:19]
+		}
+		timestamp = strings.Replace(timestamp, "T", " ", 1)
+
+		fmt.Printf("  %-10s %-20s %s\n", v.SHA, timestamp, strings.Join(status, ", "))
+
+		info := v.Info
+		if info.Subject != "" {
+			fmt.Printf("  %-10s %s\n", "", info.Subject)
+		}
+
+		var details []string
+		if info.Author != "" {
+			details = append(details, "by "+info.Author)
+		}
+		if info.DeployedBy != "" {
+			details = append(details, "deployed by "+info.DeployedBy)
+		}
+		if info.ConfigFingerprint != "" {
+			details = append(details, "config "+info.ConfigFingerprint)
+		}
+		if info.EnvFingerprint != "" {
+			details = append(details, "env "+info.EnvFingerprint)
+		}
+		if len(details) > 0 {
+			fmt.Printf("  %-10s %s\n", "", strings.Join(details, " · "))
+		}
+		println()
+	}
+}
+
+func runReleasesPin(cmd *cobra.Command, args []string) error {
+	cfg, executor, client, err := connectReleases()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	sha, err := resolveRelease(executor, cfg.AppName, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := server.PinRelease(executor, cfg.AppName, sha); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Pinned release %s", sha))
+	return nil
+}
+
+func runReleasesUnpin(cmd *cobra.Command, args []string) error {
+	cfg, executor, client, err := connectReleases()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	pinned, err := server.PinnedReleases(executor, cfg.AppName)
+	if err != nil {
+		return err
+	}
+
+	sha := ""
+	for p := range pinned {
+		if strings.HasPrefix(p, args[0]) {
+			sha = p
+			break
+		}
+	}
+	if sha == "" {
+		return fmt.Errorf("release %s is not pinned", args[0])
+	}
+
+	if err := server.UnpinRelease(executor, cfg.AppName, sha); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Unpinned release %s", sha))
+	return nil
+}
+
+func runReleasesDiff(cmd *cobra.Command, args []string) error {
+	cfg, executor, client, err := connectReleases()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	versions, err := server.ListVersions(executor, cfg.AppName)
+	if err != nil {
+		return fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	from, err := findRelease(versions, args[0])
+	if err != nil {
+		return err
+	}
+	to, err := findRelease(versions, args[1])
+	if err != nil {
+		return err
+	}
+
+	ui.PrintHeader(fmt.Sprintf("Releases %s → %s", from.SHA, to.SHA))
+
+	// Commits, in whichever direction the releases are apart
+	var commits, reverted []string
+	if !isImageRelease(from) && !isImageRelease(to) {
+		fromRev, toRev := releaseRevision(from), releaseRevision(to)
+		commits, err = server.ReleaseLog(executor, cfg.AppName, cfg.RootDir, fromRev, toRev)
+		if err != nil {
+			return err
+		}
+		reverted, err = server.ReleaseLog(executor, cfg.AppName, cfg.RootDir, toRev, fromRev)
+		if err != nil {
+			return err
+		}
+	}
+
+	println()
+	ui.PrintInfo("Commits")
+	if isImageRelease(from) || isImageRelease(to) {
+		fmt.Printf("  Deployed from images: %s → %s\n", releaseSource(from), releaseSource(to))
+	} else if len(commits) == 0 && len(reverted) == 0 {
+		fmt.Println("  No commits between the releases")
+	}
+	for _, c := range commits {
+		fmt.Println("  " + ui.Success("+ "+c))
+	}
+	for _, c := range reverted {
+		fmt.Println("  " + ui.Error("- "+c))
+	}
+
+	// mushak.yaml settings
+	fromConfig, err := readReleaseConfig(executor, cfg, from)
+	if err != nil {
+		return err
+	}
+	toConfig, err := readReleaseConfig(executor, cfg, to)
+	if err != nil {
+		return err
+	}
+
+	println()
+	ui.PrintInfo("mushak.yaml")
+	lines, err := formatConfigDiff([]byte(fromConfig), []byte(toConfig))
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		fmt.Println("  No changes")
+	}
+	for _, line := range lines {
+		fmt.Println("  " + line)
+	}
+
+	// Environment the releases were started with
+	fromEnv, err := server.ReadReleaseEnvFiles(executor, cfg.AppName, from.SHA)
+	if err != nil {
+		return err
+	}
+	toEnv, err := server.ReadReleaseEnvFiles(executor, cfg.AppName, to.SHA)
+	if err != nil {
+		return err
+	}
+
+	println()
+	ui.PrintInfo("Environment")
+	lines, err = formatReleaseEnvDiff(fromEnv, toEnv)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		fmt.Println("  No changes")
+	}
+	for _, line := range lines {
+		fmt.Println("  " + line)
+	}
+
+	return nil
+}
+
+// formatConfigDiff lists the mushak.yaml settings that differ between two releases
+func formatConfigDiff(before, after []byte) ([]string, error) {
+	added, removed, changed, err := config.DiffConfigKeys(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, key := range added {
+		lines = append(lines, ui.Success("+ "+key))
+	}
+	for _, key := range removed {
+		lines = append(lines, ui.Error("- "+key))
+	}
+	for _, key := range changed {
+		lines = append(lines, ui.Warning("≠ "+key))
+	}
+	return lines, nil
+}
+
+// formatReleaseEnvDiff lists the variables that differ between the env files
+// of two releases, grouped by file
+func formatReleaseEnvDiff(before, after map[string]string) ([]string, error) {
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var lines []string
+	for _, name := range sorted {
+		beforeVars, err := parseEnvVars(name, before[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		afterVars, err := parseEnvVars(name, after[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+
+		diff := formatEnvVersionDiff(beforeVars, afterVars)
+		if len(diff) == 0 {
+			continue
+		}
+
+		// Only label per-service files when there is more than the app's .env
+		if len(sorted) > 1 {
+			lines = append(lines, name+":")
+		}
+		lines = append(lines, diff...)
+	}
+	return lines, nil
+}
+
+// parseEnvVars returns the variables of a release env file's content
+func parseEnvVars(name, content string) (map[string]string, error) {
+	f, err := utils.ParseEnv(content, serverEnvFormat(name))
+	if err != nil {
+		return nil, err
+	}
+	return f.Vars(), nil
+}
+
+// releaseRevision returns the commit to look up a release by in the repository
+func releaseRevision(v *server.DeploymentVersion) string {
+	if v.Info.Commit != "" {
+		return v.Info.Commit
+	}
+	return v.SHA
+}
+
+// readReleaseConfig returns the mushak.yaml a release was deployed with. Image
+// releases have no commit, so the settings recorded in their directory are used
+func readReleaseConfig(executor *ssh.Executor, cfg *config.DeployConfig, v *server.DeploymentVersion) (string, error) {
+	if isImageRelease(v) {
+		return server.ReadReleaseFile(executor, cfg.AppName, v.SHA, "mushak.yaml")
+	}
+	return server.ReadRepoFile(executor, cfg.AppName, releaseRevision(v), path.Join(cfg.RootDir, "mushak.yaml"))
+}
+
+// isImageRelease reports whether a release was deployed from a registry image
+// rather than from a commit
+func isImageRelease(v *server.DeploymentVersion) bool {
+	return strings.HasPrefix(v.SHA, "image-")
+}
+
+// releaseSource returns the image a release was deployed from, or its SHA
+func releaseSource(v *server.DeploymentVersion) string {
+	if isImageRelease(v) && v.Info.Subject != "" {
+		return v.Info.Subject
+	}
+	return v.SHA
+}
+
+// findRelease returns the release whose SHA starts with prefix
+func findRelease(versions []server.DeploymentVersion, prefix string) (*server.DeploymentVersion, error) {
+	for i, v := range versions {
+		if strings.HasPrefix(v.SHA, prefix) {
+			return &versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release %s not found or image not available", prefix)
+}
+
+// resolveRelease returns the full SHA of the release matching prefix
+func resolveRelease(executor *ssh.Executor, appName, prefix string) (string, error) {
+	versions, err := server.ListVersions(executor, appName)
+	if err != nil {
+		return "", fmt.Errorf("failed to list releases: %w", err)
+	}
+
+	v, err := findRelease(versions, prefix)
+	if err != nil {
+		return "", err
+	}
+	return v.SHA, nil
+}
+
+// connectReleases loads the deploy config and opens an SSH connection to the app's server
+func connectReleases() (*config.DeployConfig, *ssh.Executor, *ssh.Client, error) {
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	return cfg, ssh.NewExecutor(client), client, nil
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleasesCommands(t *testing.T) {
+	if releasesCmd.Use != "releases" {
+		t.Errorf("releasesCmd.Use = %v, want releases", releasesCmd.Use)
+	}
+
+	for _, c := range releasesCmd.Commands() {
+		if err := c.Args(c, []string{}); err == nil {
+			t.Errorf("releases %s should require a SHA", c.Name())
+		}
+	}
+
+	names := map[string]bool{}
+	for _, c := range releasesCmd.Commands() {
+		names[c.Name()] = true
+	}
+	for _, want := range []string{"pin", "unpin", "diff"} {
+		if !names[want] {
+			t.Errorf("releases command should have %s subcommand", want)
+		}
+	}
+}
+
+func TestFormatReleaseEnvDiff(t *testing.T) {
+	before := map[string]string{".env": "A=1\nB=2\n"}
+	after := map[string]string{".env": "A=1\nB=3\nC=secret-value\n", ".env.d/worker.env": "QUEUE=jobs\n"}
+
+	lines, err := formatReleaseEnvDiff(before, after)
+	if err != nil {
+		t.Fatalf("formatReleaseEnvDiff() error = %v", err)
+	}
+
+	got := strings.Join(lines, "\n")
+	for _, want := range []string{".env:", "C=", "B=", ".env.d/worker.env:", "QUEUE="} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatReleaseEnvDiff() missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "secret-value") {
+		t.Error("formatReleaseEnvDiff() should mask values")
+	}
+}
+
+func TestFormatConfigDiff(t *testing.T) {
+	lines, err := formatConfigDiff([]byte("health_path: /\n"), []byte("health_path: /health\ndrain_seconds: 5\n"))
+	if err != nil {
+		t.Fatalf("formatConfigDiff() error = %v", err)
+	}
+	if len(lines) != 2 {
+		t.Errorf("formatConfigDiff() = %v, want one added and one changed setting", lines)
+	}
+}
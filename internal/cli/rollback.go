@@ -22,13 +22,18 @@ With a SHA argument, rolls back to that specific version.
 
 Examples:
   mushak rollback          # List available versions
+  mushak rollback --list   # List versions with commit and deploy details
   mushak rollback abc123d  # Rollback to specific version
   mushak rollback -1       # Rollback to previous version`,
 	RunE: withTimer(runRollback),
 }
 
+var rollbackList bool
+
 func init() {
 	rootCmd.AddCommand(rollbackCmd)
+
+	rollbackCmd.Flags().BoolVarP(&rollbackList, "list", "l", false, "List available versions with commit and deploy details, without rolling back")
 }
 
 func runRollback(cmd *cobra.Command, args []string) error {
@@ -75,6 +80,12 @@ func runRollback(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if rollbackList {
+		println()
+		printReleases(versions)
+		return nil
+	}
+
 	// If no argument, show list and prompt for selection
 	if len(args) == 0 {
 		return showVersionsAndPrompt(executor, cfg, versions)
@@ -123,8 +134,8 @@ func showVersionsAndPrompt(executor *ssh.Executor, cfg *config.DeployConfig, ver
 	println()
 
 	// Print header
-	fmt.Printf("  %-10s %-22s %-8s %s\n", "SHA", "DEPLOYED", "STATUS", "")
-	fmt.Printf("  %-10s %-22s %-8s %s\n", "---", "--------", "------", "")
+	fmt.Printf("  %-10s %-22s %-8s %s\n", "SHA", "DEPLOYED", "STATUS", "COMMIT")
+	fmt.Printf("  %-10s %-22s %-8s %s\n", "---", "--------", "------", "------")
 
 	for i, v := range versions {
 		status := ""
@@ -133,6 +144,9 @@ func showVersionsAndPrompt(executor *ssh.Executor, cfg *config.DeployConfig, ver
 			status = "current"
 			marker = " ←"
 		}
+		if v.IsPinned && status == "" {
+			status = "pinned"
+		}
 
 		// Format timestamp nicely
 		timestamp := v.Timestamp
@@ -141,7 +155,12 @@ func showVersionsAndPrompt(executor *ssh.Executor, cfg *config.DeployConfig, ver
 		}
 		timestamp = strings.Replace(timestamp, "T", " ", 1)
 
-		fmt.Printf("  %-10s %-22s %-8s%s\n", v.SHA, timestamp, status, marker)
+		subject := v.Info.Subject
+		if len(subject) > 50 {
+			subject = subject[:47] + "..."
+		}
+
+		fmt.Printf("  %-10s %-22s %-8s %s%s\n", v.SHA, timestamp, status, subject, marker)
 
 		// Only show first 5 versions in list
 		if i >= 4 {
@@ -46,3 +46,9 @@ func containsHelper(s, substr string) bool {
 	return false
 }
 
+
+func TestRollbackListFlag(t *testing.T) {
+	if rollbackCmd.Flags().Lookup("list") == nil {
+		t.Error("rollback command should have --list flag")
+	}
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/ui"
+	"github.com/hmontazeri/mushak/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage encrypted secrets",
+	Long: `Manage secrets in secrets.env.enc, an encrypted env file that is safe to commit.
+
+The file is encrypted with a team key kept in .mushak/secrets.key (or the
+MUSHAK_SECRETS_KEY environment variable). Share the key with your team through
+a password manager, never through the repository.
+
+On deploy the key is synced to the server, where secrets are verified and
+decrypted into memory-backed storage only while the containers are created.
+Restarts and rollbacks decrypt them again.
+Variables in secrets.env.enc take precedence over the server's .env.prod.`,
+}
+
+var secretsInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Generate a team key for encrypted secrets",
+	Long: `Generate a new team key in .mushak/secrets.key and add it to .gitignore.
+
+Example:
+  mushak secrets init`,
+	Args: cobra.NoArgs,
+	RunE: withTimer(runSecretsInit),
+}
+
+var secretsEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit encrypted secrets in $EDITOR",
+	Long: `Decrypt secrets.env.enc into a temporary file, open it in $EDITOR and
+encrypt it again when the editor exits. Creates the file if it doesn't exist.
+
+Example:
+  mushak secrets edit
+  EDITOR="code --wait" mushak secrets edit`,
+	Args: cobra.NoArgs,
+	RunE: runSecretsEdit,
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsInitCmd)
+	secretsCmd.AddCommand(secretsEditCmd)
+}
+
+func runSecretsInit(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(utils.SecretsKeyFile); err == nil {
+		return fmt.Errorf("%s already exists", utils.SecretsKeyFile)
+	}
+
+	key, err := utils.GenerateSecretsKey()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(".mushak", 0755); err != nil {
+		return fmt.Errorf("failed to create .mushak directory: %w", err)
+	}
+
+	if err := os.WriteFile(utils.SecretsKeyFile, []byte(key+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", utils.SecretsKeyFile, err)
+	}
+	ui.PrintSuccess(fmt.Sprintf("Generated team key in %s", utils.SecretsKeyFile))
+
+	if err := ensureGitignored(".gitignore", utils.SecretsKeyFile); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Failed to update .gitignore: %v. Make sure %s is never committed", err, utils.SecretsKeyFile))
+	}
+
+	println()
+	ui.PrintInfo("Share the key with your team through a password manager")
+	ui.PrintInfo("Run 'mushak secrets edit' to add secrets")
+
+	return nil
+}
+
+func runSecretsEdit(cmd *cobra.Command, args []string) error {
+	key, err := utils.LoadSecretsKey()
+	if err != nil {
+		return err
+	}
+
+	var current string
+	if _, err := os.Stat(utils.SecretsFile); err == nil {
+		current, err = utils.ReadSecretsFile(utils.SecretsFile, key)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", utils.SecretsFile, err)
+		}
+	}
+
+	// os.CreateTemp creates the file with 0600 permissions
+	tmp, err := os.CreateTemp("", "mushak-secrets-*.env")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(current); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	tmp.Close()
+
+	if err := openEditor(tmp.Name()); err != nil {
+		return err
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("failed to read edited secrets: %w", err)
+	}
+
+	if string(edited) == current {
+		ui.PrintInfo("No changes")
+		return nil
+	}
+
+	// Save anyway so the edit is not lost, it can be fixed with another edit
+	if f, err := utils.ParseDotenv(string(edited)); err != nil {
+		ui.PrintWarning(fmt.Sprintf("Secrets contain invalid syntax: %v", err))
+	} else if serverEnvFormat("") == utils.EnvFormatDocker && !maps.Equal(f.Vars(), utils.ParseDockerEnv(string(edited)).Vars()) {
+		// docker run --env-file passes quotes, escapes and comments through
+		ui.PrintWarning("Dockerfile apps receive secrets with docker run --env-file, which does not unquote values. Use plain KEY=value lines")
+	}
+
+	encrypted, err := utils.EncryptSecrets(edited, key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(utils.SecretsFile, encrypted, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", utils.SecretsFile, err)
+	}
+
+	count, _ := utils.CountEnvVars(tmp.Name())
+	ui.PrintSuccess(fmt.Sprintf("Saved %d secret%s to %s", count, pluralizeEnv(count), utils.SecretsFile))
+	ui.PrintInfo("Commit the file and run 'mushak deploy' to apply")
+
+	return nil
+}
+
+// openEditor opens path in $VISUAL or $EDITOR and waits for it to exit
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// The editor may carry arguments, e.g. "code --wait"
+	parts := strings.Fields(editor)
+	editorCmd := exec.Command(parts[0], append(parts[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+
+	if err := editorCmd.Run(); err != nil {
+		return fmt.Errorf("editor exited with error: %w", err)
+	}
+	return nil
+}
+
+// ensureGitignored appends entry to the gitignore file unless it is already listed
+func ensureGitignored(gitignore, entry string) error {
+	content, err := os.ReadFile(gitignore)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == entry || line == "/"+entry || line == ".mushak" || line == ".mushak/" || line == "/.mushak/" {
+			return nil
+		}
+	}
+
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		entry = "\n" + entry
+	}
+
+	f, err := os.OpenFile(gitignore, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(entry + "\n")
+	return err
+}
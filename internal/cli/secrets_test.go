@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecretsCommand(t *testing.T) {
+	if secretsCmd == nil {
+		t.Fatal("secretsCmd should not be nil")
+	}
+
+	if secretsCmd.Use != "secrets" {
+		t.Errorf("secretsCmd.Use = %v, want secrets", secretsCmd.Use)
+	}
+
+	subcommands := map[string]bool{}
+	for _, c := range secretsCmd.Commands() {
+		subcommands[c.Name()] = true
+	}
+
+	for _, name := range []string{"init", "edit"} {
+		if !subcommands[name] {
+			t.Errorf("secrets command should have %q subcommand", name)
+		}
+	}
+}
+
+func TestEnsureGitignored(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		expected string
+	}{
+		{
+			name:     "no gitignore",
+			existing: "",
+			expected: ".mushak/secrets.key\n",
+		},
+		{
+			name:     "append to existing",
+			existing: "node_modules\n",
+			expected: "node_modules\n.mushak/secrets.key\n",
+		},
+		{
+			name:     "missing trailing newline",
+			existing: "node_modules",
+			expected: "node_modules\n.mushak/secrets.key\n",
+		},
+		{
+			name:     "already ignored",
+			existing: ".mushak/secrets.key\n",
+			expected: ".mushak/secrets.key\n",
+		},
+		{
+			name:     "whole directory ignored",
+			existing: ".mushak/\n",
+			expected: ".mushak/\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".gitignore")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := ensureGitignored(path, ".mushak/secrets.key"); err != nil {
+				t.Fatalf("ensureGitignored() error = %v", err)
+			}
+
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.expected {
+				t.Errorf("ensureGitignored() wrote %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
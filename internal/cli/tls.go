@@ -0,0 +1,328 @@
+package cli
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/server"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var tlsCmd = &cobra.Command{
+	Use:   "tls",
+	Short: "Manage TLS certificates for the application",
+	Long: `Manage how Caddy obtains certificates for your application.
+
+By default Caddy uses automatic HTTPS (HTTP-01/TLS-ALPN challenges), which needs
+the domain to be publicly reachable. For private networks you can instead use:
+  - tls.mode: internal in mushak.yaml (certificates from Caddy's local CA)
+  - mushak tls upload (your own certificate and key)
+  - mushak tls dns (DNS-01 challenge through a DNS provider)`,
+}
+
+var tlsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show certificate issuer, expiry and errors",
+	Long: `Show the certificate Caddy is serving for each domain of the application,
+including issuer, expiry date and any certificate errors logged in the last 24 hours.
+
+Example:
+  mushak tls status`,
+	RunE: withTimer(runTLSStatus),
+}
+
+var tlsUploadCmd = &cobra.Command{
+	Use:   "upload [cert.pem] [key.pem]",
+	Short: "Upload a custom certificate and key",
+	Long: `Upload a custom certificate and private key to the server and switch the
+application to tls mode 'custom'.
+
+Example:
+  mushak tls upload fullchain.pem privkey.pem`,
+	Args: cobra.ExactArgs(2),
+	RunE: withTimer(runTLSUpload),
+}
+
+var tlsDNSCmd = &cobra.Command{
+	Use:   "dns [provider] [KEY=VALUE]...",
+	Short: "Use the DNS-01 challenge with a DNS provider",
+	Long: `Store DNS provider credentials on the server and switch the application to
+tls mode 'dns'. Credentials are kept in a root-only file on the server and passed
+to Caddy as environment variables. The matching caddy-dns module is installed
+if Caddy does not have it yet.
+
+A single credential is passed to the provider as its token. Providers that
+need several credentials get them as options, so name them after the
+provider's Caddyfile options.
+
+Example:
+  mushak tls dns cloudflare CLOUDFLARE_API_TOKEN=xxxx
+  mushak tls dns route53 access_key_id=xxxx secret_access_key=yyyy`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: withTimer(runTLSDNS),
+}
+
+var tlsResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Use the TLS settings from mushak.yaml again",
+	Long: `Clear the TLS mode recorded by 'mushak tls upload' and 'mushak tls dns'
+and apply tls.mode from mushak.yaml (auto if it has none). Uploaded
+certificates and stored DNS credentials stay on the server.
+
+Example:
+  mushak tls reset`,
+	Args: cobra.NoArgs,
+	RunE: withTimer(runTLSReset),
+}
+
+func init() {
+	rootCmd.AddCommand(tlsCmd)
+	tlsCmd.AddCommand(tlsStatusCmd)
+	tlsCmd.AddCommand(tlsUploadCmd)
+	tlsCmd.AddCommand(tlsDNSCmd)
+	tlsCmd.AddCommand(tlsResetCmd)
+}
+
+func runTLSStatus(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	appCfg, _ := config.LoadConfig("mushak.yaml")
+	mode, provider := resolveTLSConfig(cfg, appCfg)
+
+	ui.PrintHeader("Mushak TLS Status")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+	if provider != "" {
+		ui.PrintKeyValue("Mode", fmt.Sprintf("%s (%s)", mode, provider))
+	} else {
+		ui.PrintKeyValue("Mode", mode)
+	}
+	println()
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	for _, status := range server.GetTLSStatus(executor, splitDomains(cfg.Domain)) {
+		fmt.Println(ui.Bold(status.Domain))
+		if status.Issuer == "" {
+			ui.PrintWarning("No certificate is being served")
+		} else {
+			ui.PrintKeyValue("Issuer", status.Issuer)
+			ui.PrintKeyValue("Expires", status.NotAfter)
+		}
+
+		if len(status.Errors) == 0 {
+			ui.PrintSuccess("No certificate errors in the last 24 hours")
+		} else {
+			ui.PrintError(fmt.Sprintf("%d error%s in the last 24 hours:", len(status.Errors), pluralize(len(status.Errors))))
+			for _, line := range status.Errors {
+				fmt.Println("  " + ui.Muted(line))
+			}
+		}
+		println()
+	}
+
+	return nil
+}
+
+func runTLSUpload(cmd *cobra.Command, args []string) error {
+	certFile, keyFile := args[0], args[1]
+
+	// Validate the pair locally before touching the server
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return fmt.Errorf("invalid certificate/key pair: %w", err)
+	}
+
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", certFile, err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", keyFile, err)
+	}
+
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	ui.PrintHeader("Mushak TLS Upload")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+	ui.PrintKeyValue("Certificate", certFile)
+	println()
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	if err := server.UploadCertificate(executor, cfg.AppName, string(certPEM), string(keyPEM)); err != nil {
+		return err
+	}
+
+	cfg.TLSMode = server.TLSModeCustom
+	cfg.TLSDNSProvider = ""
+	if err := server.ApplyTLSConfig(executor, cfg.AppName, cfg.TLSMode, ""); err != nil {
+		return err
+	}
+
+	if err := config.SaveDeployConfig(cfg); err != nil {
+		return fmt.Errorf("failed to update local config: %w", err)
+	}
+
+	return nil
+}
+
+func runTLSDNS(cmd *cobra.Command, args []string) error {
+	provider := args[0]
+
+	credentials := make(map[string]string)
+	for _, arg := range args[1:] {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid argument: %s. Must be KEY=VALUE", arg)
+		}
+		credentials[parts[0]] = parts[1]
+	}
+
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	ui.PrintHeader("Mushak TLS DNS")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+	ui.PrintKeyValue("Provider", provider)
+	println()
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	if err := server.StoreDNSCredentials(executor, cfg.AppName, provider, credentials); err != nil {
+		return err
+	}
+
+	cfg.TLSMode = server.TLSModeDNS
+	cfg.TLSDNSProvider = provider
+	if err := server.ApplyTLSConfig(executor, cfg.AppName, cfg.TLSMode, provider); err != nil {
+		return err
+	}
+
+	if err := config.SaveDeployConfig(cfg); err != nil {
+		return fmt.Errorf("failed to update local config: %w", err)
+	}
+
+	return nil
+}
+
+func runTLSReset(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadDeployConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w\nHave you run 'mushak init'?", err)
+	}
+
+	cfg.TLSMode = ""
+	cfg.TLSDNSProvider = ""
+
+	appCfg, _ := config.LoadConfig("mushak.yaml")
+	mode, provider := resolveTLSConfig(cfg, appCfg)
+
+	ui.PrintHeader("Mushak TLS Reset")
+	ui.PrintKeyValue("Server", fmt.Sprintf("%s@%s", cfg.User, cfg.Host))
+	ui.PrintKeyValue("App", cfg.AppName)
+	ui.PrintKeyValue("Mode", mode)
+	println()
+
+	client, err := ssh.NewClient(ssh.Config{
+		Host: cfg.Host,
+		User: cfg.User,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SSH client: %w", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer client.Close()
+
+	executor := ssh.NewExecutor(client)
+
+	if err := server.ApplyTLSConfig(executor, cfg.AppName, mode, provider); err != nil {
+		return err
+	}
+
+	if err := config.SaveDeployConfig(cfg); err != nil {
+		return fmt.Errorf("failed to update local config: %w", err)
+	}
+
+	ui.PrintSuccess("TLS settings now come from mushak.yaml")
+	return nil
+}
+
+// resolveTLSConfig returns the TLS mode and DNS provider, preferring the
+// local deploy config over the committed mushak.yaml
+func resolveTLSConfig(cfg *config.DeployConfig, appCfg *config.AppConfig) (string, string) {
+	mode := cfg.TLSMode
+	provider := cfg.TLSDNSProvider
+	if mode == "" && appCfg != nil {
+		mode = appCfg.TLS.Mode
+		provider = appCfg.TLS.DNSProvider
+	}
+	if mode == "" {
+		mode = server.TLSModeAuto
+	}
+	return mode, provider
+}
+
+// splitDomains splits a Caddy site address list such as "a.com, b.com"
+func splitDomains(domain string) []string {
+	return strings.FieldsFunc(domain, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/hmontazeri/mushak/internal/config"
+)
+
+func TestTLSCommands(t *testing.T) {
+	if tlsCmd == nil {
+		t.Fatal("tlsCmd should not be nil")
+	}
+
+	expected := map[string]bool{"status": false, "upload": false, "dns": false, "reset": false}
+	for _, sub := range tlsCmd.Commands() {
+		if _, ok := expected[sub.Name()]; ok {
+			expected[sub.Name()] = true
+		}
+	}
+
+	for name, found := range expected {
+		if !found {
+			t.Errorf("tls command should have %q subcommand", name)
+		}
+	}
+}
+
+func TestResolveTLSConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          *config.DeployConfig
+		appCfg       *config.AppConfig
+		wantMode     string
+		wantProvider string
+	}{
+		{
+			name:     "defaults to auto",
+			cfg:      &config.DeployConfig{},
+			appCfg:   config.DefaultConfig(),
+			wantMode: "auto",
+		},
+		{
+			name:     "from mushak.yaml",
+			cfg:      &config.DeployConfig{},
+			appCfg:   &config.AppConfig{TLS: config.TLSConfig{Mode: "internal"}},
+			wantMode: "internal",
+		},
+		{
+			name:         "deploy config overrides mushak.yaml",
+			cfg:          &config.DeployConfig{TLSMode: "dns", TLSDNSProvider: "cloudflare"},
+			appCfg:       &config.AppConfig{TLS: config.TLSConfig{Mode: "internal"}},
+			wantMode:     "dns",
+			wantProvider: "cloudflare",
+		},
+		{
+			name:     "nil app config",
+			cfg:      &config.DeployConfig{},
+			appCfg:   nil,
+			wantMode: "auto",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, provider := resolveTLSConfig(tt.cfg, tt.appCfg)
+			if mode != tt.wantMode {
+				t.Errorf("mode = %q, want %q", mode, tt.wantMode)
+			}
+			if provider != tt.wantProvider {
+				t.Errorf("provider = %q, want %q", provider, tt.wantProvider)
+			}
+		})
+	}
+}
+
+func TestSplitDomains(t *testing.T) {
+	got := splitDomains("example.com, www.example.com")
+	if len(got) != 2 || got[0] != "example.com" || got[1] != "www.example.com" {
+		t.Errorf("splitDomains() = %v, want [example.com www.example.com]", got)
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// buildPathPattern restricts paths and names in the build settings to
+// characters that are safe in the deploy hook and the compose override
+var buildPathPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+// buildArgPattern matches build argument names
+var buildArgPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// buildSecretIDPattern matches build secret ids
+var buildSecretIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ForwardSSHAgent is the build.ssh value that forwards the SSH agent of the
+// machine running 'mushak deploy' to the build
+const ForwardSSHAgent = "agent"
+
+// DockerfilePath returns the Dockerfile's path relative to the repository root
+func (b BuildConfig) DockerfilePath() string {
+	dockerfile := b.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	return path.Join(b.ContextPath(), dockerfile)
+}
+
+// ContextPath returns the build context relative to the repository root
+func (b BuildConfig) ContextPath() string {
+	if b.Context == "" {
+		return "."
+	}
+	return path.Clean(b.Context)
+}
+
+// ArgNames returns the names of the build arguments in args, sorted
+func (b BuildConfig) ArgNames() []string {
+	names := make([]string, 0, len(b.Args))
+	for name := range b.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check validates the build settings
+func (b BuildConfig) Check() error {
+	for _, field := range []struct{ name, value string }{
+		{"build.dockerfile", b.Dockerfile},
+		{"build.context", b.Context},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if !buildPathPattern.MatchString(field.value) || path.IsAbs(field.value) ||
+			strings.HasPrefix(path.Clean(field.value), "..") {
+			return fmt.Errorf("invalid %s %q: must be a relative path inside the repository", field.name, field.value)
+		}
+	}
+
+	if b.Target != "" && (!buildPathPattern.MatchString(b.Target) || strings.Contains(b.Target, "/")) {
+		return fmt.Errorf("invalid build.target %q", b.Target)
+	}
+	if b.Platform != "" && !buildPathPattern.MatchString(b.Platform) {
+		return fmt.Errorf("invalid build.platform %q: use a platform like linux/amd64", b.Platform)
+	}
+
+	for _, name := range b.ArgNames() {
+		if !buildArgPattern.MatchString(name) {
+			return fmt.Errorf("build.args: invalid argument name %q", name)
+		}
+		if strings.ContainsAny(b.Args[name], "\r\n") {
+			return fmt.Errorf("build.args.%s: value must be a single line", name)
+		}
+	}
+	for _, name := range b.ArgsFromEnv {
+		if !buildArgPattern.MatchString(name) {
+			return fmt.Errorf("build.args_from_env: invalid variable name %q", name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, secret := range b.Secrets {
+		if !buildSecretIDPattern.MatchString(secret.ID) {
+			return fmt.Errorf("build.secrets: invalid id %q", secret.ID)
+		}
+		if seen[secret.ID] {
+			return fmt.Errorf("build.secrets: duplicate id %q", secret.ID)
+		}
+		seen[secret.ID] = true
+
+		if (secret.Env == "") == (secret.File == "") {
+			return fmt.Errorf("build.secrets.%s: set either env or file", secret.ID)
+		}
+		if secret.Env != "" && !buildArgPattern.MatchString(secret.Env) {
+			return fmt.Errorf("build.secrets.%s: invalid variable name %q", secret.ID, secret.Env)
+		}
+		if secret.File != "" && !buildPathPattern.MatchString(secret.File) {
+			return fmt.Errorf("build.secrets.%s: invalid file %q", secret.ID, secret.File)
+		}
+	}
+
+	if b.SSH != "" && b.SSH != ForwardSSHAgent && !buildPathPattern.MatchString(b.SSH) {
+		return fmt.Errorf("invalid build.ssh %q: use agent or the path of a private key on the server", b.SSH)
+	}
+
+	return nil
+}
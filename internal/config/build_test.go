@@ -0,0 +1,71 @@
+package config
+
+import "testing"
+
+func TestBuildConfig_DockerfilePath(t *testing.T) {
+	tests := []struct {
+		build BuildConfig
+		want  string
+	}{
+		{build: BuildConfig{}, want: "Dockerfile"},
+		{build: BuildConfig{Dockerfile: "Dockerfile.prod"}, want: "Dockerfile.prod"},
+		{build: BuildConfig{Context: "app"}, want: "app/Dockerfile"},
+		{build: BuildConfig{Context: "./app/", Dockerfile: "docker/Dockerfile"}, want: "app/docker/Dockerfile"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.build.DockerfilePath(); got != tt.want {
+			t.Errorf("%+v.DockerfilePath() = %q, want %q", tt.build, got, tt.want)
+		}
+	}
+}
+
+func TestBuildConfig_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   BuildConfig
+		wantErr bool
+	}{
+		{name: "empty", build: BuildConfig{}},
+		{
+			name: "all settings",
+			build: BuildConfig{
+				Dockerfile:  "docker/Dockerfile.prod",
+				Context:     "app",
+				Target:      "runner",
+				Args:        map[string]string{"NODE_ENV": "production", "VERSION": "1.2 beta"},
+				ArgsFromEnv: []string{"NEXT_PUBLIC_API_URL"},
+				Platform:    "linux/amd64",
+			},
+		},
+		{name: "absolute context", build: BuildConfig{Context: "/srv/app"}, wantErr: true},
+		{name: "context outside repository", build: BuildConfig{Context: "../other"}, wantErr: true},
+		{name: "dockerfile with spaces", build: BuildConfig{Dockerfile: "My Dockerfile"}, wantErr: true},
+		{name: "target with slash", build: BuildConfig{Target: "a/b"}, wantErr: true},
+		{name: "invalid platform", build: BuildConfig{Platform: "linux amd64"}, wantErr: true},
+		{name: "invalid arg name", build: BuildConfig{Args: map[string]string{"NODE-ENV": "x"}}, wantErr: true},
+		{name: "multiline arg", build: BuildConfig{Args: map[string]string{"KEY": "a\nb"}}, wantErr: true},
+		{name: "invalid env arg", build: BuildConfig{ArgsFromEnv: []string{"$HOME"}}, wantErr: true},
+		{
+			name: "secrets and ssh",
+			build: BuildConfig{
+				Secrets: []BuildSecret{{ID: "npm_token", Env: "NPM_TOKEN"}, {ID: "netrc", File: "/home/deploy/.netrc"}},
+				SSH:     ForwardSSHAgent,
+			},
+		},
+		{name: "ssh key on server", build: BuildConfig{SSH: "/home/deploy/.ssh/id_ed25519"}},
+		{name: "secret without source", build: BuildConfig{Secrets: []BuildSecret{{ID: "npm_token"}}}, wantErr: true},
+		{name: "secret with two sources", build: BuildConfig{Secrets: []BuildSecret{{ID: "npm_token", Env: "NPM_TOKEN", File: "npmrc"}}}, wantErr: true},
+		{name: "duplicate secret", build: BuildConfig{Secrets: []BuildSecret{{ID: "a", Env: "A"}, {ID: "a", Env: "B"}}}, wantErr: true},
+		{name: "secret file with comma", build: BuildConfig{Secrets: []BuildSecret{{ID: "a", File: "a,b"}}}, wantErr: true},
+		{name: "invalid ssh", build: BuildConfig{SSH: "my key"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.build.Check(); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
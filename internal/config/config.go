@@ -15,14 +15,33 @@ type AppConfig struct {
 	ServiceName         string   `yaml:"service_name"`
 	PersistentServices  []string `yaml:"persistent_services"`
 	CacheLimit          string   `yaml:"cache_limit"` // e.g. "10GB" or "24h"
+	DrainSeconds        int      `yaml:"drain_seconds"`     // Max wait for open connections to the old release
+	StopGracePeriod     int      `yaml:"stop_grace_period"` // Seconds between SIGTERM and SIGKILL for old containers
+	TLS                 TLSConfig `yaml:"tls,omitempty"`
+	Protection          ProtectionConfig `yaml:"protection,omitempty"`
+	Env                 EnvConfig `yaml:"env,omitempty"`
+	Retention           RetentionConfig `yaml:"retention,omitempty"`
+	Watch               WatchConfig `yaml:"watch,omitempty"`
+	Build               BuildConfig `yaml:"build,omitempty"`
+	Image               string `yaml:"image,omitempty"` // prebuilt image to deploy instead of source, e.g. ghcr.io/acme/app:1.2.3
+	Type                string `yaml:"type,omitempty"`  // "static" serves files with Caddy instead of running a container
+	Static              StaticConfig `yaml:"static,omitempty"`
+}
+
+// TLSConfig controls how Caddy obtains certificates for the app
+type TLSConfig struct {
+	Mode        string `yaml:"mode,omitempty"`         // auto (default), internal, custom or dns
+	DNSProvider string `yaml:"dns_provider,omitempty"` // e.g. cloudflare, route53 (dns mode only)
 }
 
 // DefaultConfig returns the default configuration
 func DefaultConfig() *AppConfig {
 	return &AppConfig{
-		InternalPort:  80,
-		HealthPath:    "/",
-		HealthTimeout: 30,
+		InternalPort:    80,
+		HealthPath:      "/",
+		HealthTimeout:   30,
+		DrainSeconds:    10,
+		StopGracePeriod: 10,
 	}
 }
 
@@ -40,6 +59,12 @@ func LoadConfig(path string) (*AppConfig, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	return ParseConfig(data)
+}
+
+// ParseConfig parses the content of a mushak.yaml file on top of the defaults
+func ParseConfig(data []byte) (*AppConfig, error) {
+	cfg := DefaultConfig()
 	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
@@ -60,6 +85,76 @@ func SaveAppConfig(cfg *AppConfig) error {
 	return nil
 }
 
+// ProtectionConfig configures rate limiting and request blocking at the proxy
+type ProtectionConfig struct {
+	RateLimit       RateLimitConfig `yaml:"rate_limit,omitempty"`
+	MaxConnections  int             `yaml:"max_connections,omitempty"` // concurrent connections to the app
+	BlockUserAgents []string        `yaml:"block_user_agents,omitempty"`
+	BlockPaths      []string        `yaml:"block_paths,omitempty"`
+}
+
+// RateLimitConfig limits requests per client IP
+type RateLimitConfig struct {
+	Requests int    `yaml:"requests,omitempty"` // allowed requests per window
+	Window   string `yaml:"window,omitempty"`   // e.g. "1m" (default) or "10s"
+}
+
+// EnvConfig describes the environment variables the app expects
+type EnvConfig struct {
+	Schema map[string]EnvVarSchema `yaml:"schema,omitempty"`
+}
+
+// EnvVarSchema describes one environment variable
+type EnvVarSchema struct {
+	Required bool   `yaml:"required,omitempty"`
+	Type     string `yaml:"type,omitempty"`    // string (default), int, number, bool or url
+	Pattern  string `yaml:"pattern,omitempty"` // POSIX extended regular expression
+	Default  string `yaml:"default,omitempty"` // used when the variable is not set
+}
+
+// RetentionConfig controls how many old releases are kept for rollback
+type RetentionConfig struct {
+	Releases int    `yaml:"releases,omitempty"` // newest releases to keep (default 3)
+	MinAge   string `yaml:"min_age,omitempty"`  // e.g. "7d" or "48h", younger releases are always kept
+}
+
+// WatchConfig enables monitoring a release after traffic is switched to it,
+// rolling back automatically if it crashes or fails requests
+type WatchConfig struct {
+	Duration     string  `yaml:"duration,omitempty"`       // e.g. "2m", empty disables the watch
+	MaxErrorRate float64 `yaml:"max_error_rate,omitempty"` // percentage of 5xx responses (default 5)
+	MinRequests  int     `yaml:"min_requests,omitempty"`   // requests needed before the error rate counts (default 20)
+	MaxRestarts  int     `yaml:"max_restarts,omitempty"`   // container restarts tolerated (default 0)
+}
+
+// BuildConfig controls how the app's images are built
+type BuildConfig struct {
+	Registry    string            `yaml:"registry,omitempty"`      // repository 'mushak deploy --build local' pushes to for the server to pull, e.g. ghcr.io/acme/app
+	Dockerfile  string            `yaml:"dockerfile,omitempty"`    // relative to the context (default Dockerfile)
+	Context     string            `yaml:"context,omitempty"`       // relative to the repository root (default .)
+	Target      string            `yaml:"target,omitempty"`        // stage of a multi-stage Dockerfile to build
+	Args        map[string]string `yaml:"args,omitempty"`          // build arguments
+	ArgsFromEnv []string          `yaml:"args_from_env,omitempty"` // build arguments taken from the app's environment
+	Platform    string            `yaml:"platform,omitempty"`      // e.g. linux/amd64
+	Secrets     []BuildSecret     `yaml:"secrets,omitempty"`       // mounted with RUN --mount=type=secret, never stored in the image
+	SSH         string            `yaml:"ssh,omitempty"`           // "agent" to forward your SSH agent, or a private key on the server, for RUN --mount=type=ssh
+}
+
+// StaticConfig controls how static sites (type: static) are built
+type StaticConfig struct {
+	Build  string `yaml:"build,omitempty"`  // command that builds the site, run in a throwaway container
+	Image  string `yaml:"image,omitempty"`  // image the build command runs in (default node:lts-alpine)
+	Output string `yaml:"output,omitempty"` // directory with the files to serve (default dist with a build command, . without)
+}
+
+// BuildSecret is passed to the build as --secret id=<id>, from the app's
+// environment or from a file on the server
+type BuildSecret struct {
+	ID   string `yaml:"id"`
+	Env  string `yaml:"env,omitempty"`  // variable in the app's environment
+	File string `yaml:"file,omitempty"` // path on the server, relative to /var/www/<app>
+}
+
 // DeployConfig represents local deployment configuration
 // Stored in .mushak/mushak.yaml
 type DeployConfig struct {
@@ -70,10 +165,19 @@ type DeployConfig struct {
 	Branch     string `yaml:"branch"`
 	RemoteName string `yaml:"remote_name"`
 
+	// Monorepo apps: the app's directory in the repository, and whether pushes
+	// that don't change anything in it are skipped
+	RootDir       string `yaml:"root_dir,omitempty"`
+	SkipUnchanged bool   `yaml:"skip_unchanged,omitempty"`
+
 	// Optional overrides
 	InternalPort  int    `yaml:"internal_port,omitempty"`
 	HealthPath    string `yaml:"health_path,omitempty"`
 	HealthTimeout int    `yaml:"health_timeout,omitempty"`
+
+	// TLS overrides (set by 'mushak tls')
+	TLSMode        string `yaml:"tls_mode,omitempty"`
+	TLSDNSProvider string `yaml:"tls_dns_provider,omitempty"`
 }
 
 // SaveDeployConfig saves deployment configuration locally
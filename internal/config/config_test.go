@@ -26,6 +26,14 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.HealthTimeout != 30 {
 		t.Errorf("DefaultConfig().HealthTimeout = %v, want 30", cfg.HealthTimeout)
 	}
+
+	if cfg.DrainSeconds != 10 {
+		t.Errorf("DefaultConfig().DrainSeconds = %v, want 10", cfg.DrainSeconds)
+	}
+
+	if cfg.StopGracePeriod != 10 {
+		t.Errorf("DefaultConfig().StopGracePeriod = %v, want 10", cfg.StopGracePeriod)
+	}
 }
 
 func TestLoadConfig_NonExistentFile(t *testing.T) {
@@ -313,3 +321,71 @@ func TestSaveAndLoadDeployConfig_RoundTrip(t *testing.T) {
 		t.Errorf("RemoteName = %v, want %v", loadedCfg.RemoteName, originalCfg.RemoteName)
 	}
 }
+
+func TestLoadConfig_TLS(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "mushak.yaml")
+
+	configContent := `tls:
+  mode: dns
+  dns_provider: cloudflare
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if cfg.TLS.Mode != "dns" {
+		t.Errorf("LoadConfig().TLS.Mode = %v, want dns", cfg.TLS.Mode)
+	}
+
+	if cfg.TLS.DNSProvider != "cloudflare" {
+		t.Errorf("LoadConfig().TLS.DNSProvider = %v, want cloudflare", cfg.TLS.DNSProvider)
+	}
+}
+
+func TestLoadConfig_Protection(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "mushak.yaml")
+
+	configContent := `protection:
+  rate_limit:
+    requests: 120
+    window: 1m
+  max_connections: 50
+  block_user_agents:
+    - AhrefsBot
+  block_paths:
+    - /wp-admin*
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if cfg.Protection.RateLimit.Requests != 120 {
+		t.Errorf("RateLimit.Requests = %v, want 120", cfg.Protection.RateLimit.Requests)
+	}
+	if cfg.Protection.RateLimit.Window != "1m" {
+		t.Errorf("RateLimit.Window = %v, want 1m", cfg.Protection.RateLimit.Window)
+	}
+	if cfg.Protection.MaxConnections != 50 {
+		t.Errorf("MaxConnections = %v, want 50", cfg.Protection.MaxConnections)
+	}
+	if len(cfg.Protection.BlockUserAgents) != 1 || cfg.Protection.BlockUserAgents[0] != "AhrefsBot" {
+		t.Errorf("BlockUserAgents = %v, want [AhrefsBot]", cfg.Protection.BlockUserAgents)
+	}
+	if len(cfg.Protection.BlockPaths) != 1 || cfg.Protection.BlockPaths[0] != "/wp-admin*" {
+		t.Errorf("BlockPaths = %v, want [/wp-admin*]", cfg.Protection.BlockPaths)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DiffConfigKeys compares two mushak.yaml files and returns the settings that
+// were added, removed or changed, as dotted paths like "watch.duration".
+// An empty file is treated as having no settings.
+func DiffConfigKeys(before, after []byte) (added, removed, changed []string, err error) {
+	var a, b map[string]interface{}
+	if err := yaml.Unmarshal(before, &a); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := yaml.Unmarshal(after, &b); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	beforeKeys := flattenConfig("", a)
+	afterKeys := flattenConfig("", b)
+
+	for key, value := range afterKeys {
+		old, ok := beforeKeys[key]
+		switch {
+		case !ok:
+			added = append(added, key)
+		case !reflect.DeepEqual(old, value):
+			changed = append(changed, key)
+		}
+	}
+	for key := range beforeKeys {
+		if _, ok := afterKeys[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed, nil
+}
+
+// flattenConfig maps dotted paths to the values of nested settings. Lists are
+// compared as a whole.
+func flattenConfig(prefix string, m map[string]interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	for key, value := range m {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			for k, v := range flattenConfig(path, nested) {
+				flat[k] = v
+			}
+			continue
+		}
+		flat[path] = value
+	}
+	return flat
+}
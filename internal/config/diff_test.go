@@ -0,0 +1,32 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffConfigKeys(t *testing.T) {
+	before := []byte("internal_port: 3000\nhealth_path: /\nwatch:\n  duration: 2m\npersistent_services:\n  - postgres\n")
+	after := []byte("internal_port: 8080\nwatch:\n  duration: 2m\n  max_restarts: 1\npersistent_services:\n  - postgres\n  - redis\n")
+
+	added, removed, changed, err := DiffConfigKeys(before, after)
+	if err != nil {
+		t.Fatalf("DiffConfigKeys() error = %v", err)
+	}
+
+	if want := []string{"watch.max_restarts"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added = %v, want %v", added, want)
+	}
+	if want := []string{"health_path"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if want := []string{"internal_port", "persistent_services"}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+
+	// A release without mushak.yaml
+	added, _, _, err = DiffConfigKeys(nil, []byte("internal_port: 80\n"))
+	if err != nil || !reflect.DeepEqual(added, []string{"internal_port"}) {
+		t.Errorf("DiffConfigKeys(nil, ...) = %v, %v", added, err)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envSchemaKeyPattern restricts schema keys to names that are safe to use in
+// the deploy hook's shell checks
+var envSchemaKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// The same checks are done in bash by the post-receive hook, so they are
+// written as POSIX extended regular expressions
+var envTypePatterns = map[string]*regexp.Regexp{
+	"int":    regexp.MustCompile(`^-?[0-9]+$`),
+	"number": regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`),
+	"url":    regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://[^[:space:]]+$`),
+}
+
+var envTypeDescriptions = map[string]string{
+	"int":    "an integer",
+	"number": "a number",
+	"bool":   "a boolean (true, false, 1, 0, yes or no)",
+	"url":    "a URL",
+}
+
+// Keys returns the variables in the schema, sorted
+func (e EnvConfig) Keys() []string {
+	keys := make([]string, 0, len(e.Schema))
+	for key := range e.Schema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Check reports mistakes in the schema itself, such as unknown types,
+// invalid patterns or defaults that fail their own rules
+func (e EnvConfig) Check() error {
+	for _, key := range e.Keys() {
+		s := e.Schema[key]
+
+		if !envSchemaKeyPattern.MatchString(key) {
+			return fmt.Errorf("env.schema: invalid variable name %q", key)
+		}
+
+		switch s.Type {
+		case "", "string", "int", "number", "bool", "url":
+		default:
+			return fmt.Errorf("env.schema.%s: unknown type %q (use string, int, number, bool or url)", key, s.Type)
+		}
+
+		if s.Pattern != "" {
+			if _, err := regexp.CompilePOSIX(s.Pattern); err != nil {
+				return fmt.Errorf("env.schema.%s: invalid pattern: %w", key, err)
+			}
+		}
+
+		if strings.ContainsAny(s.Pattern+s.Default, "\n\x1f") {
+			return fmt.Errorf("env.schema.%s: pattern and default must be a single line", key)
+		}
+
+		if s.Default != "" {
+			if problem := s.check(s.Default); problem != "" {
+				return fmt.Errorf("env.schema.%s: default %s", key, problem)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks vars against the schema. It returns one problem per invalid
+// or missing variable, and the variables that are missing but have a default.
+// An empty value counts as not set.
+func (e EnvConfig) Validate(vars map[string]string) (problems []string, defaulted []string) {
+	for _, key := range e.Keys() {
+		s := e.Schema[key]
+		value := vars[key]
+
+		if value == "" {
+			switch {
+			case s.Default != "":
+				defaulted = append(defaulted, key)
+			case s.Required:
+				problems = append(problems, fmt.Sprintf("%s: required but not set", key))
+			}
+			continue
+		}
+
+		if problem := s.check(value); problem != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", key, problem))
+		}
+	}
+
+	return problems, defaulted
+}
+
+// check validates a value against the type and pattern
+func (s EnvVarSchema) check(value string) string {
+	switch s.Type {
+	case "bool":
+		switch strings.ToLower(value) {
+		case "true", "false", "1", "0", "yes", "no":
+		default:
+			return "must be " + envTypeDescriptions[s.Type]
+		}
+	case "int", "number", "url":
+		if !envTypePatterns[s.Type].MatchString(value) {
+			return "must be " + envTypeDescriptions[s.Type]
+		}
+	}
+
+	if s.Pattern != "" {
+		re, err := regexp.CompilePOSIX(s.Pattern)
+		if err != nil || !re.MatchString(value) {
+			return fmt.Sprintf("does not match pattern %s", s.Pattern)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEnvConfig_Validate(t *testing.T) {
+	env := EnvConfig{Schema: map[string]EnvVarSchema{
+		"DATABASE_URL": {Required: true, Type: "url", Pattern: "^postgres://"},
+		"PORT":         {Type: "int", Default: "3000"},
+		"RATIO":        {Type: "number"},
+		"DEBUG":        {Type: "bool"},
+		"API_KEY":      {Required: true},
+		"OPTIONAL":     {},
+	}}
+
+	tests := []struct {
+		name      string
+		vars      map[string]string
+		problems  []string
+		defaulted []string
+	}{
+		{
+			name: "valid",
+			vars: map[string]string{
+				"DATABASE_URL": "postgres://db/app",
+				"PORT":         "8080",
+				"RATIO":        "0.5",
+				"DEBUG":        "TRUE",
+				"API_KEY":      "x",
+			},
+		},
+		{
+			name:      "missing",
+			vars:      map[string]string{"API_KEY": ""},
+			problems:  []string{"API_KEY: required but not set", "DATABASE_URL: required but not set"},
+			defaulted: []string{"PORT"},
+		},
+		{
+			name: "invalid",
+			vars: map[string]string{
+				"DATABASE_URL": "mysql://db/app",
+				"PORT":         "80a",
+				"RATIO":        "1.",
+				"DEBUG":        "maybe",
+				"API_KEY":      "x",
+			},
+			problems: []string{
+				"DATABASE_URL: does not match pattern ^postgres://",
+				"DEBUG: must be a boolean (true, false, 1, 0, yes or no)",
+				"PORT: must be an integer",
+				"RATIO: must be a number",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, defaulted := env.Validate(tt.vars)
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("Validate() problems = %q, want %q", problems, tt.problems)
+			}
+			if !reflect.DeepEqual(defaulted, tt.defaulted) {
+				t.Errorf("Validate() defaulted = %v, want %v", defaulted, tt.defaulted)
+			}
+		})
+	}
+}
+
+func TestEnvConfig_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  map[string]EnvVarSchema
+		wantErr bool
+	}{
+		{"valid", map[string]EnvVarSchema{"PORT": {Type: "int", Default: "3000", Pattern: "^[0-9]{4}$"}}, false},
+		{"unknown type", map[string]EnvVarSchema{"PORT": {Type: "integer"}}, true},
+		{"invalid pattern", map[string]EnvVarSchema{"A": {Pattern: "("}}, true},
+		{"perl class", map[string]EnvVarSchema{"A": {Pattern: `^\d+$`}}, true},
+		{"invalid default", map[string]EnvVarSchema{"PORT": {Type: "int", Default: "abc"}}, true},
+		{"invalid key", map[string]EnvVarSchema{"A-B": {}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := EnvConfig{Schema: tt.schema}.Check()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_EnvSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mushak.yaml")
+	content := `env:
+  schema:
+    DATABASE_URL:
+      required: true
+      pattern: "^postgres://"
+    PORT:
+      type: int
+      default: "3000"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	want := map[string]EnvVarSchema{
+		"DATABASE_URL": {Required: true, Pattern: "^postgres://"},
+		"PORT":         {Type: "int", Default: "3000"},
+	}
+	if !reflect.DeepEqual(cfg.Env.Schema, want) {
+		t.Errorf("Env.Schema = %+v, want %+v", cfg.Env.Schema, want)
+	}
+}
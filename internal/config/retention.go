@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetentionReleases is the number of releases kept when retention.releases is not set
+const DefaultRetentionReleases = 3
+
+// KeepReleases returns the number of newest releases to keep
+func (r RetentionConfig) KeepReleases() int {
+	if r.Releases == 0 {
+		return DefaultRetentionReleases
+	}
+	return r.Releases
+}
+
+// MinAgeDuration parses min_age. Besides Go durations like "48h" it accepts
+// whole days like "7d". An empty min_age is zero.
+func (r RetentionConfig) MinAgeDuration() (time.Duration, error) {
+	if r.MinAge == "" {
+		return 0, nil
+	}
+
+	if days, ok := strings.CutSuffix(r.MinAge, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid retention.min_age %q", r.MinAge)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(r.MinAge)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid retention.min_age %q: use a duration like 7d or 48h", r.MinAge)
+	}
+	return d, nil
+}
+
+// Check validates the retention settings
+func (r RetentionConfig) Check() error {
+	if r.Releases < 0 {
+		return fmt.Errorf("invalid retention.releases %d: must be at least 1", r.Releases)
+	}
+	_, err := r.MinAgeDuration()
+	return err
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionConfig_MinAgeDuration(t *testing.T) {
+	tests := []struct {
+		minAge  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{minAge: "", want: 0},
+		{minAge: "7d", want: 7 * 24 * time.Hour},
+		{minAge: "48h", want: 48 * time.Hour},
+		{minAge: "90m", want: 90 * time.Minute},
+		{minAge: "1.5d", wantErr: true},
+		{minAge: "-1h", wantErr: true},
+		{minAge: "week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.minAge, func(t *testing.T) {
+			got, err := RetentionConfig{MinAge: tt.minAge}.MinAgeDuration()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MinAgeDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MinAgeDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetentionConfig_KeepReleases(t *testing.T) {
+	if got := (RetentionConfig{}).KeepReleases(); got != DefaultRetentionReleases {
+		t.Errorf("KeepReleases() = %d, want default %d", got, DefaultRetentionReleases)
+	}
+	if got := (RetentionConfig{Releases: 10}).KeepReleases(); got != 10 {
+		t.Errorf("KeepReleases() = %d, want 10", got)
+	}
+	if err := (RetentionConfig{Releases: -1}).Check(); err == nil {
+		t.Error("Check() should reject negative releases")
+	}
+}
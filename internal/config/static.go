@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// AppTypeStatic is the type of apps whose files are served by Caddy without
+// a container
+const AppTypeStatic = "static"
+
+// DefaultStaticImage is the image static build commands run in
+const DefaultStaticImage = "node:lts-alpine"
+
+// staticImagePattern matches image references like node:20-alpine
+var staticImagePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/:@-]*$`)
+
+// IsStatic reports whether the app is a static site
+func (c *AppConfig) IsStatic() bool {
+	return c != nil && c.Type == AppTypeStatic
+}
+
+// CheckType returns an error if type or the static settings are invalid. A
+// nil config is a container app with the defaults.
+func (c *AppConfig) CheckType() error {
+	if c == nil {
+		return nil
+	}
+	if c.Type != "" && c.Type != AppTypeStatic {
+		return fmt.Errorf("invalid type %q: use static, or leave it out for container apps", c.Type)
+	}
+	if !c.IsStatic() {
+		return nil
+	}
+	if c.Image != "" {
+		return fmt.Errorf("image can't be deployed as a static site")
+	}
+	return c.Static.Check()
+}
+
+// BuildImage returns the image the build command runs in
+func (s StaticConfig) BuildImage() string {
+	if s.Image == "" {
+		return DefaultStaticImage
+	}
+	return s.Image
+}
+
+// OutputDir returns the directory with the files to serve, relative to the
+// repository root, or "" if static.output is needed
+func (s StaticConfig) OutputDir() string {
+	switch {
+	case s.Output != "":
+		return path.Clean(s.Output)
+	case s.Build != "":
+		return "dist"
+	}
+	return ""
+}
+
+// Check returns an error if the static settings can't be used safely by the
+// deploy hook
+func (s StaticConfig) Check() error {
+	if strings.ContainsAny(s.Build, "\r\n") {
+		return fmt.Errorf("static.build must be a single line, use && to run several commands")
+	}
+	if s.Image != "" && !staticImagePattern.MatchString(s.Image) {
+		return fmt.Errorf("invalid static.image %q", s.Image)
+	}
+	if s.Output != "" && (!buildPathPattern.MatchString(s.Output) || path.IsAbs(s.Output) ||
+		strings.HasPrefix(path.Clean(s.Output), "..")) {
+		return fmt.Errorf("invalid static.output %q: must be a relative path inside the repository", s.Output)
+	}
+	// The repository root holds secrets.env.enc, compose files and other files
+	// that must not be published
+	if dir := s.OutputDir(); dir == "" || dir == "." {
+		return fmt.Errorf("static.output must name the directory with the site's files, e.g. public: the repository root can't be published")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import "testing"
+
+func TestStaticConfig_OutputDir(t *testing.T) {
+	tests := []struct {
+		static StaticConfig
+		want   string
+	}{
+		{static: StaticConfig{}, want: ""},
+		{static: StaticConfig{Build: "npm run build"}, want: "dist"},
+		{static: StaticConfig{Build: "hugo", Output: "public/"}, want: "public"},
+		{static: StaticConfig{Output: "./site"}, want: "site"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.static.OutputDir(); got != tt.want {
+			t.Errorf("%+v.OutputDir() = %q, want %q", tt.static, got, tt.want)
+		}
+	}
+}
+
+func TestAppConfig_CheckType(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AppConfig
+		wantErr bool
+	}{
+		{name: "container app", cfg: AppConfig{}},
+		{name: "static without build", cfg: AppConfig{Type: AppTypeStatic, Static: StaticConfig{Output: "public"}}},
+		{
+			name: "static with build",
+			cfg: AppConfig{Type: AppTypeStatic, Static: StaticConfig{
+				Build:  "npm ci && npm run build",
+				Image:  "node:20-alpine",
+				Output: "build",
+			}},
+		},
+		{name: "unknown type", cfg: AppConfig{Type: "lambda"}, wantErr: true},
+		{name: "static with image", cfg: AppConfig{Type: AppTypeStatic, Image: "nginx:alpine"}, wantErr: true},
+		{name: "multiline build", cfg: AppConfig{Type: AppTypeStatic, Static: StaticConfig{Build: "npm ci\nnpm run build"}}, wantErr: true},
+		// The repository root would publish secrets.env.enc and the rest of the checkout
+		{name: "static without output", cfg: AppConfig{Type: AppTypeStatic}, wantErr: true},
+		{name: "repository root as output", cfg: AppConfig{Type: AppTypeStatic, Static: StaticConfig{Output: "."}}, wantErr: true},
+		{name: "repository root with build", cfg: AppConfig{Type: AppTypeStatic, Static: StaticConfig{Build: "make", Output: "./"}}, wantErr: true},
+		{name: "invalid image", cfg: AppConfig{Type: AppTypeStatic, Static: StaticConfig{Image: "node 20"}}, wantErr: true},
+		{name: "output outside repository", cfg: AppConfig{Type: AppTypeStatic, Static: StaticConfig{Output: "../site"}}, wantErr: true},
+		{name: "absolute output", cfg: AppConfig{Type: AppTypeStatic, Static: StaticConfig{Output: "/var/www"}}, wantErr: true},
+	}
+
+	var nilCfg *AppConfig
+	if err := nilCfg.CheckType(); err != nil {
+		t.Errorf("CheckType() on nil config error = %v, want nil", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.CheckType()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
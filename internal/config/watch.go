@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults for the watch window thresholds
+const (
+	DefaultWatchMaxErrorRate = 5.0
+	DefaultWatchMinRequests  = 20
+)
+
+// Window returns how long to watch a release. Zero means the watch is disabled.
+func (w WatchConfig) Window() (time.Duration, error) {
+	if w.Duration == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(w.Duration)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid watch.duration %q: use a duration like 2m", w.Duration)
+	}
+	return d, nil
+}
+
+// ErrorRateLimit returns the highest tolerated percentage of 5xx responses
+func (w WatchConfig) ErrorRateLimit() float64 {
+	if w.MaxErrorRate == 0 {
+		return DefaultWatchMaxErrorRate
+	}
+	return w.MaxErrorRate
+}
+
+// RequestThreshold returns how many requests are needed before the error rate is judged
+func (w WatchConfig) RequestThreshold() int {
+	if w.MinRequests == 0 {
+		return DefaultWatchMinRequests
+	}
+	return w.MinRequests
+}
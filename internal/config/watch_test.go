@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchConfig(t *testing.T) {
+	var w WatchConfig
+	if d, err := w.Window(); err != nil || d != 0 {
+		t.Errorf("Window() = %v, %v, want disabled", d, err)
+	}
+	if w.ErrorRateLimit() != DefaultWatchMaxErrorRate || w.RequestThreshold() != DefaultWatchMinRequests {
+		t.Error("empty watch config should use the default thresholds")
+	}
+
+	w = WatchConfig{Duration: "2m", MaxErrorRate: 1.5, MinRequests: 100}
+	if d, err := w.Window(); err != nil || d != 2*time.Minute {
+		t.Errorf("Window() = %v, %v, want 2m", d, err)
+	}
+	if w.ErrorRateLimit() != 1.5 || w.RequestThreshold() != 100 {
+		t.Error("configured thresholds should be used")
+	}
+
+	if _, err := (WatchConfig{Duration: "soon"}).Window(); err == nil {
+		t.Error("Window() should reject invalid durations")
+	}
+}
@@ -0,0 +1,189 @@
+package hooks
+
+import "strings"
+
+// The scripts below prepare a release's environment. They are shared by the
+// post-receive hook and the scripts that start containers outside of it, and
+// expect $APP_NAME to be set and the release directory to be the working directory.
+
+// CopyEnvFilesScript copies the app's env file and per-service env files
+// from /var/www/$APP_NAME into the release directory
+const CopyEnvFilesScript = `# Copy environment file (try .env.prod first, then .env)
+if [ -f "/var/www/$APP_NAME/.env.prod" ]; then
+    echo "→ Loading environment variables from .env.prod..."
+    cp "/var/www/$APP_NAME/.env.prod" .env.prod
+    # Also copy to .env for compatibility
+    cp "/var/www/$APP_NAME/.env.prod" .env
+elif [ -f "/var/www/$APP_NAME/.env" ]; then
+    echo "→ Loading environment variables from .env..."
+    cp "/var/www/$APP_NAME/.env" .env
+else
+    echo "⚠ No .env.prod or .env file found. Use 'mushak env set' to configure environment variables."
+fi
+
+# Copy per-service environment files (set with 'mushak env set --service')
+rm -rf .env.d
+if ls /var/www/$APP_NAME/.env.d/*.env > /dev/null 2>&1; then
+    echo "→ Loading per-service environment files..."
+    mkdir -p .env.d
+    cp /var/www/$APP_NAME/.env.d/*.env .env.d/
+fi
+`
+
+// DecryptSecretsScript verifies and decrypts secrets.env.enc, if the release
+// has one, and sets $SECRETS_ENV to the decrypted file. The plaintext only
+// exists while the script runs: an EXIT trap deletes it once the containers
+// are created, and restarts and rollbacks decrypt again. Scripts that set an
+// EXIT trap of their own afterwards must call remove_decrypted_secrets from it.
+const DecryptSecretsScript = `# Decrypt committed secrets into memory-backed storage. They are handed to
+# the containers and never written to the release directory.
+SECRETS_ENV=""
+remove_decrypted_secrets() {
+    if [ -n "$SECRETS_ENV" ]; then
+        rm -f "$SECRETS_ENV"
+    fi
+}
+if [ -f "secrets.env.enc" ]; then
+    echo "→ Decrypting secrets.env.enc..."
+    if [ ! -f "/var/www/$APP_NAME/.secrets.key" ]; then
+        echo "ERROR: secrets.env.enc found but no secrets key on the server. Run 'mushak deploy' with the team key available" >&2
+        exit 1
+    fi
+
+    # HMAC-SHA256 with the key given as hex. Computed with printf, a shell
+    # builtin, so the key never shows up in the process list
+    hmac_sha256() {
+        local key=$1 ipad="" opad="" byte hex i
+        for ((i = 0; i < 64; i++)); do
+            byte=0
+            [ $i -lt $((${#key} / 2)) ] && byte=$((0x${key:$((i * 2)):2}))
+            printf -v hex '\\x%02x' $((byte ^ 0x36)); ipad+=$hex
+            printf -v hex '\\x%02x' $((byte ^ 0x5c)); opad+=$hex
+        done
+        { printf "$opad"; { printf "$ipad"; cat; } | openssl dgst -sha256 -binary; } | openssl dgst -sha256 -r | cut -c1-64
+    }
+
+    # The ciphertext is authenticated, so a modified file is rejected before decryption
+    SECRETS_MAC=$(grep "^hmac=" secrets.env.enc | cut -d= -f2 | tr -d '[:space:]')
+    SECRETS_MAC_KEY=$(printf 'mushak-secrets-mac:%s' "$(cat /var/www/$APP_NAME/.secrets.key)" | openssl dgst -sha256 -r | cut -c1-64)
+    if [ -z "$SECRETS_MAC" ] || [ "$SECRETS_MAC" != "$(grep -v "^hmac=" secrets.env.enc | tr -d '[:space:]' | hmac_sha256 "$SECRETS_MAC_KEY")" ]; then
+        echo "ERROR: secrets.env.enc failed verification. Is the server's secrets key up to date, and was the file written by 'mushak secrets edit'?" >&2
+        exit 1
+    fi
+
+    SECRETS_DIR="/dev/shm/mushak-$APP_NAME"
+    SECRETS_ENV="$SECRETS_DIR/$(basename "$PWD").env"
+    mkdir -p "$SECRETS_DIR" && chmod 700 "$SECRETS_DIR"
+    # Containers keep their environment once created, so the plaintext is
+    # deleted when the script ends, whether it succeeds or not
+    trap remove_decrypted_secrets EXIT
+    if ! grep -v "^hmac=" secrets.env.enc | (umask 077; openssl enc -d -aes-256-cbc -pbkdf2 -iter 100000 -md sha256 -a -pass file:/var/www/$APP_NAME/.secrets.key -out "$SECRETS_ENV") 2>/dev/null; then
+        echo "ERROR: Failed to decrypt secrets.env.enc. Is the server's secrets key up to date?" >&2
+        exit 1
+    fi
+fi
+`
+
+// EnvSchemaScript validates the environment against the env schema synced from
+// mushak.yaml and appends defaults for missing variables to .env. Run it after
+// DecryptSecretsScript. It also defines env_value, which prints a variable's value,
+// and check_service_env_schema, which the compose setup runs for every
+// application service with its own env file.
+const EnvSchemaScript = `# Prints the value of $1 from the env files given after it, the first file
+# setting it wins (default: secrets, then .env). Quotes and inline comments are stripped
+env_value() {
+    local key=$1 file line value
+    shift
+    [ $# -eq 0 ] && set -- "$SECRETS_ENV" .env
+    for file in "$@"; do
+        if [ -z "$file" ] || [ ! -f "$file" ]; then
+            continue
+        fi
+        line=$(grep -E "^[[:space:]]*(export[[:space:]]+)?$key[[:space:]]*=" "$file" | tail -1)
+        value="${line#*=}"
+        value="${value#"${value%%[![:space:]]*}"}"
+        case "$value" in
+            \"*) value="${value#\"}"; value="${value%%\"*}" ;;
+            \'*) value="${value#\'}"; value="${value%%\'*}" ;;
+            *) value="${value%%[[:space:]]#*}"; value="${value%"${value##*[![:space:]]}"}" ;;
+        esac
+        if [ -n "$value" ]; then
+            echo "$value"
+            return
+        fi
+    done
+}
+
+# Validates the variables of the env files given after $1 and $2 (highest
+# precedence first) against env.schema from mushak.yaml, synced by 'mushak deploy'.
+# Defaults for missing variables are appended to $2. $1 names the service, if any
+check_env_schema() {
+    local service=$1 target=$2 key required type default pattern value error
+    local errors=()
+    shift 2
+
+    while IFS=$'\x1f' read -r key required type default pattern; do
+        [ -z "$key" ] && continue
+        value=$(env_value "$key" "$@")
+
+        if [ -z "$value" ]; then
+            if [ -n "$default" ]; then
+                echo "  $key not set${service:+ for $service}, using default"
+                echo "$key=$default" >> "$target"
+                [ "$target" = ".env" ] && [ -f ".env.prod" ] && echo "$key=$default" >> .env.prod
+            elif [ "$required" = "1" ]; then
+                errors+=("$key: required but not set")
+            fi
+            continue
+        fi
+
+        case "$type" in
+            int) [[ "$value" =~ ^-?[0-9]+$ ]] || errors+=("$key: must be an integer") ;;
+            number) [[ "$value" =~ ^-?[0-9]+(\.[0-9]+)?$ ]] || errors+=("$key: must be a number") ;;
+            bool) [[ "${value,,}" =~ ^(true|false|1|0|yes|no)$ ]] || errors+=("$key: must be a boolean (true, false, 1, 0, yes or no)") ;;
+            url) [[ "$value" =~ ^[a-zA-Z][a-zA-Z0-9+.-]*://[^[:space:]]+$ ]] || errors+=("$key: must be a URL") ;;
+        esac
+
+        if [ -n "$pattern" ] && ! [[ "$value" =~ $pattern ]]; then
+            errors+=("$key: does not match pattern $pattern")
+        fi
+    done < "/var/www/$APP_NAME/.env-schema"
+
+    if [ ${#errors[@]} -gt 0 ]; then
+        echo "ERROR: Environment${service:+ of service $service} does not match env.schema in mushak.yaml:" >&2
+        for error in "${errors[@]}"; do
+            echo "  - $error" >&2
+        done
+        echo "Use 'mushak env set${service:+ --service $service} KEY=VALUE' to fix it" >&2
+        return 1
+    fi
+}
+
+# A compose service with its own env file gets secrets first, then
+# .env.d/<service>.env, then .env
+check_service_env_schema() {
+    if [ ! -f "/var/www/$APP_NAME/.env-schema" ] || [ ! -f ".env.d/$1.env" ]; then
+        return 0
+    fi
+    check_env_schema "$1" ".env.d/$1.env" "$SECRETS_ENV" ".env.d/$1.env" .env
+}
+
+# Validate the environment here, so a missing variable fails the deploy
+# instead of the health check
+if [ -f "/var/www/$APP_NAME/.env-schema" ]; then
+    echo "→ Validating environment variables..."
+    check_env_schema "" .env "$SECRETS_ENV" .env || exit 1
+    echo "✓ Environment variables valid"
+fi
+`
+
+// indent prefixes every non-empty line of script with prefix
+func indent(script, prefix string) string {
+	lines := strings.Split(strings.TrimSuffix(script, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -4,8 +4,19 @@ import (
 	"fmt"
 )
 
-// GeneratePostReceiveHook generates the post-receive hook script
-func GeneratePostReceiveHook(appName, domain, branch string, noCache bool, internalPort int, healthPath string, healthTimeout int) string {
+// DeploymentSkippedNotice is printed by the post-receive hook when it skips a
+// push that changes nothing in a monorepo app's directory. 'mushak deploy'
+// looks for it to know that no new release was deployed.
+const DeploymentSkippedNotice = "No new release deployed"
+
+// GeneratePostReceiveHook generates the post-receive hook script. rootDir is
+// the app's directory in a monorepo, "" for the whole repository.
+func GeneratePostReceiveHook(appName, domain, branch, rootDir string, skipUnchanged, noCache bool, internalPort int, healthPath string, healthTimeout int) string {
+	skip := 0
+	if skipUnchanged {
+		skip = 1
+	}
+
 	buildOpts := ""
 	if noCache {
 		buildOpts = "--no-cache"
@@ -25,6 +36,8 @@ set -e
 APP_NAME="%s"
 DOMAIN="%s"
 DEPLOY_BRANCH="%s"
+ROOT_DIR="%s"
+SKIP_UNCHANGED=%d
 BUILD_OPTS="%s"
 
 # Configured defaults from mushak init/deploy
@@ -37,31 +50,72 @@ INTERNAL_PORT=80
 HEALTH_PATH="/"
 HEALTH_TIMEOUT=30
 
+%s
+%s
 echo "========================================="
 echo "Mushak Deployment Started"
 echo "========================================="
 
 while read oldrev newrev refname; do
-    # Get the branch name
-    BRANCH=$(git rev-parse --symbolic --abbrev-ref $refname)
+    # 'mushak deploy --dirty' and '--archive' deploy uncommitted work from a scratch ref
+    DIRTY=0
+    if [ "$refname" = "refs/mushak/dirty" ]; then
+        DIRTY=1
+        BRANCH="(uncommitted changes)"
+    else
+        # Get the branch name
+        BRANCH=$(git rev-parse --symbolic --abbrev-ref $refname)
+    fi
 
     echo "Branch: $BRANCH"
 
     # Only deploy configured branch
-    if [ "$BRANCH" != "$DEPLOY_BRANCH" ]; then
+    if [ $DIRTY -eq 0 ] && [ "$BRANCH" != "$DEPLOY_BRANCH" ]; then
         echo "⚠ Skipping deployment for branch: $BRANCH (configured: $DEPLOY_BRANCH)"
         exit 0
     fi
 
-    # Get short commit SHA
-    SHA=$(git rev-parse --short $newrev)
+    # Get short commit SHA. Uncommitted work is named after its content so it
+    # can't be mistaken for a real commit
+    if [ $DIRTY -eq 1 ]; then
+        SHA="dirty-$(git rev-parse --short=7 "$newrev^{tree}")"
+    else
+        SHA=$(git rev-parse --short $newrev)
+    fi
     echo "Commit: $SHA"
 
+    # Commit details for the release metadata, read while we are still in the repository
+    COMMIT_SUBJECT=$(git log -1 --format=%%s $newrev)
+    COMMIT_AUTHOR=$(git log -1 --format='%%an <%%ae>' $newrev)
+
+    # 'mushak deploy' passes who is deploying as a push option
+    DEPLOYED_BY=$(whoami)
+    for i in $(seq 0 $((${GIT_PUSH_OPTION_COUNT:-0} - 1))); do
+        PUSH_OPTION_VAR="GIT_PUSH_OPTION_$i"
+        case "${!PUSH_OPTION_VAR}" in
+            deploy-user=*) DEPLOYED_BY="${!PUSH_OPTION_VAR#deploy-user=}" ;;
+        esac
+    done
+
     # Paths
     DEPLOY_DIR="/var/www/$APP_NAME/$SHA"
     CURRENT_LINK="/var/www/$APP_NAME/current"
     PROJECT_NAME="mushak-$APP_NAME-$SHA"
 
+    # Monorepo apps skip pushes that change nothing in their directory, compared
+    # with the live release (or the previous push). Redeploys always run
+    if [ $SKIP_UNCHANGED -eq 1 ] && [ -n "$ROOT_DIR" ] && [ $DIRTY -eq 0 ] && [ "$oldrev" != "$newrev" ] && [ -e "$CURRENT_LINK" ]; then
+        BASE_REV=$(grep "^commit=" "$CURRENT_LINK/.mushak-release" 2>/dev/null | cut -d= -f2)
+        if [ -z "$BASE_REV" ] || ! git cat-file -e "$BASE_REV^{commit}" 2>/dev/null; then
+            BASE_REV=$oldrev
+        fi
+        if [ "$BASE_REV" != "0000000000000000000000000000000000000000" ] && git diff --quiet "$BASE_REV" "$newrev" -- "$ROOT_DIR" 2>/dev/null; then
+            echo "✓ Nothing in $ROOT_DIR changed since $(git rev-parse --short "$BASE_REV"), skipping deployment"
+            echo "  %s"
+            exit 0
+        fi
+    fi
+
     # Function to sanitize docker-compose.yml (remove hardcoded ports)
     sanitize_docker_compose() {
         local file=$1
@@ -108,46 +162,246 @@ while read oldrev newrev refname; do
     echo ""
     echo "→ Finding available port..."
 
-    # Find a free port (8000-9000)
-    find_free_port() {
-        for port in {8000..9000}; do
-            if ! ss -tuln | grep -q ":$port "; then
-                echo $port
-                return 0
-            fi
-        done
-        echo "ERROR: No free ports available in range 8000-9000" >&2
-        exit 1
-    }
-
     HOST_PORT=$(find_free_port)
     echo "  Using port: $HOST_PORT"
 
     echo ""
     echo "→ Checking out code to $DEPLOY_DIR..."
 
-    # Create deployment directory
+    # Create deployment directory. .mushak-release marks it as a release, so
+    # cleanup removes it if the deploy fails; the metadata is written on success
     mkdir -p $DEPLOY_DIR
+    touch "$DEPLOY_DIR/.mushak-release"
 
-    # Checkout the code
-    GIT_WORK_TREE=$DEPLOY_DIR git checkout -f $newrev
+    # Checkout the code. Monorepo apps only get their directory, so the release
+    # looks like a repository of its own
+    if [ -n "$ROOT_DIR" ]; then
+        if ! git cat-file -e "$newrev:$ROOT_DIR" 2>/dev/null; then
+            echo "ERROR: $ROOT_DIR not found in $SHA" >&2
+            exit 1
+        fi
+        git archive "$newrev:$ROOT_DIR" | tar -x -C $DEPLOY_DIR
+        # Keep HEAD on the deployed commit, as checkout does, for redeploys
+        git update-ref --no-deref HEAD $newrev
+    else
+        GIT_WORK_TREE=$DEPLOY_DIR git checkout -f $newrev
+    fi
 
     cd $DEPLOY_DIR
 
     # Update stable symlink (used for infrastructure services consistency)
     ln -snf "$DEPLOY_DIR" "$CURRENT_LINK"
 
-    # Copy environment file (try .env.prod first, then .env)
-    if [ -f "/var/www/$APP_NAME/.env.prod" ]; then
-        echo "→ Loading environment variables from .env.prod..."
-        cp "/var/www/$APP_NAME/.env.prod" .env.prod
-        # Also copy to .env for compatibility
-        cp "/var/www/$APP_NAME/.env.prod" .env
-    elif [ -f "/var/www/$APP_NAME/.env" ]; then
-        echo "→ Loading environment variables from .env..."
-        cp "/var/www/$APP_NAME/.env" .env
-    else
-        echo "⚠ No .env.prod or .env file found. Use 'mushak env set' to configure environment variables."
+%s
+
+%s
+
+%s
+
+    # Build settings from mushak.yaml (synced by 'mushak deploy'). Empty values
+    # leave the compose file's own build settings alone
+    DOCKERFILE=""
+    BUILD_CONTEXT=""
+    BUILD_TARGET=""
+    BUILD_PLATFORM=""
+    BUILD_ARGS=()
+    BUILD_SSH=()
+    BUILD_SECRETS=()
+    BUILD_SECRET_IDS=()
+    BUILD_SECRET_FILES=()
+    BUILD_SECRETS_DIR=""
+    if [ -f "/var/www/$APP_NAME/.build" ]; then
+        while IFS= read -r setting; do
+            value="${setting#*=}"
+            case "${setting%%%%=*}" in
+                dockerfile) DOCKERFILE="$value" ;;
+                context) BUILD_CONTEXT="$value" ;;
+                target) BUILD_TARGET="$value" ;;
+                platform) BUILD_PLATFORM="$value" ;;
+                arg) BUILD_ARGS+=(--build-arg "$value") ;;
+                env_arg)
+                    # Taken from the environment, so values like public API URLs aren't committed
+                    ARG_VALUE=$(env_value "$value")
+                    if [ -n "$ARG_VALUE" ]; then
+                        BUILD_ARGS+=(--build-arg "$value=$ARG_VALUE")
+                    else
+                        echo "  ⚠ Build argument $value is not set in the environment"
+                    fi
+                    ;;
+                secret)
+                    # Secrets are mounted into RUN steps and never end up in the image
+                    secret_id="${value%%%%=*}"
+                    secret_source="${value#*=}"
+                    case "$secret_source" in
+                        env:*)
+                            if [ -z "$BUILD_SECRETS_DIR" ]; then
+                                BUILD_SECRETS_DIR=$(mktemp -d -p /dev/shm mushak-$APP_NAME-build.XXXXXX 2>/dev/null || mktemp -d)
+                                trap 'rm -rf "$BUILD_SECRETS_DIR"; remove_decrypted_secrets' EXIT
+                            fi
+                            secret_file="$BUILD_SECRETS_DIR/$secret_id"
+                            env_value "${secret_source#env:}" > "$secret_file"
+                            if [ ! -s "$secret_file" ]; then
+                                echo "ERROR: Build secret $secret_id: ${secret_source#env:} is not set in the environment" >&2
+                                exit 1
+                            fi
+                            ;;
+                        file:*)
+                            secret_file="${secret_source#file:}"
+                            [[ "$secret_file" == /* ]] || secret_file="/var/www/$APP_NAME/$secret_file"
+                            if [ ! -f "$secret_file" ]; then
+                                echo "ERROR: Build secret $secret_id: $secret_file not found on the server" >&2
+                                exit 1
+                            fi
+                            ;;
+                    esac
+                    BUILD_SECRETS+=(--secret "id=$secret_id,src=$secret_file")
+                    BUILD_SECRET_IDS+=("$secret_id")
+                    BUILD_SECRET_FILES+=("$secret_file")
+                    ;;
+                ssh)
+                    # 'agent' uses the agent 'mushak deploy' forwards, anything else is a key on the server
+                    if [ "$value" = "agent" ]; then
+                        if [ -z "$SSH_AUTH_SOCK" ]; then
+                            echo "ERROR: build.ssh forwards your SSH agent, but none was forwarded. Deploy with 'mushak deploy' and a running ssh-agent" >&2
+                            exit 1
+                        fi
+                        BUILD_SSH=(--ssh default)
+                    else
+                        [[ "$value" == /* ]] || value="/var/www/$APP_NAME/$value"
+                        BUILD_SSH=(--ssh "default=$value")
+                    fi
+                    ;;
+            esac
+        done < "/var/www/$APP_NAME/.build"
+    fi
+
+    # Static sites (type: static in mushak.yaml, synced by 'mushak deploy') are
+    # built in a throwaway container and served by Caddy from the release's
+    # public directory, without a container of their own
+    if [ -f "/var/www/$APP_NAME/.static" ]; then
+        STATIC_BUILD=""
+        STATIC_IMAGE="node:lts-alpine"
+        STATIC_OUTPUT=""
+        while IFS= read -r setting; do
+            value="${setting#*=}"
+            case "${setting%%%%=*}" in
+                build) STATIC_BUILD="$value" ;;
+                image) STATIC_IMAGE="$value" ;;
+                output) STATIC_OUTPUT="$value" ;;
+            esac
+        done < "/var/www/$APP_NAME/.static"
+
+        echo ""
+        echo "→ Building static site..."
+
+        # The repository root holds secrets.env.enc, compose files and other
+        # files that must not be published
+        if [ -z "$STATIC_OUTPUT" ] || [ "$STATIC_OUTPUT" = "." ]; then
+            echo "ERROR: static.output must name the directory with the site's files, e.g. public" >&2
+            echo "The repository root can't be published" >&2
+            exit 1
+        fi
+
+        # Redeploys of the same commit reuse the release directory. A public
+        # directory that is the output was just checked out again
+        if grep -qx "type=static" .mushak-release 2>/dev/null && [ "$STATIC_OUTPUT" != "public" ]; then
+            rm -rf public
+        fi
+
+        if [ -n "$STATIC_BUILD" ]; then
+            echo "  Running '$STATIC_BUILD' in $STATIC_IMAGE"
+            STATIC_ENV_OPTS=()
+            if [ -f ".env" ]; then
+                STATIC_ENV_OPTS+=(--env-file .env)
+            fi
+            if [ -n "$SECRETS_ENV" ]; then
+                STATIC_ENV_OPTS+=(--env-file "$SECRETS_ENV")
+            fi
+            # Run as the deploy user, so the output belongs to it and is removed with the release
+            if ! docker run --rm "${STATIC_ENV_OPTS[@]}" -u "$(id -u):$(id -g)" -e HOME=/tmp \
+                -v "$DEPLOY_DIR:/app" -w /app "$STATIC_IMAGE" sh -c "$STATIC_BUILD"; then
+                echo "ERROR: Static build failed" >&2
+                exit 1
+            fi
+        fi
+
+        if [ ! -d "$STATIC_OUTPUT" ]; then
+            echo "ERROR: Output directory $STATIC_OUTPUT not found" >&2
+            exit 1
+        fi
+
+        # Only the output is served. Env files and Git metadata never end up in it
+        STATIC_STAGE=$(mktemp -d -p "/var/www/$APP_NAME" .public.XXXXXX)
+        chmod 755 "$STATIC_STAGE"
+        tar -C "$STATIC_OUTPUT" --exclude='./.env*' --exclude=./.git --exclude=./.mushak-release --exclude=./mushak.yaml --exclude=./secrets.env.enc -cf - . | tar -C "$STATIC_STAGE" -xf -
+        rm -rf "$DEPLOY_DIR/public"
+        mv "$STATIC_STAGE" "$DEPLOY_DIR/public"
+        echo "  Copied $STATIC_OUTPUT to $DEPLOY_DIR/public ($(find "$DEPLOY_DIR/public" -type f | wc -l) files)"
+
+        echo ""
+        echo "→ Updating Caddy configuration..."
+
+%s
+
+        # Record deployment to manifest file (for rollback listing). Static sites have no port
+        DEPLOYMENTS_FILE="/var/www/$APP_NAME/.deployments"
+        echo "${SHA} $(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ) - static" >> "$DEPLOYMENTS_FILE"
+
+        # Record release metadata. type=static tells rollbacks and cleanup there is no image
+        CONFIG_FINGERPRINT="none"
+        if [ -f "$DEPLOY_DIR/mushak.yaml" ]; then
+            CONFIG_FINGERPRINT=$(sha256sum "$DEPLOY_DIR/mushak.yaml" | cut -c1-12)
+        fi
+        ENV_FINGERPRINT=$(cat "$DEPLOY_DIR/.env" "$DEPLOY_DIR"/.env.d/*.env 2>/dev/null | sha256sum | cut -c1-12)
+        cat > "$DEPLOY_DIR/.mushak-release" <<EOF
+commit=$newrev
+subject=$COMMIT_SUBJECT
+author=$COMMIT_AUTHOR
+deployed_by=$DEPLOYED_BY
+config=$CONFIG_FINGERPRINT
+env=$ENV_FINGERPRINT
+type=static
+EOF
+
+        echo ""
+%s
+
+        echo ""
+        echo "========================================="
+        echo "✓ Deployment Successful!"
+        echo "========================================="
+        echo "App: $APP_NAME"
+        echo "SHA: $SHA"
+        echo "Type: static"
+        echo "URL: https://$DOMAIN"
+        echo "========================================="
+        continue
+    fi
+
+    # Tells whether a compose service is built from source, so build-only
+    # settings aren't added to services that run an image
+    compose_service_builds() {
+        awk -v service="$1" '
+            /^[^ #]/ { in_service = 0 }
+            /^  [^ #]/ { in_service = ($0 ~ "^  " service ":") }
+            in_service && /^    build:/ { found = 1 }
+            END { exit !found }
+        ' "$COMPOSE_FILE"
+    }
+    DOCKERFILE_PATH="${BUILD_CONTEXT:-.}/${DOCKERFILE:-Dockerfile}"
+
+    # Apps without a Dockerfile are built with the one 'mushak deploy' generated for their stack
+    if [ ! -f "docker-compose.yml" ] && [ ! -f "docker-compose.yaml" ] && [ ! -f "$DOCKERFILE_PATH" ] && [ -f "/var/www/$APP_NAME/.dockerfile" ] && [ -d "${BUILD_CONTEXT:-.}" ]; then
+        cp "/var/www/$APP_NAME/.dockerfile" "$DOCKERFILE_PATH"
+        echo "  No Dockerfile found, using the generated one"
+        # Its COPY . takes the whole context, so keep the env files copied into
+        # the release and other secrets out of the image
+        if [ -f "/var/www/$APP_NAME/.dockerignore" ]; then
+            if [ -s "${BUILD_CONTEXT:-.}/.dockerignore" ]; then
+                echo "" >> "${BUILD_CONTEXT:-.}/.dockerignore"
+            fi
+            cat "/var/www/$APP_NAME/.dockerignore" >> "${BUILD_CONTEXT:-.}/.dockerignore"
+        fi
     fi
 
     # Sanitize docker-compose files to remove hardcoded ports
@@ -164,8 +418,15 @@ while read oldrev newrev refname; do
         DETECTED_PORT=$(grep -A 10 "web" docker-compose.yml | grep -A 5 "ports:" | grep -E "\-[[:space:]]*[\"\']?[0-9]+:[0-9]+[\"\']?" | head -1 | sed -E 's/.*:([0-9]+).*/\1/' || echo 0)
     fi
 
-    if [ "$DETECTED_PORT" -eq 0 ] && [ -f "Dockerfile" ]; then
-        DETECTED_PORT=$(grep -i "^EXPOSE" Dockerfile | head -1 | awk '{print $2}' || echo 0)
+    if [ "$DETECTED_PORT" -eq 0 ] && [ -f "$DOCKERFILE_PATH" ]; then
+        # Prefer the EXPOSE of the stage being built
+        if [ -n "$BUILD_TARGET" ]; then
+            DETECTED_PORT=$(awk -v target="$BUILD_TARGET" 'toupper($1) == "FROM" { stage = (tolower($NF) == tolower(target)) } stage && toupper($1) == "EXPOSE" { print $2; exit }' "$DOCKERFILE_PATH")
+        fi
+        if [ -z "$DETECTED_PORT" ] || [ "$DETECTED_PORT" = "0" ]; then
+            DETECTED_PORT=$(grep -i "^EXPOSE" "$DOCKERFILE_PATH" | head -1 | awk '{print $2}' || echo 0)
+        fi
+        DETECTED_PORT=${DETECTED_PORT:-0}
     fi
 
     # 2. Sanitize files
@@ -195,6 +456,8 @@ while read oldrev newrev refname; do
     # Read mushak.yaml if it exists (Highest priority for app settings)
     CUSTOM_PERSISTENT_SERVICES=""
     CACHE_LIMIT="24h"
+    DRAIN_SECONDS=10
+    STOP_GRACE_PERIOD=10
     if [ -f "mushak.yaml" ]; then
         echo "  Found mushak.yaml"
 
@@ -218,6 +481,16 @@ while read oldrev newrev refname; do
             echo "  Cache limit: $CACHE_LIMIT"
         fi
 
+        if grep -q "drain_seconds:" mushak.yaml; then
+            DRAIN_SECONDS=$(grep "drain_seconds:" mushak.yaml | head -1 | awk '{print $2}')
+            echo "  Drain seconds: $DRAIN_SECONDS"
+        fi
+
+        if grep -q "stop_grace_period:" mushak.yaml; then
+            STOP_GRACE_PERIOD=$(grep "stop_grace_period:" mushak.yaml | head -1 | awk '{print $2}')
+            echo "  Stop grace period: $STOP_GRACE_PERIOD"
+        fi
+
         # Read persistent_services array (simple parsing)
         if grep -q "persistent_services:" mushak.yaml; then
             CUSTOM_PERSISTENT_SERVICES=$(grep -A 10 "persistent_services:" mushak.yaml | grep "^  - " | sed 's/^  - //' | tr '\n' ' ')
@@ -230,6 +503,11 @@ while read oldrev newrev refname; do
     echo ""
     echo "→ Detecting build method..."
 
+    # Images built by 'mushak deploy --build local' are shipped before the push
+    # and deployed instead of building on the server
+    PREBUILT_REPO="mushak-${APP_NAME}-prebuilt"
+    PREBUILT=0
+
     # Detect docker-compose.yml or Dockerfile
     if [ -f "docker-compose.yml" ] || [ -f "docker-compose.yaml" ]; then
         echo "  Found docker-compose.yml"
@@ -299,40 +577,49 @@ while read oldrev newrev refname; do
         # Override container_name for ALL services to enable zero-downtime deployments
         # Infrastructure services get static names, app services get versioned names
 
-        cat > docker-compose.override.yml <<EOF
-version: '3'
-networks:
-  default:
-    external: true
-    name: $NETWORK_NAME
-services:
-EOF
+        compose_override_start
 
         # Generate configuration for all application services
         for app_svc in $APP_SERVICES; do
-            cat >> docker-compose.override.yml <<EOF
-  $app_svc:
-    container_name: ${PROJECT_NAME}-${app_svc}
-EOF
+            image=""
+            if docker image inspect "${PREBUILT_REPO}:${newrev}-${app_svc}" > /dev/null 2>&1; then
+                image="${PREBUILT_REPO}:${newrev}-${app_svc}"
+                PREBUILT=1
+            fi
+            compose_override_service "$app_svc" "$image"
+            if [ -n "$BUILD_PLATFORM" ]; then
+                echo "    platform: $BUILD_PLATFORM" >> docker-compose.override.yml
+            fi
 
-            # Add port mapping for the main web service
+            # Dockerfile, context and target from mushak.yaml apply to the web service,
+            # secrets to every service that is built
+            OVERRIDE_BUILD=()
             if [ "$app_svc" = "$SERVICE_NAME" ]; then
-                cat >> docker-compose.override.yml <<EOF
-    ports:
-      - "$HOST_PORT:$INTERNAL_PORT"
-EOF
+                if [ -n "$BUILD_CONTEXT" ]; then
+                    OVERRIDE_BUILD+=("context: $BUILD_CONTEXT")
+                fi
+                if [ -n "$DOCKERFILE" ]; then
+                    OVERRIDE_BUILD+=("dockerfile: $DOCKERFILE")
+                fi
+                if [ -n "$BUILD_TARGET" ]; then
+                    OVERRIDE_BUILD+=("target: $BUILD_TARGET")
+                fi
             fi
-
-            # Configure external_links for application services to reach infrastructure services
-            # This allows app services to find infra services by their service name (hostname aliasing)
-            if [ -n "$INFRA_SERVICES" ]; then
-                echo "    external_links:" >> docker-compose.override.yml
-                for infra_svc in $INFRA_SERVICES; do
-                    # Map static container name to service name
-                    # e.g. bareagent_postgres:postgres
-                    echo "      - ${APP_NAME}_${infra_svc}:${infra_svc}" >> docker-compose.override.yml
+            if [ ${#BUILD_SECRET_IDS[@]} -gt 0 ] && compose_service_builds "$app_svc"; then
+                OVERRIDE_BUILD+=("secrets:")
+                for secret_id in "${BUILD_SECRET_IDS[@]}"; do
+                    OVERRIDE_BUILD+=("  - $secret_id")
                 done
             fi
+            if [ ${#OVERRIDE_BUILD[@]} -gt 0 ]; then
+                echo "    build:" >> docker-compose.override.yml
+                for build_line in "${OVERRIDE_BUILD[@]}"; do
+                    echo "      $build_line" >> docker-compose.override.yml
+                done
+            fi
+
+            check_service_env_schema "$app_svc" || exit 1
+            compose_override_runtime "$app_svc"
         done
 
         # Keep infrastructure services with static names (app-specific, not versioned)
@@ -341,20 +628,41 @@ EOF
   $infra_svc:
     container_name: ${APP_NAME}_${infra_svc}
 EOF
+            if [ -f ".env.d/$infra_svc.env" ]; then
+                cat >> docker-compose.override.yml <<EOF
+    env_file:
+      - .env.d/$infra_svc.env
+EOF
+            fi
         done
 
+        # Build secrets are declared at the top level and referenced by the services
+        if [ ${#BUILD_SECRET_IDS[@]} -gt 0 ]; then
+            echo "secrets:" >> docker-compose.override.yml
+            for i in "${!BUILD_SECRET_IDS[@]}"; do
+                cat >> docker-compose.override.yml <<EOF
+  ${BUILD_SECRET_IDS[$i]}:
+    file: ${BUILD_SECRET_FILES[$i]}
+EOF
+            done
+        fi
+
         echo "  Created docker-compose.override.yml"
         echo "    - Overriding container names for zero-downtime deployments"
         echo "    - Configuring shared network: $NETWORK_NAME"
         if [ -n "$INFRA_SERVICES" ]; then
             echo "    - Configuring external links for infrastructure services"
         fi
+        if [ -d ".env.d" ]; then
+            echo "    - Adding per-service environment files"
+        fi
 
-    elif [ -f "Dockerfile" ]; then
-        echo "  Found Dockerfile"
+    elif [ -f "$DOCKERFILE_PATH" ]; then
+        echo "  Found $DOCKERFILE_PATH"
         BUILD_METHOD="dockerfile"
     else
         echo "ERROR: No Dockerfile or docker-compose.yml found" >&2
+        echo "Add one, or deploy with 'mushak deploy' to build with a generated Dockerfile (see 'mushak init --generate-dockerfile')" >&2
         exit 1
     fi
 
@@ -387,22 +695,25 @@ EOF
 
         # Build and deploy application services only
         # We use the SHA-versioned project name for zero-downtime updates
-        if [ -n "$APP_SERVICES" ]; then
+        if [ -n "$APP_SERVICES" ] && [ $PREBUILT -eq 1 ]; then
+            echo "  Deploying application services from locally built images..."
+            docker compose -p $PROJECT_NAME up -d --no-build --no-deps $APP_SERVICES
+        elif [ -n "$APP_SERVICES" ]; then
             echo "  Building and deploying application services..."
             # Use --no-deps to prevent Docker from trying to interact with the infra services in THIS project scope
             # (since they are now managed by the infra project)
             # Explicitly build first if build opts are present (e.g. --no-cache)
-            # 'up --build' doesn't support --no-cache so we handle it separately
-            if [[ "$BUILD_OPTS" == *"--no-cache"* ]]; then
-                docker compose -p $PROJECT_NAME build $BUILD_OPTS $APP_SERVICES
+            # 'up --build' doesn't support --no-cache, --build-arg or --ssh so we handle it separately
+            if [[ "$BUILD_OPTS" == *"--no-cache"* ]] || [ ${#BUILD_ARGS[@]} -gt 0 ] || [ ${#BUILD_SSH[@]} -gt 0 ]; then
+                docker compose -p $PROJECT_NAME build $BUILD_OPTS "${BUILD_ARGS[@]}" "${BUILD_SSH[@]}" $APP_SERVICES
                 docker compose -p $PROJECT_NAME up -d --no-deps $APP_SERVICES
             else
                 docker compose -p $PROJECT_NAME up -d --build $BUILD_OPTS --no-deps $APP_SERVICES
             fi
         else
             # Fallback: deploy everything if we couldn't categorize
-            if [[ "$BUILD_OPTS" == *"--no-cache"* ]]; then
-                docker compose -p $PROJECT_NAME build $BUILD_OPTS
+            if [[ "$BUILD_OPTS" == *"--no-cache"* ]] || [ ${#BUILD_ARGS[@]} -gt 0 ] || [ ${#BUILD_SSH[@]} -gt 0 ]; then
+                docker compose -p $PROJECT_NAME build $BUILD_OPTS "${BUILD_ARGS[@]}" "${BUILD_SSH[@]}"
                 docker compose -p $PROJECT_NAME up -d
             else
                 docker compose -p $PROJECT_NAME up -d --build $BUILD_OPTS
@@ -410,12 +721,28 @@ EOF
         fi
     else
         # Dockerfile
-        docker build $BUILD_OPTS -t $PROJECT_NAME .
+        if docker image inspect "${PREBUILT_REPO}:${newrev}" > /dev/null 2>&1; then
+            echo "  Using locally built image"
+            docker tag "${PREBUILT_REPO}:${newrev}" $PROJECT_NAME
+            PREBUILT=1
+        else
+            DOCKER_BUILD_OPTS=(-f "$DOCKERFILE_PATH")
+            if [ -n "$BUILD_TARGET" ]; then
+                DOCKER_BUILD_OPTS+=(--target "$BUILD_TARGET")
+            fi
+            if [ -n "$BUILD_PLATFORM" ]; then
+                DOCKER_BUILD_OPTS+=(--platform "$BUILD_PLATFORM")
+            fi
+            docker build $BUILD_OPTS "${DOCKER_BUILD_OPTS[@]}" "${BUILD_ARGS[@]}" "${BUILD_SECRETS[@]}" "${BUILD_SSH[@]}" -t $PROJECT_NAME "${BUILD_CONTEXT:-.}"
+        fi
 
         ENV_OPTS=""
         if [ -f ".env" ]; then
             ENV_OPTS="--env-file .env"
         fi
+        if [ -n "$SECRETS_ENV" ]; then
+            ENV_OPTS="$ENV_OPTS --env-file $SECRETS_ENV"
+        fi
 
         docker run -d --name $PROJECT_NAME $ENV_OPTS -p $HOST_PORT:$INTERNAL_PORT $PROJECT_NAME
         CONTAINER_NAME=$PROJECT_NAME
@@ -457,46 +784,20 @@ EOF
     echo ""
     echo "→ Updating Caddy configuration..."
 
-    # Update Caddy config
-    sudo tee /etc/caddy/apps/$APP_NAME.caddy > /dev/null <<EOF
-$DOMAIN {
-	reverse_proxy localhost:$HOST_PORT
-}
-EOF
-
-    # Reload Caddy
-    sudo systemctl reload caddy
+    # Remember the previous release's port so its connections can be drained
+    OLD_PORT=$(grep -oE "localhost:[0-9]+" /etc/caddy/apps/$APP_NAME.caddy 2>/dev/null | head -1 | cut -d: -f2)
 
-    echo "  Caddy updated and reloaded"
+%s
+%s
 
     echo ""
     echo "→ Cleaning up old containers..."
 
-    # Stop and remove old containers (exclude current SHA)
-    # IMPORTANT: We use 'docker compose down' WITHOUT -v flag to preserve volumes
-    # This ensures database data and other persistent storage is NOT deleted
-    if [ "$BUILD_METHOD" = "compose" ]; then
-        # Find old compose projects for this app
-        docker ps -a --format "{{.Names}}" | grep "^mushak-$APP_NAME-" | grep -v "$SHA" | while read container; do
-            echo "  Stopping $container"
-            # Extract project name from container name
-            PROJECT=$(echo $container | sed 's/-[^-]*$//')
-            # Note: No -v flag = volumes are preserved
-            docker compose -p $PROJECT down 2>/dev/null || true
-        done
-        echo "  (Volumes preserved)"
-    else
-        # Find old containers for this app
-        docker ps -a --format "{{.Names}}" | grep "^mushak-$APP_NAME-" | grep -v "$SHA" | while read container; do
-            echo "  Stopping $container"
-            docker stop $container 2>/dev/null || true
-            docker rm $container 2>/dev/null || true
-        done
-    fi
+    # Containers of env restarts of the same SHA have their own project, so
+    # they are matched by project rather than by SHA
+%s
 
-    # Cleanup old deployment directories (keep last 3)
     cd /var/www/$APP_NAME
-    ls -t -d */ 2>/dev/null | grep -v "current" | tail -n +4 | xargs -r rm -rf
 
     echo ""
     echo "→ Tagging images for rollback..."
@@ -505,17 +806,25 @@ EOF
     # We tag images with the app name and SHA for easy identification
     IMAGE_REPO="mushak-${APP_NAME}"
     DEPLOYMENTS_FILE="/var/www/$APP_NAME/.deployments"
-    KEEP_IMAGES=3
 
     if [ "$BUILD_METHOD" = "compose" ]; then
-        # For compose, tag the built images for the main web service
-        # Get the image ID of the service we just deployed
-        BUILT_IMAGE=$(docker compose -p $PROJECT_NAME images -q $SERVICE_NAME 2>/dev/null | head -1)
-        if [ -n "$BUILT_IMAGE" ]; then
-            docker tag "$BUILT_IMAGE" "${IMAGE_REPO}:${SHA}" 2>/dev/null || true
-            docker tag "$BUILT_IMAGE" "${IMAGE_REPO}:latest" 2>/dev/null || true
-            echo "  Tagged image: ${IMAGE_REPO}:${SHA}"
-        fi
+        # For compose, tag the image of every application service so a rollback
+        # restores the whole stack. The web service is tagged SHA, the others SHA-service
+        for app_svc in $APP_SERVICES; do
+            BUILT_IMAGE=$(docker compose -p $PROJECT_NAME images -q $app_svc 2>/dev/null | head -1)
+            if [ -z "$BUILT_IMAGE" ]; then
+                continue
+            fi
+
+            if [ "$app_svc" = "$SERVICE_NAME" ]; then
+                docker tag "$BUILT_IMAGE" "${IMAGE_REPO}:${SHA}" 2>/dev/null || true
+                docker tag "$BUILT_IMAGE" "${IMAGE_REPO}:latest" 2>/dev/null || true
+                echo "  Tagged image: ${IMAGE_REPO}:${SHA}"
+            else
+                docker tag "$BUILT_IMAGE" "${IMAGE_REPO}:${SHA}-${app_svc}" 2>/dev/null || true
+                echo "  Tagged image: ${IMAGE_REPO}:${SHA}-${app_svc}"
+            fi
+        done
     else
         # For Dockerfile, tag the built image
         docker tag "$PROJECT_NAME" "${IMAGE_REPO}:${SHA}" 2>/dev/null || true
@@ -523,30 +832,41 @@ EOF
         echo "  Tagged image: ${IMAGE_REPO}:${SHA}"
     fi
 
+    # The shipped images are tagged for rollback now
+    if [ $PREBUILT -eq 1 ]; then
+        docker images "$PREBUILT_REPO" --format '{{.Tag}}' 2>/dev/null | grep "^${newrev}" | while read tag; do
+            docker rmi "${PREBUILT_REPO}:${tag}" > /dev/null 2>&1 || true
+        done
+    fi
+
     # Record deployment to manifest file (for rollback listing)
     # Format: SHA TIMESTAMP PORT BUILD_METHOD
     echo "${SHA} $(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ) ${HOST_PORT} ${BUILD_METHOD}" >> "$DEPLOYMENTS_FILE"
     echo "  Recorded deployment to manifest"
 
+    # Record release metadata next to the release, so it is removed with it.
+    # Fingerprints show whether mushak.yaml or the environment changed between releases
+    CONFIG_FINGERPRINT="none"
+    if [ -f "$DEPLOY_DIR/mushak.yaml" ]; then
+        CONFIG_FINGERPRINT=$(sha256sum "$DEPLOY_DIR/mushak.yaml" | cut -c1-12)
+    fi
+    ENV_FINGERPRINT=$(cat "$DEPLOY_DIR/.env" "$DEPLOY_DIR"/.env.d/*.env 2>/dev/null | sha256sum | cut -c1-12)
+    cat > "$DEPLOY_DIR/.mushak-release" <<EOF
+commit=$newrev
+subject=$COMMIT_SUBJECT
+author=$COMMIT_AUTHOR
+deployed_by=$DEPLOYED_BY
+config=$CONFIG_FINGERPRINT
+env=$ENV_FINGERPRINT
+EOF
+
     echo ""
-    echo "→ Cleaning up old images (keeping last $KEEP_IMAGES)..."
-
-    # Get all tagged versions for this app, sorted by creation time (newest first)
-    # Skip 'latest' and keep the most recent N versions
-    OLD_TAGS=$(docker images "${IMAGE_REPO}" --format "{{.Tag}} {{.CreatedAt}}" 2>/dev/null | \
-        grep -v "latest" | \
-        sort -k2 -r | \
-        tail -n +$((KEEP_IMAGES + 1)) | \
-        awk '{print $1}')
-
-    for tag in $OLD_TAGS; do
-        echo "  Removing old image: ${IMAGE_REPO}:${tag}"
-        docker rmi "${IMAGE_REPO}:${tag}" 2>/dev/null || true
-    done
+%s
 
     # Also cleanup old project-specific images that are no longer tagged
-    # These are images like mushak-myapp-abc123f that we can safely remove
-    docker images --format "{{.Repository}}:{{.Tag}}" | grep "^mushak-${APP_NAME}-" | grep -v "$SHA" | while read old_image; do
+    # These are images like mushak-myapp-abc123f that we can safely remove.
+    # Prebuilt images shipped for pushes that haven't deployed yet are kept
+    docker images --format "{{.Repository}}:{{.Tag}}" | grep "^mushak-${APP_NAME}-" | grep -v "^mushak-${APP_NAME}-prebuilt:" | grep -v "$SHA" | while read old_image; do
         echo "  Removing old build image: $old_image"
         docker rmi "$old_image" 2>/dev/null || true
     done
@@ -574,5 +894,10 @@ EOF
     echo "URL: https://$DOMAIN"
     echo "========================================="
 done
-`, appName, domain, branch, buildOpts, internalPort, healthPath, healthTimeout)
+`, appName, domain, branch, rootDir, skip, buildOpts, internalPort, healthPath, healthTimeout,
+		FindFreePortScript, ComposeOverrideScript, DeploymentSkippedNotice,
+		indent(CopyEnvFilesScript, "    "), indent(DecryptSecretsScript, "    "), indent(EnvSchemaScript, "    "),
+		StaticCaddyScript, indent(CleanupReleasesScript, "        "),
+		CaddyProxyScript, indent(DrainConnectionsScript, "    "), indent(StopPreviousContainersScript, "    "),
+		indent(CleanupReleasesScript, "    "))
 }
@@ -59,14 +59,14 @@ func TestGeneratePostReceiveHook(t *testing.T) {
 			contains: []string{
 				"BUILD_OPTS=\"--no-cache\"",
 				"docker compose -p $PROJECT_NAME build $BUILD_OPTS",
-				"docker build $BUILD_OPTS -t $PROJECT_NAME .",
+				`docker build $BUILD_OPTS "${DOCKER_BUILD_OPTS[@]}" "${BUILD_ARGS[@]}" "${BUILD_SECRETS[@]}" "${BUILD_SSH[@]}" -t $PROJECT_NAME "${BUILD_CONTEXT:-.}"`,
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			script := GeneratePostReceiveHook(tt.appName, tt.domain, tt.branch, tt.noCache, 0, "", 0)
+			script := GeneratePostReceiveHook(tt.appName, tt.domain, tt.branch, "", false, tt.noCache, 0, "", 0)
 
 			if script == "" {
 				t.Error("GeneratePostReceiveHook() returned empty script")
@@ -88,7 +88,7 @@ func TestGeneratePostReceiveHook_ScriptStructure(t *testing.T) {
 	domain := "test.example.com"
 	branch := "main"
 
-	script := GeneratePostReceiveHook(appName, domain, branch, false, 0, "", 0)
+	script := GeneratePostReceiveHook(appName, domain, branch, "", false, false, 0, "", 0)
 
 	// Test that script has proper bash structure
 	requiredElements := []string{
@@ -106,7 +106,7 @@ func TestGeneratePostReceiveHook_ScriptStructure(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_DeploymentSteps(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	// Check that deployment steps are included
 	deploymentSteps := []string{
@@ -129,7 +129,7 @@ func TestGeneratePostReceiveHook_DeploymentSteps(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_DockerSupport(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	// Check for Docker Compose support
 	dockerComposeElements := []string{
@@ -160,7 +160,7 @@ func TestGeneratePostReceiveHook_DockerSupport(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_HealthCheck(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	healthCheckElements := []string{
 		"Waiting for service to be healthy",
@@ -179,7 +179,7 @@ func TestGeneratePostReceiveHook_HealthCheck(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_Rollback(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	rollbackElements := []string{
 		"Rolling back",
@@ -196,12 +196,16 @@ func TestGeneratePostReceiveHook_Rollback(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_CaddyIntegration(t *testing.T) {
-	script := GeneratePostReceiveHook("myapp", "myapp.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("myapp", "myapp.com", "main", "", false, false, 0, "", 0)
 
 	caddyElements := []string{
 		"Updating Caddy configuration",
 		"/etc/caddy/apps/$APP_NAME.caddy",
+		"import /etc/caddy/apps/$APP_NAME.d/*.conf",
+		"output file /var/log/caddy/$APP_NAME.log",
+		"format json",
 		"reverse_proxy localhost:$HOST_PORT",
+		"import /etc/caddy/apps/$APP_NAME.d/*.proxy",
 		"systemctl reload caddy",
 		"myapp.com",
 	}
@@ -214,14 +218,13 @@ func TestGeneratePostReceiveHook_CaddyIntegration(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_Cleanup(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	cleanupElements := []string{
 		"Cleaning up old containers",
 		"grep -v \"$SHA\"",
-		"tail -n +4",
 		"Tagging images for rollback",
-		"Cleaning up old images",
+		"Cleaning up old releases",
 		"docker image prune",
 	}
 
@@ -233,7 +236,7 @@ func TestGeneratePostReceiveHook_Cleanup(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_NetworkAndInfra(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	expectedElements := []string{
 		"mushak-${APP_NAME}-net",
@@ -257,11 +260,11 @@ func TestGeneratePostReceiveHook_NetworkAndInfra(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_BranchFiltering(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "production", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "production", "", false, false, 0, "", 0)
 
 	branchElements := []string{
 		"BRANCH=$(git rev-parse --symbolic --abbrev-ref $refname)",
-		"if [ \"$BRANCH\" != \"$DEPLOY_BRANCH\" ]",
+		"[ \"$BRANCH\" != \"$DEPLOY_BRANCH\" ]",
 		"Skipping deployment for branch",
 	}
 
@@ -273,7 +276,7 @@ func TestGeneratePostReceiveHook_BranchFiltering(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_ConfigurationParsing(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	configElements := []string{
 		"mushak.yaml",
@@ -291,7 +294,7 @@ func TestGeneratePostReceiveHook_ConfigurationParsing(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_PortManagement(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	portElements := []string{
 		"Finding available port",
@@ -310,7 +313,7 @@ func TestGeneratePostReceiveHook_PortManagement(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_PathsAndDirectories(t *testing.T) {
-	script := GeneratePostReceiveHook("testapp", "test.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("testapp", "test.com", "main", "", false, false, 0, "", 0)
 
 	pathElements := []string{
 		"DEPLOY_DIR=\"/var/www/$APP_NAME",
@@ -327,7 +330,7 @@ func TestGeneratePostReceiveHook_PathsAndDirectories(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_ErrorHandling(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	errorElements := []string{
 		"set -e",
@@ -344,7 +347,7 @@ func TestGeneratePostReceiveHook_ErrorHandling(t *testing.T) {
 }
 
 func TestGeneratePostReceiveHook_Sanitization(t *testing.T) {
-	script := GeneratePostReceiveHook("app", "app.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
 
 	sanitizationElements := []string{
 		"sanitize_docker_compose()",
@@ -363,7 +366,7 @@ func TestGeneratePostReceiveHook_Sanitization(t *testing.T) {
 
 func TestGeneratePostReceiveHook_EmptyInputs(t *testing.T) {
 	// Test that function handles empty inputs gracefully
-	script := GeneratePostReceiveHook("", "", "", false, 0, "", 0)
+	script := GeneratePostReceiveHook("", "", "", "", false, false, 0, "", 0)
 
 	// Should still generate a script structure even with empty inputs
 	if script == "" {
@@ -405,7 +408,7 @@ func TestGeneratePostReceiveHook_SpecialCharacters(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			script := GeneratePostReceiveHook(tt.appName, tt.domain, tt.branch, false, 0, "", 0)
+			script := GeneratePostReceiveHook(tt.appName, tt.domain, tt.branch, "", false, false, 0, "", 0)
 
 			if script == "" {
 				t.Error("GeneratePostReceiveHook() returned empty script")
@@ -430,7 +433,7 @@ func TestGeneratePostReceiveHook_SpecialCharacters(t *testing.T) {
 func TestGeneratePostReceiveHook_ServiceCategorizationBeforeOverride(t *testing.T) {
 	// This test verifies the fix for the container name override ordering bug
 	// Service categorization MUST happen before override file creation
-	script := GeneratePostReceiveHook("testapp", "test.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("testapp", "test.com", "main", "", false, false, 0, "", 0)
 
 	// Find the index where service categorization starts
 	serviceCategorizationMarker := "# Detect infrastructure services (databases, caches, etc.) that should persist"
@@ -447,7 +450,7 @@ func TestGeneratePostReceiveHook_ServiceCategorizationBeforeOverride(t *testing.
 	}
 
 	// Find the index where override file is created
-	overrideCreationMarker := "cat > docker-compose.override.yml <<EOF"
+	overrideCreationMarker := "\n        compose_override_start\n"
 	overrideIndex := strings.Index(script, overrideCreationMarker)
 	if overrideIndex == -1 {
 		t.Fatal("Script doesn't create docker-compose.override.yml")
@@ -479,7 +482,7 @@ func TestGeneratePostReceiveHook_ServiceCategorizationBeforeOverride(t *testing.
 
 func TestGeneratePostReceiveHook_ContainerNameOverrides(t *testing.T) {
 	// Test that the override file includes container_name for all service types
-	script := GeneratePostReceiveHook("myapp", "myapp.com", "main", false, 0, "", 0)
+	script := GeneratePostReceiveHook("myapp", "myapp.com", "main", "", false, false, 0, "", 0)
 
 	// Check that override file includes container_name overrides for application services
 	appServiceOverride := "for app_svc in $APP_SERVICES; do"
@@ -505,3 +508,367 @@ func TestGeneratePostReceiveHook_ContainerNameOverrides(t *testing.T) {
 		t.Error("Script doesn't include static container_name pattern for infra services")
 	}
 }
+
+func TestGeneratePostReceiveHook_MaintenanceMode(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	// The site block keeps importing app snippets, so maintenance survives a deploy
+	maintenanceElements := []string{
+		"import /etc/caddy/apps/$APP_NAME.d/*.conf",
+		"/etc/caddy/apps/$APP_NAME.d/maintenance.conf",
+		"Maintenance mode is ON",
+	}
+
+	for _, element := range maintenanceElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing maintenance element: %q", element)
+		}
+	}
+}
+
+func TestGeneratePostReceiveHook_ConnectionDraining(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	drainElements := []string{
+		"DRAIN_SECONDS=10",
+		"STOP_GRACE_PERIOD=10",
+		`grep "drain_seconds:" mushak.yaml`,
+		`grep "stop_grace_period:" mushak.yaml`,
+		`ss -Htn state established "( dport = :$OLD_PORT )"`,
+		`docker stop -t $STOP_GRACE_PERIOD "$container"`,
+		// Env restarts of the same SHA have their own project and are stopped too
+		`if [ "$project" = "$PROJECT_NAME" ] || [ "$container" = "$PROJECT_NAME" ]; then`,
+	}
+
+	for _, element := range drainElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing draining element: %q", element)
+		}
+	}
+
+	// Old containers must only be stopped once the drain is over
+	drainIdx := strings.Index(script, "Draining connections to previous release")
+	cleanupIdx := strings.Index(script, "Cleaning up old containers")
+	if drainIdx == -1 || cleanupIdx == -1 || drainIdx > cleanupIdx {
+		t.Error("Connections should be drained before old containers are cleaned up")
+	}
+}
+
+func TestGeneratePostReceiveHook_Secrets(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	secretsElements := []string{
+		`if [ -f "secrets.env.enc" ]; then`,
+		"openssl enc -d -aes-256-cbc -pbkdf2 -iter 100000 -md sha256 -a -pass file:/var/www/$APP_NAME/.secrets.key",
+		`SECRETS_ENV="$SECRETS_DIR/$(basename "$PWD").env"`,
+		`tr -d '[:space:]' | hmac_sha256 "$SECRETS_MAC_KEY"`,
+		`echo "      - $SECRETS_ENV" >> docker-compose.override.yml`,
+		`ENV_OPTS="$ENV_OPTS --env-file $SECRETS_ENV"`,
+		// The plaintext is deleted when the hook ends
+		"trap remove_decrypted_secrets EXIT",
+	}
+
+	for _, element := range secretsElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing secrets element: %q", element)
+		}
+	}
+}
+
+func TestGeneratePostReceiveHook_ServiceEnvFiles(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	serviceEnvElements := []string{
+		"cp /var/www/$APP_NAME/.env.d/*.env .env.d/",
+		`echo "      - .env.d/$app_svc.env" >> docker-compose.override.yml`,
+		"      - .env.d/$infra_svc.env",
+	}
+
+	for _, element := range serviceEnvElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing per-service env element: %q", element)
+		}
+	}
+
+	// Per-service files are listed before secrets so secrets take precedence
+	if strings.Index(script, ".env.d/$app_svc.env\" >>") > strings.Index(script, "- $SECRETS_ENV\" >>") {
+		t.Error("per-service env file should come before the secrets env file")
+	}
+}
+
+func TestGeneratePostReceiveHook_EnvSchema(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	schemaElements := []string{
+		`if [ -f "/var/www/$APP_NAME/.env-schema" ]; then`,
+		`while IFS=$'\x1f' read -r key required type default pattern; do`,
+		`errors+=("$key: required but not set")`,
+		`[[ "$value" =~ $pattern ]]`,
+		`echo "$key=$default" >> "$target"`,
+		`check_env_schema "" .env "$SECRETS_ENV" .env || exit 1`,
+		// Services with their own env file are checked against their effective env
+		`check_env_schema "$1" ".env.d/$1.env" "$SECRETS_ENV" ".env.d/$1.env" .env`,
+		`check_service_env_schema "$app_svc" || exit 1`,
+		// Bash parameter expansion survives the format string
+		`${value%%\"*}`,
+	}
+
+	for _, element := range schemaElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing env schema element: %q", element)
+		}
+	}
+
+	// Validation runs before anything is built
+	if strings.Index(script, ".env-schema") > strings.Index(script, "Building and starting containers") {
+		t.Error("env schema should be validated before building")
+	}
+	if strings.Index(script, `check_service_env_schema "$app_svc"`) > strings.Index(script, "Building and starting containers") {
+		t.Error("service env schema should be validated before building")
+	}
+}
+
+func TestGeneratePostReceiveHook_TagsAllAppServices(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	tagElements := []string{
+		`BUILT_IMAGE=$(docker compose -p $PROJECT_NAME images -q $app_svc 2>/dev/null | head -1)`,
+		`docker tag "$BUILT_IMAGE" "${IMAGE_REPO}:${SHA}"`,
+		`docker tag "$BUILT_IMAGE" "${IMAGE_REPO}:${SHA}-${app_svc}"`,
+		// Cleanup removes the per-service tags with the release
+		`grep -E "^${release}(-|$)"`,
+	}
+
+	for _, element := range tagElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing image tagging element: %q", element)
+		}
+	}
+}
+
+func TestGeneratePostReceiveHook_ReleaseCleanup(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	cleanupElements := []string{
+		`RETENTION_FILE="$RELEASES_DIR/.retention"`,
+		`grep -qx "$release" "$PINNED_FILE"`,
+		`rm -rf "$RELEASES_DIR/$release"`,
+		`docker rmi "$RELEASE_IMAGE_REPO:$tag"`,
+		// Only real releases are removed, not e.g. the build secrets in secrets/
+		`grep -q "^$1 " "$MANIFEST_FILE" 2>/dev/null || [ -f "$RELEASES_DIR/$1/.mushak-release" ]`,
+		`if is_release_dir "$release"; then`,
+		// New release directories are marked before anything can fail
+		`touch "$DEPLOY_DIR/.mushak-release"`,
+	}
+
+	for _, element := range cleanupElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing release cleanup element: %q", element)
+		}
+	}
+
+	// The old hardcoded limits are gone
+	if strings.Contains(script, "tail -n +4") || strings.Contains(script, "KEEP_IMAGES") {
+		t.Error("hook should use the shared release cleanup")
+	}
+
+	// Cleanup runs after the new release is tagged and recorded
+	if strings.LastIndex(script, "Recorded deployment to manifest") > strings.LastIndex(script, "Cleaning up old releases") {
+		t.Error("releases should be cleaned up after the deployment is recorded")
+	}
+}
+
+func TestGeneratePostReceiveHook_ReleaseMetadata(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	metadataElements := []string{
+		"COMMIT_SUBJECT=$(git log -1 --format=%s $newrev)",
+		"COMMIT_AUTHOR=$(git log -1 --format='%an <%ae>' $newrev)",
+		`deploy-user=*) DEPLOYED_BY="${!PUSH_OPTION_VAR#deploy-user=}" ;;`,
+		`cat > "$DEPLOY_DIR/.mushak-release" <<EOF`,
+		"subject=$COMMIT_SUBJECT",
+		"env=$ENV_FINGERPRINT",
+	}
+
+	for _, element := range metadataElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing release metadata element: %q", element)
+		}
+	}
+}
+
+func TestGeneratePostReceiveHook_UncommittedReleases(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	elements := []string{
+		`if [ "$refname" = "refs/mushak/dirty" ]; then`,
+		`if [ $DIRTY -eq 0 ] && [ "$BRANCH" != "$DEPLOY_BRANCH" ]; then`,
+		`SHA="dirty-$(git rev-parse --short=7 "$newrev^{tree}")"`,
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing uncommitted release element: %q", element)
+		}
+	}
+}
+
+func TestGeneratePostReceiveHook_PrebuiltImages(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	elements := []string{
+		`PREBUILT_REPO="mushak-${APP_NAME}-prebuilt"`,
+		`image="${PREBUILT_REPO}:${newrev}-${app_svc}"`,
+		`compose_override_service "$app_svc" "$image"`,
+		"docker compose -p $PROJECT_NAME up -d --no-build --no-deps $APP_SERVICES",
+		`docker tag "${PREBUILT_REPO}:${newrev}" $PROJECT_NAME`,
+		`docker build $BUILD_OPTS "${DOCKER_BUILD_OPTS[@]}" "${BUILD_ARGS[@]}" "${BUILD_SECRETS[@]}" "${BUILD_SSH[@]}" -t $PROJECT_NAME "${BUILD_CONTEXT:-.}"`,
+		`docker rmi "${PREBUILT_REPO}:${tag}"`,
+		// Images shipped for other pushes survive the old image cleanup
+		`grep "^mushak-${APP_NAME}-" | grep -v "^mushak-${APP_NAME}-prebuilt:" | grep -v "$SHA"`,
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing prebuilt image element: %q", element)
+		}
+	}
+}
+
+func TestGeneratePostReceiveHook_BuildSettings(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	elements := []string{
+		`done < "/var/www/$APP_NAME/.build"`,
+		`arg) BUILD_ARGS+=(--build-arg "$value") ;;`,
+		`ARG_VALUE=$(env_value "$value")`,
+		`DOCKERFILE_PATH="${BUILD_CONTEXT:-.}/${DOCKERFILE:-Dockerfile}"`,
+		`grep -i "^EXPOSE" "$DOCKERFILE_PATH"`,
+		`elif [ -f "$DOCKERFILE_PATH" ]; then`,
+		`echo "    platform: $BUILD_PLATFORM" >> docker-compose.override.yml`,
+		`OVERRIDE_BUILD+=("target: $BUILD_TARGET")`,
+		`docker compose -p $PROJECT_NAME build $BUILD_OPTS "${BUILD_ARGS[@]}" "${BUILD_SSH[@]}" $APP_SERVICES`,
+		`DOCKER_BUILD_OPTS+=(--target "$BUILD_TARGET")`,
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing build settings element: %q", element)
+		}
+	}
+
+	// The settings are read after the environment is prepared, so env_value works
+	if strings.Index(script, "env_value() {") > strings.Index(script, `ARG_VALUE=$(env_value "$value")`) {
+		t.Error("env_value must be defined before the build settings are read")
+	}
+}
+
+func TestGeneratePostReceiveHook_RootDir(t *testing.T) {
+	script := GeneratePostReceiveHook("api", "api.com", "main", "services/api", true, false, 0, "", 0)
+
+	elements := []string{
+		`ROOT_DIR="services/api"`,
+		"SKIP_UNCHANGED=1",
+		`git archive "$newrev:$ROOT_DIR" | tar -x -C $DEPLOY_DIR`,
+		"git update-ref --no-deref HEAD $newrev",
+		`git diff --quiet "$BASE_REV" "$newrev" -- "$ROOT_DIR"`,
+		`[ "$oldrev" != "$newrev" ]`,
+		// Lets 'mushak deploy --watch' know there is no new release to watch
+		`echo "  ` + DeploymentSkippedNotice + `"`,
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing monorepo element: %q", element)
+		}
+	}
+
+	// The whole repository is checked out without a root_dir
+	script = GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+	if !strings.Contains(script, `ROOT_DIR=""`) || !strings.Contains(script, "SKIP_UNCHANGED=0") {
+		t.Error("Script should deploy the whole repository without a root_dir")
+	}
+}
+
+func TestGeneratePostReceiveHook_BuildSecrets(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	elements := []string{
+		`secret_id="${value%%=*}"`,
+		`env_value "${secret_source#env:}" > "$secret_file"`,
+		// Replaces the EXIT trap of the decrypted secrets, so it removes them too
+		`trap 'rm -rf "$BUILD_SECRETS_DIR"; remove_decrypted_secrets' EXIT`,
+		`BUILD_SECRETS+=(--secret "id=$secret_id,src=$secret_file")`,
+		`BUILD_SSH=(--ssh default)`,
+		`BUILD_SSH=(--ssh "default=$value")`,
+		`compose_service_builds "$app_svc"`,
+		`    file: ${BUILD_SECRET_FILES[$i]}`,
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing build secrets element: %q", element)
+		}
+	}
+}
+
+func TestGeneratePostReceiveHook_GeneratedDockerfile(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	elements := []string{
+		`cp "/var/www/$APP_NAME/.dockerfile" "$DOCKERFILE_PATH"`,
+		"mushak init --generate-dockerfile",
+		// Env files copied into the release stay out of the image
+		`cat "/var/www/$APP_NAME/.dockerignore" >> "${BUILD_CONTEXT:-.}/.dockerignore"`,
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing generated Dockerfile element: %q", element)
+		}
+	}
+
+	// The generated Dockerfile must be in place before the port is detected from it
+	if strings.Index(script, ".dockerfile\" \"$DOCKERFILE_PATH\"") > strings.Index(script, "DETECTED_PORT=0") {
+		t.Error("generated Dockerfile should be copied before port detection")
+	}
+}
+
+func TestGeneratePostReceiveHook_StaticSite(t *testing.T) {
+	script := GeneratePostReceiveHook("app", "app.com", "main", "", false, false, 0, "", 0)
+
+	staticElements := []string{
+		`if [ -f "/var/www/$APP_NAME/.static" ]; then`,
+		`-v "$DEPLOY_DIR:/app" -w /app "$STATIC_IMAGE" sh -c "$STATIC_BUILD"`,
+		`--exclude='./.env*'`,
+		`mv "$STATIC_STAGE" "$DEPLOY_DIR/public"`,
+		"root * $DEPLOY_DIR/public",
+		"file_server",
+		`- static" >> "$DEPLOYMENTS_FILE"`,
+		"type=static",
+		// The repository root, with secrets.env.enc and the rest of the checkout, is never published
+		`if [ -z "$STATIC_OUTPUT" ] || [ "$STATIC_OUTPUT" = "." ]; then`,
+		"--exclude=./secrets.env.enc",
+	}
+
+	for _, element := range staticElements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing static site element: %q", element)
+		}
+	}
+
+	// The Caddy heredoc must be terminated by an unindented EOF
+	static := script[strings.Index(script, `if [ -f "/var/www/$APP_NAME/.static" ]; then`):]
+	static = static[:strings.Index(static, "        continue\n")]
+	if !strings.Contains(static, "\n}\nEOF\n") {
+		t.Error("Caddy config heredoc should not be indented")
+	}
+
+	// Static sites are recorded before old releases are cleaned up, and skip the container build
+	if strings.Index(static, "- static") > strings.Index(static, "Cleaning up old releases") {
+		t.Error("static releases should be cleaned up after the deployment is recorded")
+	}
+	if strings.Contains(static, "docker build") {
+		t.Error("static sites should not build an image")
+	}
+}
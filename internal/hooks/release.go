@@ -0,0 +1,170 @@
+package hooks
+
+// The scripts below start a release's containers and switch traffic to them.
+// They are shared by the post-receive hook and the rollback, env restart and
+// image deploy scripts, and expect $APP_NAME to be set.
+
+// FindFreePortScript defines find_free_port, which prints a free port in 8000-9000
+const FindFreePortScript = `# Find a free port (8000-9000)
+find_free_port() {
+    for port in {8000..9000}; do
+        if ! ss -tuln | grep -q ":$port "; then
+            echo $port
+            return 0
+        fi
+    done
+    echo "ERROR: No free ports available in range 8000-9000" >&2
+    exit 1
+}
+`
+
+// ComposeOverrideScript defines the functions that write a release's
+// docker-compose.override.yml. Application services get versioned container
+// names so releases can run side by side, join the app's shared network and
+// reach infrastructure services by their service names. The functions read
+// $PROJECT_NAME, $NETWORK_NAME, $SERVICE_NAME, $HOST_PORT, $INTERNAL_PORT,
+// $INFRA_SERVICES and $SECRETS_ENV when they are called.
+//
+// The script has a heredoc, so it must be inserted without indentation.
+const ComposeOverrideScript = `# Starts docker-compose.override.yml on the app's shared network
+compose_override_start() {
+    cat > docker-compose.override.yml <<EOF
+version: '3'
+networks:
+  default:
+    external: true
+    name: $NETWORK_NAME
+services:
+EOF
+}
+
+# Adds application service $1 with its versioned container name, running image $2 if set
+compose_override_service() {
+    local app_svc=$1 image=$2
+    echo "  $app_svc:" >> docker-compose.override.yml
+    echo "    container_name: ${PROJECT_NAME}-${app_svc}" >> docker-compose.override.yml
+    if [ -n "$image" ]; then
+        echo "    image: $image" >> docker-compose.override.yml
+    fi
+}
+
+# Adds what application service $1 runs with: its env files, the web service's
+# port and links to the infrastructure services
+compose_override_runtime() {
+    local app_svc=$1 infra_svc
+
+    # Per-service variables first, so secrets take precedence.
+    # Secrets are only read when the container is created
+    if [ -f ".env.d/$app_svc.env" ] || [ -n "$SECRETS_ENV" ]; then
+        echo "    env_file:" >> docker-compose.override.yml
+    fi
+    if [ -f ".env.d/$app_svc.env" ]; then
+        echo "      - .env.d/$app_svc.env" >> docker-compose.override.yml
+    fi
+    if [ -n "$SECRETS_ENV" ]; then
+        echo "      - $SECRETS_ENV" >> docker-compose.override.yml
+    fi
+
+    if [ "$app_svc" = "$SERVICE_NAME" ]; then
+        echo "    ports:" >> docker-compose.override.yml
+        echo "      - \"$HOST_PORT:$INTERNAL_PORT\"" >> docker-compose.override.yml
+    fi
+
+    # Map the static container names to service names, e.g. myapp_postgres:postgres
+    if [ -n "$INFRA_SERVICES" ]; then
+        echo "    external_links:" >> docker-compose.override.yml
+        for infra_svc in $INFRA_SERVICES; do
+            echo "      - ${APP_NAME}_${infra_svc}:${infra_svc}" >> docker-compose.override.yml
+        done
+    fi
+}
+`
+
+// CaddyProxySite is the site block of an app served by its containers on
+// $HOST_PORT. Extra directives such as TLS settings, maintenance mode and
+// proxy options are imported from snippets in /etc/caddy/apps/$APP_NAME.d.
+const CaddyProxySite = caddySiteStart + `	reverse_proxy localhost:$HOST_PORT {
+		import /etc/caddy/apps/$APP_NAME.d/*.proxy
+	}
+}
+`
+
+// caddySiteStart opens the site block of $DOMAIN with the app's snippets and access log
+const caddySiteStart = `$DOMAIN {
+	import /etc/caddy/apps/$APP_NAME.d/*.conf
+	log {
+		output file /var/log/caddy/$APP_NAME.log {
+			roll_size 10MiB
+			roll_keep 5
+			roll_keep_for 720h
+		}
+		format json
+	}
+`
+
+// CaddyProxyScript points the app's site block at $HOST_PORT and reloads
+// Caddy. It expects $DOMAIN and $HOST_PORT to be set.
+//
+// The script has a heredoc, so it must be inserted without indentation.
+const CaddyProxyScript = `# Update Caddy config (extra directives such as TLS settings are imported from $APP_NAME.d)
+sudo tee /etc/caddy/apps/$APP_NAME.caddy > /dev/null <<EOF
+` + CaddyProxySite + `EOF
+
+# Reload Caddy
+sudo systemctl reload caddy
+
+echo "  Caddy updated and reloaded"
+` + maintenanceWarningScript
+
+// maintenanceWarningScript reminds that visitors still see the maintenance
+// page after traffic is switched to another release
+const maintenanceWarningScript = `
+# Maintenance mode is a snippet imported by the site block, so it stays active
+if [ -f "/etc/caddy/apps/$APP_NAME.d/maintenance.conf" ]; then
+    echo "  ⚠ Maintenance mode is ON - visitors still see the maintenance page"
+    echo "    Run 'mushak maintenance off' to restore traffic"
+fi
+`
+
+// DrainConnectionsScript waits up to $DRAIN_SECONDS for the connections to
+// the previous release on $OLD_PORT to close. Run it after traffic has been
+// switched to $HOST_PORT.
+const DrainConnectionsScript = `# New requests now go to the new release. Let in-flight requests and
+# websockets on the previous release finish before stopping it.
+if [ -n "$OLD_PORT" ] && [ "$OLD_PORT" != "$HOST_PORT" ] && [ "$DRAIN_SECONDS" -gt 0 ]; then
+    echo ""
+    echo "→ Draining connections to previous release (up to ${DRAIN_SECONDS}s)..."
+    DRAIN_ELAPSED=0
+    OPEN_CONNECTIONS=$(ss -Htn state established "( dport = :$OLD_PORT )" 2>/dev/null | wc -l)
+    while [ "$OPEN_CONNECTIONS" -gt 0 ] && [ $DRAIN_ELAPSED -lt $DRAIN_SECONDS ]; do
+        echo -n "."
+        sleep 1
+        DRAIN_ELAPSED=$((DRAIN_ELAPSED + 1))
+        OPEN_CONNECTIONS=$(ss -Htn state established "( dport = :$OLD_PORT )" 2>/dev/null | wc -l)
+    done
+    echo ""
+    if [ "$OPEN_CONNECTIONS" -gt 0 ]; then
+        echo "  ⚠ $OPEN_CONNECTIONS connection(s) still open after ${DRAIN_SECONDS}s, stopping anyway"
+    else
+        echo "  Connections drained"
+    fi
+fi
+`
+
+// StopPreviousContainersScript stops and removes every container of the app
+// except those of $PROJECT_NAME. Compose containers are matched by their
+// project and Dockerfile containers by name, so containers of an env restart
+// of the same release (a project of its own) are stopped too. Infrastructure
+// containers are named ${APP_NAME}_service and are left alone. Expects
+// $STOP_GRACE_PERIOD to be set.
+const StopPreviousContainersScript = `# Removing containers keeps their volumes, so database data and other
+# persistent storage is NOT deleted
+docker ps -a --format '{{.Names}} {{.Label "com.docker.compose.project"}}' | grep "^mushak-$APP_NAME-" | while read container project; do
+    if [ "$project" = "$PROJECT_NAME" ] || [ "$container" = "$PROJECT_NAME" ]; then
+        continue
+    fi
+    echo "  Stopping $container"
+    docker stop -t $STOP_GRACE_PERIOD "$container" 2>/dev/null || true
+    docker rm "$container" 2>/dev/null || true
+done
+`
@@ -0,0 +1,104 @@
+package hooks
+
+// CleanupReleasesScript removes old releases. It is shared by the post-receive
+// hook and the rollback script and expects $APP_NAME to be set.
+//
+// A release is kept while it is the current release, pinned with 'mushak
+// releases pin', among the newest $KEEP_RELEASES or younger than $MIN_AGE
+// seconds (both synced from mushak.yaml's retention settings). A release's
+// directory and images are removed together, and releases missing either are
+// removed since they can't be rolled back to. Static sites only have a directory.
+// Directories count as releases only if the manifest lists them or they hold
+// .mushak-release, so other data kept in /var/www/$APP_NAME is left alone.
+const CleanupReleasesScript = `# Remove old releases, keeping directories and images consistent
+RELEASES_DIR="/var/www/$APP_NAME"
+RETENTION_FILE="$RELEASES_DIR/.retention"
+PINNED_FILE="$RELEASES_DIR/.pinned"
+MANIFEST_FILE="$RELEASES_DIR/.deployments"
+RELEASE_IMAGE_REPO="mushak-$APP_NAME"
+
+KEEP_RELEASES=3
+MIN_AGE=0
+if [ -f "$RETENTION_FILE" ]; then
+    KEEP_RELEASES=$(grep "^releases=" "$RETENTION_FILE" | cut -d= -f2)
+    MIN_AGE=$(grep "^min_age=" "$RETENTION_FILE" | cut -d= -f2)
+    KEEP_RELEASES=${KEEP_RELEASES:-3}
+    MIN_AGE=${MIN_AGE:-0}
+fi
+
+echo "→ Cleaning up old releases (keeping $KEEP_RELEASES)..."
+
+CURRENT_RELEASE=$(basename "$(readlink "$RELEASES_DIR/current" 2>/dev/null)" 2>/dev/null || true)
+NOW=$(date +%s)
+
+# Only directories listed in the manifest or holding release metadata are
+# releases. Others, such as the build secrets in secrets/, are never touched
+is_release_dir() {
+    grep -q "^$1 " "$MANIFEST_FILE" 2>/dev/null || [ -f "$RELEASES_DIR/$1/.mushak-release" ]
+}
+
+# Newest first: deployed releases from the manifest, then directories and
+# images the manifest doesn't know about. Image tags are <release> or
+# <release>-<service>. Releases of uncommitted work and images are named
+# dirty-<hash> and image-<id>
+RELEASES=$( {
+    tac "$MANIFEST_FILE" 2>/dev/null | awk '{print $1}'
+    ls -d "$RELEASES_DIR"/*/ 2>/dev/null | xargs -r -n1 basename | grep -vx "current" | while read dir; do
+        if is_release_dir "$dir"; then
+            echo "$dir"
+        fi
+    done
+    docker images "$RELEASE_IMAGE_REPO" --format '{{.Tag}}' 2>/dev/null | grep -vx "latest" | sed -E 's/^((dirty-|image-)?[^-]+).*/\1/'
+} | awk 'NF && !seen[$1]++' )
+
+KEPT=0
+for release in $RELEASES; do
+    if [ "$release" = "$CURRENT_RELEASE" ]; then
+        KEPT=$((KEPT + 1))
+        continue
+    fi
+
+    if grep -qx "$release" "$PINNED_FILE" 2>/dev/null; then
+        echo "  Keeping pinned release $release"
+        continue
+    fi
+
+    # Static sites have no image, their files are in the release directory
+    COMPLETE=0
+    if [ -d "$RELEASES_DIR/$release" ]; then
+        if grep -qx "type=static" "$RELEASES_DIR/$release/.mushak-release" 2>/dev/null ||
+            docker image inspect "$RELEASE_IMAGE_REPO:$release" > /dev/null 2>&1; then
+            COMPLETE=1
+        fi
+    fi
+
+    REMOVE=0
+    if [ $COMPLETE -eq 0 ]; then
+        # Incomplete releases can't be rolled back to
+        REMOVE=1
+    elif [ $KEPT -lt $KEEP_RELEASES ]; then
+        KEPT=$((KEPT + 1))
+    else
+        REMOVE=1
+        DEPLOYED_AT=$(grep "^$release " "$MANIFEST_FILE" 2>/dev/null | tail -1 | awk '{print $2}')
+        if [ "$MIN_AGE" -gt 0 ] && [ -n "$DEPLOYED_AT" ]; then
+            DEPLOYED_EPOCH=$(date -d "$DEPLOYED_AT" +%s 2>/dev/null || echo 0)
+            if [ $((NOW - DEPLOYED_EPOCH)) -lt $MIN_AGE ]; then
+                REMOVE=0
+            fi
+        fi
+    fi
+
+    if [ $REMOVE -eq 1 ]; then
+        echo "  Removing release $release"
+        if is_release_dir "$release"; then
+            rm -rf "$RELEASES_DIR/$release"
+        fi
+        rm -f "/dev/shm/mushak-$APP_NAME/$release.env"
+        # The web service's tag and the per-service tags of compose releases
+        docker images "$RELEASE_IMAGE_REPO" --format '{{.Tag}}' 2>/dev/null | grep -E "^${release}(-|$)" | while read tag; do
+            docker rmi "$RELEASE_IMAGE_REPO:$tag" > /dev/null 2>&1 || true
+        done
+    fi
+done
+`
@@ -0,0 +1,29 @@
+package hooks
+
+// StaticCaddyScript points the app's site block at the public directory of a
+// static release and stops the containers of earlier releases. It is shared
+// by the post-receive hook and the rollback script and expects $APP_NAME,
+// $DOMAIN and $DEPLOY_DIR to be set. Caddy reloads gracefully, so requests are
+// served from either the old or the new root, never a mix.
+//
+// The script has a heredoc, so it must be inserted without indentation.
+const StaticCaddyScript = `# Update Caddy config (extra directives such as TLS settings are imported from $APP_NAME.d)
+sudo tee /etc/caddy/apps/$APP_NAME.caddy > /dev/null <<EOF
+` + caddySiteStart + `	root * $DEPLOY_DIR/public
+	encode zstd gzip
+	file_server
+}
+EOF
+
+# Reload Caddy
+sudo systemctl reload caddy
+
+echo "  Caddy now serves $DEPLOY_DIR/public"
+` + maintenanceWarningScript + `
+# Releases deployed before the app became a static site ran as containers
+docker ps -a --format "{{.Names}}" | grep "^mushak-$APP_NAME-" | while read container; do
+    echo "  Stopping $container"
+    docker stop "$container" 2>/dev/null || true
+    docker rm "$container" 2>/dev/null || true
+done
+`
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogEntry is a single request from Caddy's JSON access log
+type AccessLogEntry struct {
+	Time     time.Time
+	RemoteIP string
+	Method   string
+	Host     string
+	URI      string
+	Status   int
+	Size     int
+	Duration time.Duration
+}
+
+// AccessLogFilter selects access log entries
+type AccessLogFilter struct {
+	Status []string // exact codes ("404") or classes ("5xx")
+	Path   string   // URI prefix
+	Since  time.Time
+}
+
+// AccessLogSummary aggregates access log entries
+type AccessLogSummary struct {
+	Requests      int
+	First         time.Time
+	Last          time.Time
+	StatusClasses map[string]int // "2xx", "3xx", ...
+	AvgDuration   time.Duration
+	P95Duration   time.Duration
+	TopPaths      []PathCount
+}
+
+// PathCount is a request path with its number of hits
+type PathCount struct {
+	Path  string
+	Count int
+}
+
+// AccessLogPath returns the access log file Caddy writes for an app
+func AccessLogPath(appName string) string {
+	return fmt.Sprintf("/var/log/caddy/%s.log", appName)
+}
+
+// caddyAccessLog mirrors the fields of Caddy's JSON access log we use
+type caddyAccessLog struct {
+	TS      float64 `json:"ts"`
+	Request struct {
+		RemoteIP string `json:"remote_ip"`
+		ClientIP string `json:"client_ip"`
+		Method   string `json:"method"`
+		Host     string `json:"host"`
+		URI      string `json:"uri"`
+	} `json:"request"`
+	Duration float64 `json:"duration"`
+	Size     int     `json:"size"`
+	Status   int     `json:"status"`
+}
+
+// ParseAccessLogLine parses one line of Caddy's JSON access log
+func ParseAccessLogLine(line string) (*AccessLogEntry, error) {
+	var raw caddyAccessLog
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return nil, fmt.Errorf("invalid access log line: %w", err)
+	}
+
+	if raw.Request.Method == "" {
+		return nil, fmt.Errorf("not an access log entry")
+	}
+
+	sec := int64(raw.TS)
+	nsec := int64((raw.TS - float64(sec)) * float64(time.Second))
+
+	remoteIP := raw.Request.ClientIP
+	if remoteIP == "" {
+		remoteIP = raw.Request.RemoteIP
+	}
+
+	return &AccessLogEntry{
+		Time:     time.Unix(sec, nsec),
+		RemoteIP: remoteIP,
+		Method:   raw.Request.Method,
+		Host:     raw.Request.Host,
+		URI:      raw.Request.URI,
+		Status:   raw.Status,
+		Size:     raw.Size,
+		Duration: time.Duration(raw.Duration * float64(time.Second)),
+	}, nil
+}
+
+// ParseStatusFilter parses a comma separated list of status codes or classes
+func ParseStatusFilter(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var statuses []string
+	for _, s := range strings.Split(value, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if len(s) != 3 {
+			return nil, fmt.Errorf("invalid status %q (expected e.g. 404 or 5xx)", s)
+		}
+		if s[1:] != "xx" {
+			if _, err := strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("invalid status %q (expected e.g. 404 or 5xx)", s)
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Matches reports whether the entry passes the filter
+func (f AccessLogFilter) Matches(e *AccessLogEntry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+
+	if f.Path != "" && !strings.HasPrefix(e.URI, f.Path) {
+		return false
+	}
+
+	if len(f.Status) == 0 {
+		return true
+	}
+
+	code := strconv.Itoa(e.Status)
+	for _, s := range f.Status {
+		if s == code || (strings.HasSuffix(s, "xx") && len(code) == 3 && code[0] == s[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// SummarizeAccessLog aggregates entries into request rate, status and latency figures
+func SummarizeAccessLog(entries []*AccessLogEntry) AccessLogSummary {
+	summary := AccessLogSummary{
+		Requests:      len(entries),
+		StatusClasses: make(map[string]int),
+	}
+
+	if len(entries) == 0 {
+		return summary
+	}
+
+	durations := make([]time.Duration, 0, len(entries))
+	paths := make(map[string]int)
+	var total time.Duration
+
+	for _, e := range entries {
+		if summary.First.IsZero() || e.Time.Before(summary.First) {
+			summary.First = e.Time
+		}
+		if e.Time.After(summary.Last) {
+			summary.Last = e.Time
+		}
+
+		summary.StatusClasses[fmt.Sprintf("%dxx", e.Status/100)]++
+		durations = append(durations, e.Duration)
+		total += e.Duration
+
+		path := e.URI
+		if i := strings.Index(path, "?"); i >= 0 {
+			path = path[:i]
+		}
+		paths[path]++
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	summary.AvgDuration = total / time.Duration(len(entries))
+	summary.P95Duration = durations[(len(durations)*95+99)/100-1]
+
+	for p, c := range paths {
+		summary.TopPaths = append(summary.TopPaths, PathCount{Path: p, Count: c})
+	}
+	sort.Slice(summary.TopPaths, func(i, j int) bool {
+		if summary.TopPaths[i].Count != summary.TopPaths[j].Count {
+			return summary.TopPaths[i].Count > summary.TopPaths[j].Count
+		}
+		return summary.TopPaths[i].Path < summary.TopPaths[j].Path
+	})
+	if len(summary.TopPaths) > 5 {
+		summary.TopPaths = summary.TopPaths[:5]
+	}
+
+	return summary
+}
+
+// RequestsPerMinute returns the average request rate over the summarized window
+func (s AccessLogSummary) RequestsPerMinute() float64 {
+	minutes := s.Last.Sub(s.First).Minutes()
+	if minutes < 1 {
+		return float64(s.Requests)
+	}
+	return float64(s.Requests) / minutes
+}
+
+// ErrorRate returns the percentage of requests answered with a 5xx status
+func (s AccessLogSummary) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.StatusClasses["5xx"]) * 100 / float64(s.Requests)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+const sampleAccessLogLine = `{"level":"info","ts":1700000000.5,"logger":"http.log.access.log0","msg":"handled request","request":{"remote_ip":"10.0.0.1","client_ip":"203.0.113.9","proto":"HTTP/2.0","method":"GET","host":"myapp.com","uri":"/api/users?page=2"},"bytes_read":0,"duration":0.0125,"size":512,"status":200}`
+
+func TestParseAccessLogLine(t *testing.T) {
+	entry, err := ParseAccessLogLine(sampleAccessLogLine)
+	if err != nil {
+		t.Fatalf("ParseAccessLogLine() error = %v", err)
+	}
+
+	if entry.Method != "GET" {
+		t.Errorf("Method = %v, want GET", entry.Method)
+	}
+	if entry.URI != "/api/users?page=2" {
+		t.Errorf("URI = %v, want /api/users?page=2", entry.URI)
+	}
+	if entry.Status != 200 {
+		t.Errorf("Status = %v, want 200", entry.Status)
+	}
+	if entry.RemoteIP != "203.0.113.9" {
+		t.Errorf("RemoteIP = %v, want client_ip 203.0.113.9", entry.RemoteIP)
+	}
+	if entry.Duration != 12500*time.Microsecond {
+		t.Errorf("Duration = %v, want 12.5ms", entry.Duration)
+	}
+	if entry.Time.Unix() != 1700000000 {
+		t.Errorf("Time = %v, want unix 1700000000", entry.Time.Unix())
+	}
+
+	if _, err := ParseAccessLogLine("not json"); err == nil {
+		t.Error("ParseAccessLogLine() should fail on invalid JSON")
+	}
+	if _, err := ParseAccessLogLine(`{"level":"error","msg":"tls failure"}`); err == nil {
+		t.Error("ParseAccessLogLine() should reject non-access log entries")
+	}
+}
+
+func TestParseStatusFilter(t *testing.T) {
+	got, err := ParseStatusFilter("404, 5XX")
+	if err != nil {
+		t.Fatalf("ParseStatusFilter() error = %v", err)
+	}
+	if len(got) != 2 || got[0] != "404" || got[1] != "5xx" {
+		t.Errorf("ParseStatusFilter() = %v, want [404 5xx]", got)
+	}
+
+	for _, invalid := range []string{"5x", "abc", "40x4"} {
+		if _, err := ParseStatusFilter(invalid); err == nil {
+			t.Errorf("ParseStatusFilter(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestAccessLogFilterMatches(t *testing.T) {
+	now := time.Now()
+	entry := &AccessLogEntry{Time: now, URI: "/api/users", Status: 503}
+
+	tests := []struct {
+		name   string
+		filter AccessLogFilter
+		want   bool
+	}{
+		{name: "empty filter", filter: AccessLogFilter{}, want: true},
+		{name: "status class", filter: AccessLogFilter{Status: []string{"5xx"}}, want: true},
+		{name: "exact status", filter: AccessLogFilter{Status: []string{"503"}}, want: true},
+		{name: "other status", filter: AccessLogFilter{Status: []string{"4xx", "200"}}, want: false},
+		{name: "path prefix", filter: AccessLogFilter{Path: "/api"}, want: true},
+		{name: "other path", filter: AccessLogFilter{Path: "/admin"}, want: false},
+		{name: "since before", filter: AccessLogFilter{Since: now.Add(-time.Minute)}, want: true},
+		{name: "since after", filter: AccessLogFilter{Since: now.Add(time.Minute)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(entry); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeAccessLog(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	var entries []*AccessLogEntry
+	for i := 0; i < 20; i++ {
+		status := 200
+		if i%10 == 0 {
+			status = 500
+		}
+		entries = append(entries, &AccessLogEntry{
+			Time:     start.Add(time.Duration(i) * 6 * time.Second),
+			URI:      "/home?x=1",
+			Status:   status,
+			Duration: time.Duration(i+1) * time.Millisecond,
+		})
+	}
+
+	s := SummarizeAccessLog(entries)
+
+	if s.Requests != 20 {
+		t.Errorf("Requests = %d, want 20", s.Requests)
+	}
+	if s.StatusClasses["5xx"] != 2 || s.StatusClasses["2xx"] != 18 {
+		t.Errorf("StatusClasses = %v, want 2xx:18 5xx:2", s.StatusClasses)
+	}
+	if s.ErrorRate() != 10 {
+		t.Errorf("ErrorRate() = %v, want 10", s.ErrorRate())
+	}
+	if s.P95Duration != 19*time.Millisecond {
+		t.Errorf("P95Duration = %v, want 19ms", s.P95Duration)
+	}
+	if len(s.TopPaths) != 1 || s.TopPaths[0].Path != "/home" {
+		t.Errorf("TopPaths = %v, want [/home]", s.TopPaths)
+	}
+	// 20 requests over 114 seconds
+	if rate := s.RequestsPerMinute(); rate < 10.5 || rate > 10.6 {
+		t.Errorf("RequestsPerMinute() = %v, want ~10.5", rate)
+	}
+
+	empty := SummarizeAccessLog(nil)
+	if empty.Requests != 0 || empty.ErrorRate() != 0 {
+		t.Errorf("SummarizeAccessLog(nil) = %+v, want zero summary", empty)
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/utils"
+)
+
+// BuildSettingsPath returns where the build settings are stored on the server.
+// The post-receive hook reads them before detecting the port and building.
+func BuildSettingsPath(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.build", appName)
+}
+
+// GenerateBuildSettingsFile renders the build settings for the hook, one
+// key=value per line. Build arguments are written as arg=NAME=VALUE, arguments
+// taken from the environment as env_arg=NAME and secrets as
+// secret=ID=env:NAME or secret=ID=file:PATH.
+func GenerateBuildSettingsFile(b config.BuildConfig) (string, error) {
+	if err := b.Check(); err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, setting := range []struct{ key, value string }{
+		{"dockerfile", b.Dockerfile},
+		{"context", b.Context},
+		{"target", b.Target},
+		{"platform", b.Platform},
+		{"ssh", b.SSH},
+	} {
+		if setting.value != "" {
+			lines = append(lines, setting.key+"="+setting.value)
+		}
+	}
+	for _, name := range b.ArgNames() {
+		lines = append(lines, fmt.Sprintf("arg=%s=%s", name, b.Args[name]))
+	}
+	for _, name := range b.ArgsFromEnv {
+		lines = append(lines, "env_arg="+name)
+	}
+	for _, secret := range b.Secrets {
+		if secret.Env != "" {
+			lines = append(lines, fmt.Sprintf("secret=%s=env:%s", secret.ID, secret.Env))
+		} else {
+			lines = append(lines, fmt.Sprintf("secret=%s=file:%s", secret.ID, secret.File))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// SyncBuildSettings uploads the build settings from mushak.yaml, or removes
+// them if there are none
+func SyncBuildSettings(executor *ssh.Executor, appName string, b config.BuildConfig) error {
+	path := BuildSettingsPath(appName)
+
+	content, err := GenerateBuildSettingsFile(b)
+	if err != nil {
+		return fmt.Errorf("invalid mushak.yaml: %w", err)
+	}
+
+	if content == "" {
+		if _, err := executor.Run(fmt.Sprintf("rm -f %s", path)); err != nil {
+			return fmt.Errorf("failed to remove build settings: %w", err)
+		}
+		return nil
+	}
+
+	if err := executor.WriteFile(path, content); err != nil {
+		return fmt.Errorf("failed to upload build settings: %w", err)
+	}
+	return nil
+}
+
+// GeneratedDockerfilePath returns where the Dockerfile generated for an app
+// without one is stored on the server. The post-receive hook copies it into
+// releases that have no Dockerfile or docker-compose.yml.
+func GeneratedDockerfilePath(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.dockerfile", appName)
+}
+
+// GeneratedDockerignorePath returns where the .dockerignore entries for the
+// generated Dockerfile are stored on the server. The post-receive hook adds
+// them to the release's .dockerignore when it uses the generated Dockerfile.
+func GeneratedDockerignorePath(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.dockerignore", appName)
+}
+
+// SyncGeneratedDockerfile uploads the generated Dockerfile with the
+// .dockerignore entries that keep env files out of its image, or removes them
+// if content is empty because the app has its own
+func SyncGeneratedDockerfile(executor *ssh.Executor, appName, content string) error {
+	path := GeneratedDockerfilePath(appName)
+	ignorePath := GeneratedDockerignorePath(appName)
+
+	if content == "" {
+		if _, err := executor.Run(fmt.Sprintf("rm -f %s %s", path, ignorePath)); err != nil {
+			return fmt.Errorf("failed to remove generated Dockerfile: %w", err)
+		}
+		return nil
+	}
+
+	if err := executor.WriteFile(path, content); err != nil {
+		return fmt.Errorf("failed to upload generated Dockerfile: %w", err)
+	}
+	if err := executor.WriteFile(ignorePath, utils.GeneratedDockerignore); err != nil {
+		return fmt.Errorf("failed to upload generated .dockerignore: %w", err)
+	}
+	return nil
+}
+
+// ReadBuildSecretFile returns the content of a build secret file on the
+// server. Relative paths are relative to the app's directory, as in the hook.
+func ReadBuildSecretFile(executor *ssh.Executor, appName, file string) (string, error) {
+	if !path.IsAbs(file) {
+		file = path.Join("/var/www", appName, file)
+	}
+
+	content, err := executor.Run(fmt.Sprintf("cat %s", shellQuote(file)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read build secret %s: %w", file, err)
+	}
+	return content, nil
+}
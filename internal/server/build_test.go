@@ -0,0 +1,53 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/hmontazeri/mushak/internal/config"
+)
+
+func TestGenerateBuildSettingsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   config.BuildConfig
+		want    string
+		wantErr bool
+	}{
+		{name: "defaults", want: ""},
+		{name: "registry only", build: config.BuildConfig{Registry: "ghcr.io/acme/app"}, want: ""},
+		{
+			name: "configured",
+			build: config.BuildConfig{
+				Dockerfile:  "Dockerfile.prod",
+				Context:     "app",
+				Target:      "runner",
+				Platform:    "linux/amd64",
+				Args:        map[string]string{"VERSION": "1.2", "NODE_ENV": "production=1"},
+				ArgsFromEnv: []string{"NEXT_PUBLIC_API_URL"},
+			},
+			want: "dockerfile=Dockerfile.prod\ncontext=app\ntarget=runner\nplatform=linux/amd64\n" +
+				"arg=NODE_ENV=production=1\narg=VERSION=1.2\nenv_arg=NEXT_PUBLIC_API_URL",
+		},
+		{
+			name: "secrets and ssh",
+			build: config.BuildConfig{
+				Secrets: []config.BuildSecret{{ID: "npm_token", Env: "NPM_TOKEN"}, {ID: "netrc", File: "secrets/netrc"}},
+				SSH:     config.ForwardSSHAgent,
+			},
+			want: "ssh=agent\nsecret=npm_token=env:NPM_TOKEN\nsecret=netrc=file:secrets/netrc",
+		},
+		{name: "invalid", build: config.BuildConfig{Context: "../other"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateBuildSettingsFile(tt.build)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateBuildSettingsFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateBuildSettingsFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
 
+	"github.com/hmontazeri/mushak/internal/hooks"
 	"github.com/hmontazeri/mushak/internal/ssh"
 	"github.com/hmontazeri/mushak/internal/ui"
 )
@@ -62,10 +66,7 @@ func CreateAppCaddyConfig(executor *ssh.Executor, appName, domain string, port i
 
 	configPath := fmt.Sprintf("/etc/caddy/apps/%s.caddy", appName)
 
-	config := fmt.Sprintf(`%s {
-	reverse_proxy localhost:%d
-}
-`, domain, port)
+	config := appProxySite(appName, domain, port)
 
 	if err := executor.WriteFileSudo(configPath, config); err != nil {
 		return fmt.Errorf("failed to write Caddy config: %w", err)
@@ -80,17 +81,44 @@ func CreateAppCaddyConfig(executor *ssh.Executor, appName, domain string, port i
 	return nil
 }
 
+// appProxySite returns the site block that proxies domain to the app on port,
+// the same one the deploy scripts write
+func appProxySite(appName, domain string, port int) string {
+	return os.Expand(hooks.CaddyProxySite, func(name string) string {
+		switch name {
+		case "APP_NAME":
+			return appName
+		case "DOMAIN":
+			return domain
+		case "HOST_PORT":
+			return strconv.Itoa(port)
+		}
+		return "$" + name
+	})
+}
+
 // RemoveAppCaddyConfig removes the Caddy config for an app
 func RemoveAppCaddyConfig(executor *ssh.Executor, appName string) error {
 	ui.PrintInfo(fmt.Sprintf("Removing Caddy config for %s...", appName))
 
 	configPath := fmt.Sprintf("/etc/caddy/apps/%s.caddy", appName)
 
-	// Remove config file
-	if _, err := executor.RunSudo(fmt.Sprintf("rm -f %s", configPath)); err != nil {
+	// Remove config file and app snippets
+	if _, err := executor.RunSudo(fmt.Sprintf("rm -rf %s %s", configPath, appSnippetDir(appName))); err != nil {
 		return fmt.Errorf("failed to remove Caddy config: %w", err)
 	}
 
+	// Remove access logs, including rotated ones (<app>-<timestamp>.log[.gz])
+	logPath := AccessLogPath(appName)
+	if _, err := executor.RunSudo(fmt.Sprintf("rm -f %s %s-[0-9]*.log*", logPath, strings.TrimSuffix(logPath, ".log"))); err != nil {
+		return fmt.Errorf("failed to remove access logs: %w", err)
+	}
+
+	// Remove certificates and DNS credentials managed by 'mushak tls'
+	if err := removeTLSFiles(executor, appName); err != nil {
+		return err
+	}
+
 	// Reload Caddy
 	if err := ReloadCaddy(executor); err != nil {
 		return err
@@ -100,6 +128,73 @@ func RemoveAppCaddyConfig(executor *ssh.Executor, appName string) error {
 	return nil
 }
 
+// appSnippetDir returns the directory holding extra Caddy directives for an app.
+// Every generated site block imports *.conf from it (and *.proxy inside the
+// reverse_proxy block), so settings written there survive deploys and
+// rollbacks that rewrite the site block.
+func appSnippetDir(appName string) string {
+	return fmt.Sprintf("/etc/caddy/apps/%s.d", appName)
+}
+
+// writeAppSnippet writes a named snippet for the app, returning false if the
+// existing snippet already has the same content
+func writeAppSnippet(executor *ssh.Executor, appName, name, content string) (bool, error) {
+	path := fmt.Sprintf("%s/%s", appSnippetDir(appName), snippetFile(name))
+
+	if existing, err := executor.Run(fmt.Sprintf("cat %s 2>/dev/null", path)); err == nil && strings.TrimSpace(existing) == strings.TrimSpace(content) {
+		return false, nil
+	}
+
+	if _, err := executor.RunSudo(fmt.Sprintf("mkdir -p %s", appSnippetDir(appName))); err != nil {
+		return false, fmt.Errorf("failed to create snippet directory: %w", err)
+	}
+
+	if err := executor.WriteFileSudo(path, content); err != nil {
+		return false, fmt.Errorf("failed to write %s snippet: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// snippetFile returns the file name for a snippet; names that already carry
+// an extension (e.g. "protection.proxy") are used as-is
+func snippetFile(name string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return name + ".conf"
+}
+
+// removeAppSnippet removes a named snippet, returning false if it did not exist
+func removeAppSnippet(executor *ssh.Executor, appName, name string) (bool, error) {
+	path := fmt.Sprintf("%s/%s", appSnippetDir(appName), snippetFile(name))
+
+	if exists, _ := executor.FileExists(path); !exists {
+		return false, nil
+	}
+
+	if _, err := executor.RunSudo(fmt.Sprintf("rm -f %s", path)); err != nil {
+		return false, fmt.Errorf("failed to remove %s snippet: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// ensureCaddyModule adds a Caddy plugin package if the module is not compiled in.
+// Note that a Caddy package upgrade replaces the binary and drops added packages.
+func ensureCaddyModule(executor *ssh.Executor, module, pkg string) error {
+	if _, err := executor.Run(fmt.Sprintf("caddy list-modules 2>/dev/null | grep -qx %s", module)); err == nil {
+		return nil
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Installing Caddy module %s...", pkg))
+	if _, err := executor.RunSudo(fmt.Sprintf("caddy add-package %s", pkg)); err != nil {
+		return fmt.Errorf("failed to add Caddy module %s: %w", pkg, err)
+	}
+
+	return nil
+}
+
 // ReloadCaddy reloads the Caddy server
 func ReloadCaddy(executor *ssh.Executor) error {
 	// Try systemctl reload first
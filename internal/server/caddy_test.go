@@ -65,6 +65,25 @@ func TestCreateAppCaddyConfig(t *testing.T) {
 	}
 }
 
+func TestAppProxySite(t *testing.T) {
+	site := appProxySite("myapp", "example.com", 8000)
+
+	for _, want := range []string{
+		"example.com {",
+		"import " + appSnippetDir("myapp") + "/*.conf",
+		"output file " + AccessLogPath("myapp") + " {",
+		"reverse_proxy localhost:8000 {",
+		"import " + appSnippetDir("myapp") + "/*.proxy",
+	} {
+		if !strings.Contains(site, want) {
+			t.Errorf("appProxySite() missing %q in:\n%s", want, site)
+		}
+	}
+	if strings.Contains(site, "$") {
+		t.Errorf("appProxySite() left a shell variable in:\n%s", site)
+	}
+}
+
 func TestRemoveAppCaddyConfig(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hmontazeri/mushak/internal/ssh"
+)
+
+// legacyEnvVersionFormat is the timestamp format that was used as version ID.
+// Versions are now numbered, with their time recorded next to the ID.
+const legacyEnvVersionFormat = "20060102-150405"
+
+// EnvVersion is a saved version of the app's environment file
+type EnvVersion struct {
+	ID     string
+	Time   time.Time
+	File   string // relative to the app directory: .env.prod, .env or .env.d/<service>.env
+	Author string
+}
+
+func envHistoryDir(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.env-history", appName)
+}
+
+func envVersionPath(appName, id string) string {
+	return fmt.Sprintf("%s/%s.env", envHistoryDir(appName), id)
+}
+
+// WriteEnvFile writes the app's environment file and records the new content
+// as a version in the env history. If the file existed before history was
+// kept, its previous content is recorded first so it can be restored.
+func WriteEnvFile(executor *ssh.Executor, appName, targetPath, content, author string) error {
+	dir := envHistoryDir(appName)
+	file := envFileName(appName, targetPath)
+
+	// The heredoc used for writing adds the final newline
+	content = strings.TrimSuffix(content, "\n")
+
+	if _, err := executor.RunSudo(fmt.Sprintf("mkdir -p %s && chmod 700 %s", dir, dir)); err != nil {
+		return fmt.Errorf("failed to create env history directory: %w", err)
+	}
+
+	// Per-service env files live in a subdirectory
+	if _, err := executor.RunSudo(fmt.Sprintf("mkdir -p %s", path.Dir(targetPath))); err != nil {
+		return fmt.Errorf("failed to create %s: %w", path.Dir(targetPath), err)
+	}
+
+	versions, err := ListEnvVersions(executor, appName)
+	if err != nil {
+		return err
+	}
+
+	if !hasEnvVersion(versions, file) {
+		if previous, err := executor.RunSudo(fmt.Sprintf("cat %s", targetPath)); err == nil {
+			// The baseline is dated by when the file was last written
+			modified := time.Time{}
+			if out, err := executor.RunSudo(fmt.Sprintf("stat -c %%Y %s", targetPath)); err == nil {
+				if epoch, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64); err == nil {
+					modified = time.Unix(epoch, 0).UTC()
+				}
+			}
+			if err := recordEnvVersion(executor, appName, modified, file, "unknown (before history)", strings.TrimSuffix(previous, "\n")); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := executor.WriteFileSudo(targetPath, content); err != nil {
+		return fmt.Errorf("failed to write environment file: %w", err)
+	}
+
+	return recordEnvVersion(executor, appName, time.Now().UTC(), file, author, content)
+}
+
+// envFileName returns the env file path relative to the app directory,
+// e.g. ".env.prod" or ".env.d/worker.env"
+func envFileName(appName, targetPath string) string {
+	return strings.TrimPrefix(targetPath, fmt.Sprintf("/var/www/%s/", appName))
+}
+
+func hasEnvVersion(versions []EnvVersion, file string) bool {
+	for _, v := range versions {
+		if v.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// recordEnvVersion stores content as a new version and appends it to the
+// history index. Versions are numbered in the order they are recorded. The
+// number is taken under a lock and an existing version is never overwritten,
+// so concurrent writes each get a version of their own.
+func recordEnvVersion(executor *ssh.Executor, appName string, at time.Time, file, author, content string) error {
+	timestamp := "-"
+	if !at.IsZero() {
+		timestamp = at.Format(time.RFC3339)
+	}
+
+	// Index format: ID TIME FILE AUTHOR (author may contain spaces)
+	entry := fmt.Sprintf("%s %s %s", timestamp, file, strings.Join(strings.Fields(author), " "))
+	script := fmt.Sprintf(`set -e
+cd %s
+exec 9>>index.lock
+flock 9
+touch index
+id=$(( $(wc -l < index) + 1 ))
+while [ -e "$id.env" ]; do id=$((id + 1)); done
+set -C
+cat > "$id.env"
+echo "$id "%s >> index`, envHistoryDir(appName), shellQuote(entry))
+
+	if _, err := executor.RunWithInput("sudo sh -c "+shellQuote(script), strings.NewReader(content+"\n")); err != nil {
+		return fmt.Errorf("failed to save env version: %w", err)
+	}
+
+	return nil
+}
+
+// ListEnvVersions returns the recorded env versions, oldest first
+func ListEnvVersions(executor *ssh.Executor, appName string) ([]EnvVersion, error) {
+	output, err := executor.RunSudo(fmt.Sprintf("cat %s/index 2>/dev/null || true", envHistoryDir(appName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env history: %w", err)
+	}
+
+	return ParseEnvHistoryIndex(output), nil
+}
+
+// ReadEnvVersion returns the content of an env version
+func ReadEnvVersion(executor *ssh.Executor, appName, id string) (string, error) {
+	content, err := executor.RunSudo(fmt.Sprintf("cat %s", envVersionPath(appName, id)))
+	if err != nil {
+		return "", fmt.Errorf("env version %s not found", id)
+	}
+	return content, nil
+}
+
+// ParseEnvHistoryIndex parses the env history index, oldest first. Versions
+// recorded before they were numbered use their timestamp as ID.
+func ParseEnvHistoryIndex(content string) []EnvVersion {
+	var versions []EnvVersion
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+
+		if fields := strings.SplitN(line, " ", 4); len(fields) >= 3 {
+			if _, err := strconv.Atoi(fields[0]); err == nil {
+				v := EnvVersion{ID: fields[0], File: fields[2]}
+				v.Time, _ = time.Parse(time.RFC3339, fields[1])
+				if len(fields) == 4 {
+					v.Author = fields[3]
+				}
+				versions = append(versions, v)
+				continue
+			}
+		}
+
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			continue
+		}
+
+		t, err := time.Parse(legacyEnvVersionFormat, fields[0])
+		if err != nil {
+			continue
+		}
+
+		v := EnvVersion{ID: fields[0], Time: t, File: fields[1]}
+		if len(fields) == 3 {
+			v.Author = fields[2]
+		}
+		versions = append(versions, v)
+	}
+
+	return versions
+}
+
+// shellQuote quotes s for safe use as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestParseEnvHistoryIndex(t *testing.T) {
+	content := `1 - .env.prod unknown (before history)
+2 2026-10-18T12:00:00Z .env.prod Jane Doe <jane@example.com>
+garbage line
+3 2026-10-18T12:00:00Z .env.d/worker.env ci
+`
+
+	versions := ParseEnvHistoryIndex(content)
+	if len(versions) != 3 {
+		t.Fatalf("ParseEnvHistoryIndex() returned %d versions, want 3", len(versions))
+	}
+
+	// In the order they were recorded, even within the same second
+	wantIDs := []string{"1", "2", "3"}
+	for i, id := range wantIDs {
+		if versions[i].ID != id {
+			t.Errorf("versions[%d].ID = %s, want %s", i, versions[i].ID, id)
+		}
+	}
+
+	if versions[1].Author != "Jane Doe <jane@example.com>" {
+		t.Errorf("versions[1].Author = %q, want full author with spaces", versions[1].Author)
+	}
+	if versions[1].File != ".env.prod" {
+		t.Errorf("versions[1].File = %q, want .env.prod", versions[1].File)
+	}
+	if versions[2].File != ".env.d/worker.env" {
+		t.Errorf("versions[2].File = %q, want .env.d/worker.env", versions[2].File)
+	}
+	if versions[1].Time.Day() != 18 || versions[1].Time.Hour() != 12 {
+		t.Errorf("versions[1].Time = %v, want 2026-10-18 12:00", versions[1].Time)
+	}
+	if !versions[0].Time.IsZero() {
+		t.Errorf("versions[0].Time = %v, want zero for an unknown time", versions[0].Time)
+	}
+}
+
+func TestParseEnvHistoryIndex_Legacy(t *testing.T) {
+	content := `20261017-090000 .env.prod unknown (before history)
+20261018-120000 .env.prod Jane Doe <jane@example.com>
+3 2026-10-19T08:00:00Z .env.prod ci
+`
+
+	versions := ParseEnvHistoryIndex(content)
+	if len(versions) != 3 {
+		t.Fatalf("ParseEnvHistoryIndex() returned %d versions, want 3", len(versions))
+	}
+
+	if versions[0].ID != "20261017-090000" || versions[0].Time.Day() != 17 || versions[0].Time.Hour() != 9 {
+		t.Errorf("versions[0] = %+v, want timestamp ID from 2026-10-17 09:00", versions[0])
+	}
+	if versions[1].Author != "Jane Doe <jane@example.com>" {
+		t.Errorf("versions[1].Author = %q, want full author with spaces", versions[1].Author)
+	}
+	if versions[2].ID != "3" {
+		t.Errorf("versions[2].ID = %s, want 3", versions[2].ID)
+	}
+}
+
+func TestParseEnvHistoryIndex_Empty(t *testing.T) {
+	if versions := ParseEnvHistoryIndex(""); len(versions) != 0 {
+		t.Errorf("ParseEnvHistoryIndex(\"\") = %v, want empty", versions)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"simple":        "'simple'",
+		"with space":    "'with space'",
+		"O'Brien":       `'O'\''Brien'`,
+		"$(rm -rf /)":   "'$(rm -rf /)'",
+		"<a@b.example>": "'<a@b.example>'",
+	}
+
+	for input, want := range tests {
+		if got := shellQuote(input); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestEnvFileName(t *testing.T) {
+	tests := map[string]string{
+		"/var/www/myapp/.env.prod":         ".env.prod",
+		"/var/www/myapp/.env":              ".env",
+		"/var/www/myapp/.env.d/worker.env": ".env.d/worker.env",
+	}
+
+	for targetPath, want := range tests {
+		if got := envFileName("myapp", targetPath); got != want {
+			t.Errorf("envFileName(%q) = %q, want %q", targetPath, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/ssh"
+)
+
+// EnvSchemaPath returns where the env schema is stored on the server.
+// The post-receive hook validates the environment against it before building.
+func EnvSchemaPath(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.env-schema", appName)
+}
+
+// GenerateEnvSchemaFile renders the schema for the hook, one variable per line:
+// KEY, required (0 or 1), type, default and pattern, separated by \x1f so
+// empty fields survive bash's `read`
+func GenerateEnvSchemaFile(env config.EnvConfig) string {
+	var lines []string
+	for _, key := range env.Keys() {
+		s := env.Schema[key]
+
+		required := "0"
+		if s.Required {
+			required = "1"
+		}
+
+		lines = append(lines, strings.Join([]string{key, required, s.Type, s.Default, s.Pattern}, "\x1f"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SyncEnvSchema uploads the env schema from mushak.yaml, or removes it if the
+// schema is empty
+func SyncEnvSchema(executor *ssh.Executor, appName string, env config.EnvConfig) error {
+	path := EnvSchemaPath(appName)
+
+	if err := env.Check(); err != nil {
+		return fmt.Errorf("invalid mushak.yaml: %w", err)
+	}
+
+	if len(env.Schema) == 0 {
+		if _, err := executor.Run(fmt.Sprintf("rm -f %s", path)); err != nil {
+			return fmt.Errorf("failed to remove env schema: %w", err)
+		}
+		return nil
+	}
+
+	if err := executor.WriteFile(path, GenerateEnvSchemaFile(env)); err != nil {
+		return fmt.Errorf("failed to upload env schema: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/hmontazeri/mushak/internal/config"
+)
+
+func TestGenerateEnvSchemaFile(t *testing.T) {
+	env := config.EnvConfig{Schema: map[string]config.EnvVarSchema{
+		"PORT":         {Type: "int", Default: "3000"},
+		"DATABASE_URL": {Required: true, Pattern: "^postgres://"},
+	}}
+
+	want := "DATABASE_URL\x1f1\x1f\x1f\x1f^postgres://\n" +
+		"PORT\x1f0\x1fint\x1f3000\x1f"
+
+	if got := GenerateEnvSchemaFile(env); got != want {
+		t.Errorf("GenerateEnvSchemaFile() = %q, want %q", got, want)
+	}
+}
+
+func TestEnvSchemaPath(t *testing.T) {
+	if got := EnvSchemaPath("myapp"); got != "/var/www/myapp/.env-schema" {
+		t.Errorf("EnvSchemaPath() = %s", got)
+	}
+}
@@ -64,6 +64,11 @@ func InstallPostReceiveHook(executor *ssh.Executor, appName, hookScript string)
 		return fmt.Errorf("failed to make hook executable: %w", err)
 	}
 
+	// Let 'mushak deploy' tell the hook who is deploying
+	if _, err := executor.Run(fmt.Sprintf("git --git-dir=/var/repo/%s.git config receive.advertisePushOptions true", appName)); err != nil {
+		return fmt.Errorf("failed to enable push options: %w", err)
+	}
+
 	ui.PrintSuccess("Post-receive hook installed")
 	return nil
 }
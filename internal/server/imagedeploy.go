@@ -0,0 +1,223 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/hooks"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/utils"
+)
+
+// imageRefPattern matches image references like registry.example.com:5000/team/app:1.2.3
+var imageRefPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/:@-]*$`)
+
+// ValidateImageRef checks that image is a valid image reference
+func ValidateImageRef(image string) error {
+	if !imageRefPattern.MatchString(image) {
+		return fmt.Errorf("invalid image reference: %s", image)
+	}
+	return nil
+}
+
+// ImageDeploySettings are the settings an image release is started with.
+// There is no checkout on the server to read mushak.yaml from, so they are
+// passed in and recorded in the release directory for rollbacks and restarts.
+type ImageDeploySettings struct {
+	InternalPort    int
+	HealthPath      string
+	HealthTimeout   int
+	DrainSeconds    int
+	StopGracePeriod int
+}
+
+// DeployImage deploys a prebuilt image from a registry: the server pulls it
+// and switches traffic to it with the usual health check, without checking
+// out any source. The release is named image-<image ID>.
+func DeployImage(executor *ssh.Executor, cfg *config.DeployConfig, image string, settings ImageDeploySettings) error {
+	if err := ValidateImageRef(image); err != nil {
+		return err
+	}
+
+	script := generateImageDeployScript(cfg.AppName, cfg.Domain, image, utils.DeployUser(), settings)
+
+	fmt.Println("----------------------------------------")
+	if err := executor.StreamRun(script, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("deployment failed: %w", err)
+	}
+	fmt.Println("----------------------------------------")
+
+	return nil
+}
+
+// generateImageDeployScript generates a bash script that pulls image and deploys it
+func generateImageDeployScript(appName, domain, image, deployedBy string, s ImageDeploySettings) string {
+	return fmt.Sprintf(`#!/bin/bash
+set -e
+
+APP_NAME="%s"
+DOMAIN="%s"
+IMAGE=%s
+DEPLOYED_BY=%s
+INTERNAL_PORT=%d
+HEALTH_PATH=%s
+HEALTH_TIMEOUT=%d
+DRAIN_SECONDS=%d
+STOP_GRACE_PERIOD=%d
+IMAGE_REPO="mushak-$APP_NAME"
+NETWORK_NAME="mushak-${APP_NAME}-net"
+
+echo "========================================="
+echo "Mushak Deployment Started"
+echo "========================================="
+echo "App: $APP_NAME"
+echo "Image: $IMAGE"
+
+echo ""
+echo "→ Pulling image..."
+
+# Credentials come from 'mushak registry login'
+docker pull "$IMAGE"
+
+# Releases are named after the image, so deploying the same image again reuses the release
+IMAGE_ID=$(docker image inspect --format '{{.Id}}' "$IMAGE")
+IMAGE_ID=${IMAGE_ID#sha256:}
+SHA="image-${IMAGE_ID:0:7}"
+echo "Release: $SHA"
+
+DEPLOY_DIR="/var/www/$APP_NAME/$SHA"
+CURRENT_LINK="/var/www/$APP_NAME/current"
+PROJECT_NAME="mushak-$APP_NAME-$SHA"
+CONTAINER_NAME="$PROJECT_NAME"
+
+echo ""
+echo "→ Finding available port..."
+
+%s
+HOST_PORT=$(find_free_port)
+echo "  Using port: $HOST_PORT"
+
+# .mushak-release marks the directory as a release, so cleanup removes it if
+# the deploy fails; the metadata is written on success
+mkdir -p "$DEPLOY_DIR"
+touch "$DEPLOY_DIR/.mushak-release"
+cd "$DEPLOY_DIR"
+
+# Record the settings the release runs with, for rollbacks and env restarts
+cat > mushak.yaml <<EOF
+internal_port: $INTERNAL_PORT
+health_path: $HEALTH_PATH
+health_timeout: $HEALTH_TIMEOUT
+drain_seconds: $DRAIN_SECONDS
+stop_grace_period: $STOP_GRACE_PERIOD
+EOF
+
+%s
+
+%s
+
+%s
+
+# Ensure network exists
+docker network create $NETWORK_NAME 2>/dev/null || true
+
+# Tag the image like a built release, so rollbacks and cleanup treat it the same
+docker tag "$IMAGE" "${IMAGE_REPO}:${SHA}"
+docker tag "$IMAGE" "${IMAGE_REPO}:latest"
+
+echo ""
+echo "→ Starting container..."
+
+# Stop any existing container with the same name
+docker stop "$CONTAINER_NAME" 2>/dev/null || true
+docker rm "$CONTAINER_NAME" 2>/dev/null || true
+
+ENV_OPTS=""
+if [ -f ".env" ]; then
+    ENV_OPTS="--env-file .env"
+fi
+if [ -n "$SECRETS_ENV" ]; then
+    ENV_OPTS="$ENV_OPTS --env-file $SECRETS_ENV"
+fi
+
+docker run -d --name "$CONTAINER_NAME" --network "$NETWORK_NAME" $ENV_OPTS -p $HOST_PORT:$INTERNAL_PORT "${IMAGE_REPO}:${SHA}"
+
+echo "  Container started: $CONTAINER_NAME"
+
+echo ""
+echo "→ Waiting for service to be healthy..."
+
+# Health check with retry
+RETRY_COUNT=0
+until curl -sf http://localhost:$HOST_PORT$HEALTH_PATH > /dev/null 2>&1; do
+    RETRY_COUNT=$((RETRY_COUNT + 1))
+
+    if [ $RETRY_COUNT -ge $HEALTH_TIMEOUT ]; then
+        echo ""
+        echo "ERROR: Health check failed after $HEALTH_TIMEOUT seconds" >&2
+        echo "Rolling back..."
+
+        docker stop "$CONTAINER_NAME" 2>/dev/null || true
+        docker rm "$CONTAINER_NAME" 2>/dev/null || true
+
+        exit 1
+    fi
+
+    echo -n "."
+    sleep 1
+done
+
+echo ""
+echo "  Service is healthy!"
+
+echo ""
+echo "→ Updating Caddy configuration..."
+
+# Remember the previous release's port so its connections can be drained
+OLD_PORT=$(grep -oE "localhost:[0-9]+" /etc/caddy/apps/$APP_NAME.caddy 2>/dev/null | head -1 | cut -d: -f2)
+
+%s
+# Update current symlink
+ln -snf "$DEPLOY_DIR" "$CURRENT_LINK"
+
+%s
+echo ""
+echo "→ Stopping old containers..."
+
+%s
+# Record deployment to manifest file (for rollback listing)
+DEPLOYMENTS_FILE="/var/www/$APP_NAME/.deployments"
+echo "${SHA} $(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ) ${HOST_PORT} image" >> "$DEPLOYMENTS_FILE"
+
+# Record release metadata. There is no commit, so the image reference is the subject
+ENV_FINGERPRINT=$(cat "$DEPLOY_DIR/.env" "$DEPLOY_DIR"/.env.d/*.env 2>/dev/null | sha256sum | cut -c1-12)
+cat > "$DEPLOY_DIR/.mushak-release" <<EOF
+commit=
+subject=$IMAGE
+author=
+deployed_by=$DEPLOYED_BY
+config=$(sha256sum "$DEPLOY_DIR/mushak.yaml" | cut -c1-12)
+env=$ENV_FINGERPRINT
+EOF
+
+echo ""
+%s
+
+echo ""
+echo "========================================="
+echo "✓ Deployment Successful!"
+echo "========================================="
+echo "App: $APP_NAME"
+echo "Image: $IMAGE"
+echo "Release: $SHA"
+echo "Port: $HOST_PORT"
+echo "URL: https://$DOMAIN"
+echo "========================================="
+`, appName, domain, shellQuote(image), shellQuote(deployedBy),
+		s.InternalPort, shellQuote(s.HealthPath), s.HealthTimeout, s.DrainSeconds, s.StopGracePeriod,
+		hooks.FindFreePortScript, hooks.CopyEnvFilesScript, hooks.DecryptSecretsScript, hooks.EnvSchemaScript,
+		hooks.CaddyProxyScript, hooks.DrainConnectionsScript, hooks.StopPreviousContainersScript,
+		hooks.CleanupReleasesScript)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateImageRef(t *testing.T) {
+	valid := []string{
+		"nginx",
+		"ghcr.io/acme/app:1.2.3",
+		"localhost:5000/app:latest",
+		"registry.example.com/app@sha256:0123abcd",
+	}
+	for _, image := range valid {
+		if err := ValidateImageRef(image); err != nil {
+			t.Errorf("ValidateImageRef(%q) error = %v", image, err)
+		}
+	}
+
+	invalid := []string{"", "app:1; rm -rf /", "-app", "app name"}
+	for _, image := range invalid {
+		if err := ValidateImageRef(image); err == nil {
+			t.Errorf("ValidateImageRef(%q) should fail", image)
+		}
+	}
+}
+
+func TestGenerateImageDeployScript(t *testing.T) {
+	settings := ImageDeploySettings{
+		InternalPort:    3000,
+		HealthPath:      "/health",
+		HealthTimeout:   60,
+		DrainSeconds:    5,
+		StopGracePeriod: 20,
+	}
+	script := generateImageDeployScript("myapp", "app.example.com", "ghcr.io/acme/app:1.2.3", "Jo", settings)
+
+	elements := []string{
+		`APP_NAME="myapp"`,
+		`IMAGE='ghcr.io/acme/app:1.2.3'`,
+		"INTERNAL_PORT=3000",
+		"HEALTH_PATH='/health'",
+		"STOP_GRACE_PERIOD=20",
+		`docker pull "$IMAGE"`,
+		`SHA="image-${IMAGE_ID:0:7}"`,
+		// Recorded for rollbacks and restarts
+		"internal_port: $INTERNAL_PORT",
+		`docker tag "$IMAGE" "${IMAGE_REPO}:${SHA}"`,
+		`-p $HOST_PORT:$INTERNAL_PORT "${IMAGE_REPO}:${SHA}"`,
+		"until curl -sf http://localhost:$HOST_PORT$HEALTH_PATH",
+		"reverse_proxy localhost:$HOST_PORT",
+		`${SHA} $(date -u +%Y-%m-%dT%H:%M:%SZ) ${HOST_PORT} image`,
+		"subject=$IMAGE",
+		"Cleaning up old releases",
+		"Loading environment variables",
+		"Validating environment variables",
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing element: %q", element)
+		}
+	}
+
+	// No source is checked out
+	if strings.Contains(script, "git checkout") {
+		t.Error("image deploys should not check out source")
+	}
+}
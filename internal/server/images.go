@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/ssh"
+)
+
+// PrebuiltImage returns the name the post-receive hook looks for an image
+// built by 'mushak deploy --build local' under. The hook deploys it instead of
+// building commit. service is "" for Dockerfile apps.
+func PrebuiltImage(appName, commit, service string) string {
+	if service == "" {
+		return fmt.Sprintf("mushak-%s-prebuilt:%s", appName, commit)
+	}
+	return fmt.Sprintf("mushak-%s-prebuilt:%s-%s", appName, commit, service)
+}
+
+// DockerPlatform returns the platform of the server's Docker engine, e.g.
+// linux/amd64, so images built elsewhere can target it
+func DockerPlatform(executor *ssh.Executor) (string, error) {
+	output, err := executor.Run("docker version --format '{{.Server.Os}}/{{.Server.Arch}}'")
+	if err != nil {
+		return "", fmt.Errorf("failed to read server platform: %w", err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// ExistingImages returns which of the image IDs the server already has
+func ExistingImages(executor *ssh.Executor, ids []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	// inspect prints the images it finds and fails for the rest
+	output, err := executor.Run(fmt.Sprintf("docker image inspect --format '{{.Id}}' %s 2>/dev/null || true", strings.Join(ids, " ")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect server images: %w", err)
+	}
+	for _, id := range strings.Fields(output) {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// LayerChains returns the layer chains the server's images are built from:
+// every prefix of each image's layers, joined by commas. A layer is only
+// reused in the same position on top of the same layers, so the chain up to
+// it is what needs to match.
+func LayerChains(executor *ssh.Executor) (map[string]bool, error) {
+	output, err := executor.Run("docker images -q --no-trunc | sort -u | xargs -r docker image inspect --format '{{range .RootFS.Layers}}{{.}} {{end}}' 2>/dev/null || true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect server images: %w", err)
+	}
+	return parseLayerChains(output), nil
+}
+
+// parseLayerChains parses lines of space-separated layer digests into the
+// chains of their prefixes
+func parseLayerChains(output string) map[string]bool {
+	chains := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		layers := strings.Fields(line)
+		for i := range layers {
+			chains[strings.Join(layers[:i+1], ",")] = true
+		}
+	}
+	return chains
+}
+
+// TagImage tags an image on the server
+func TagImage(executor *ssh.Executor, source, target string) error {
+	if _, err := executor.Run(fmt.Sprintf("docker tag %s %s", source, target)); err != nil {
+		return fmt.Errorf("failed to tag %s: %w", target, err)
+	}
+	return nil
+}
+
+// LoadImages loads a gzipped 'docker save' archive into the server's Docker
+func LoadImages(executor *ssh.Executor, archive io.Reader) error {
+	if _, err := executor.RunWithInput("gunzip | docker load", archive); err != nil {
+		return fmt.Errorf("failed to load images: %w", err)
+	}
+	return nil
+}
+
+// PullImage pulls ref on the server and tags it as target
+func PullImage(executor *ssh.Executor, ref, target string) error {
+	if _, err := executor.Run(fmt.Sprintf("docker pull -q %s && docker tag %s %s", ref, ref, target)); err != nil {
+		return fmt.Errorf("failed to pull %s: %w", ref, err)
+	}
+	return nil
+}
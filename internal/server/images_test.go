@@ -0,0 +1,35 @@
+package server
+
+import "testing"
+
+func TestPrebuiltImage(t *testing.T) {
+	tests := []struct {
+		service string
+		want    string
+	}{
+		{"", "mushak-myapp-prebuilt:abc123"},
+		{"worker", "mushak-myapp-prebuilt:abc123-worker"},
+	}
+
+	for _, tt := range tests {
+		if got := PrebuiltImage("myapp", "abc123", tt.service); got != tt.want {
+			t.Errorf("PrebuiltImage(%q) = %q, want %q", tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestParseLayerChains(t *testing.T) {
+	chains := parseLayerChains("sha256:a sha256:b sha256:c \nsha256:a sha256:d \n\n")
+
+	for _, chain := range []string{"sha256:a", "sha256:a,sha256:b", "sha256:a,sha256:b,sha256:c", "sha256:a,sha256:d"} {
+		if !chains[chain] {
+			t.Errorf("parseLayerChains() missing chain %q", chain)
+		}
+	}
+	// Layers only match on top of the same layers
+	for _, chain := range []string{"sha256:b", "sha256:d", "sha256:a,sha256:c"} {
+		if chains[chain] {
+			t.Errorf("parseLayerChains() should not contain %q", chain)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+)
+
+// DefaultMaintenanceMessage is shown when no message or page is given
+const DefaultMaintenanceMessage = "We are performing scheduled maintenance and will be back shortly."
+
+// GenerateMaintenanceSnippet renders the Caddy directives that answer every
+// request with the maintenance page and a 503, except for allowed client IPs
+func GenerateMaintenanceSnippet(appName string, allowIPs []string) string {
+	var b strings.Builder
+
+	matcher := ""
+	if len(allowIPs) > 0 {
+		matcher = " @maintenance"
+		b.WriteString(fmt.Sprintf("@maintenance not remote_ip %s\n", strings.Join(allowIPs, " ")))
+	}
+
+	b.WriteString(fmt.Sprintf(`handle%s {
+	header Retry-After 300
+	root * %s
+	rewrite * /maintenance.html
+	file_server {
+		status 503
+	}
+}
+`, matcher, appSnippetDir(appName)))
+
+	return b.String()
+}
+
+// GenerateMaintenancePage renders a minimal maintenance page with the given message
+func GenerateMaintenancePage(appName, message string) string {
+	if message == "" {
+		message = DefaultMaintenanceMessage
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s - Maintenance</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; background: #f5f7fa; color: #023e8a; }
+main { max-width: 32rem; padding: 2rem; text-align: center; }
+</style>
+</head>
+<body>
+<main>
+<h1>Down for maintenance</h1>
+<p>%s</p>
+</main>
+</body>
+</html>
+`, html.EscapeString(appName), html.EscapeString(message))
+}
+
+// EnableMaintenance puts the app into maintenance mode.
+// The snippet lives next to the app's site block and is imported by every
+// block the hook or rollback writes, so a deploy does not lift maintenance.
+func EnableMaintenance(executor *ssh.Executor, appName, page string, allowIPs []string) error {
+	ui.PrintInfo("Enabling maintenance mode...")
+
+	if _, err := executor.RunSudo(fmt.Sprintf("mkdir -p %s", appSnippetDir(appName))); err != nil {
+		return fmt.Errorf("failed to create snippet directory: %w", err)
+	}
+
+	if err := executor.WriteFileSudo(appSnippetDir(appName)+"/maintenance.html", page); err != nil {
+		return fmt.Errorf("failed to write maintenance page: %w", err)
+	}
+
+	if _, err := writeAppSnippet(executor, appName, "maintenance", GenerateMaintenanceSnippet(appName, allowIPs)); err != nil {
+		return err
+	}
+
+	if err := ReloadCaddy(executor); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess("Maintenance mode enabled")
+	return nil
+}
+
+// DisableMaintenance takes the app out of maintenance mode
+func DisableMaintenance(executor *ssh.Executor, appName string) error {
+	ui.PrintInfo("Disabling maintenance mode...")
+
+	removed, err := removeAppSnippet(executor, appName, "maintenance")
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		ui.PrintInfo("Maintenance mode was not enabled")
+		return nil
+	}
+
+	if _, err := executor.RunSudo(fmt.Sprintf("rm -f %s/maintenance.html", appSnippetDir(appName))); err != nil {
+		return fmt.Errorf("failed to remove maintenance page: %w", err)
+	}
+
+	if err := ReloadCaddy(executor); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess("Maintenance mode disabled")
+	return nil
+}
+
+// IsMaintenanceEnabled reports whether the app is in maintenance mode
+func IsMaintenanceEnabled(executor *ssh.Executor, appName string) bool {
+	exists, _ := executor.FileExists(appSnippetDir(appName) + "/maintenance.conf")
+	return exists
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateMaintenanceSnippet(t *testing.T) {
+	t.Run("all clients", func(t *testing.T) {
+		snippet := GenerateMaintenanceSnippet("myapp", nil)
+
+		if strings.Contains(snippet, "remote_ip") {
+			t.Error("snippet without allowed IPs should not use a remote_ip matcher")
+		}
+
+		expected := []string{
+			"handle {",
+			"root * /etc/caddy/apps/myapp.d",
+			"rewrite * /maintenance.html",
+			"status 503",
+		}
+		for _, e := range expected {
+			if !strings.Contains(snippet, e) {
+				t.Errorf("snippet missing %q:\n%s", e, snippet)
+			}
+		}
+	})
+
+	t.Run("allowed IPs", func(t *testing.T) {
+		snippet := GenerateMaintenanceSnippet("myapp", []string{"203.0.113.7", "10.0.0.0/8"})
+
+		expected := []string{
+			"@maintenance not remote_ip 203.0.113.7 10.0.0.0/8",
+			"handle @maintenance {",
+		}
+		for _, e := range expected {
+			if !strings.Contains(snippet, e) {
+				t.Errorf("snippet missing %q:\n%s", e, snippet)
+			}
+		}
+	})
+}
+
+func TestGenerateMaintenancePage(t *testing.T) {
+	page := GenerateMaintenancePage("myapp", "")
+	if !strings.Contains(page, DefaultMaintenanceMessage) {
+		t.Error("page should contain the default message when none is given")
+	}
+
+	page = GenerateMaintenancePage("myapp", "Back at <b>14:00</b>")
+	if !strings.Contains(page, "Back at &lt;b&gt;14:00&lt;/b&gt;") {
+		t.Error("page should HTML-escape the message")
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+)
+
+// GenerateProtectionSnippet renders the site-level directives for rate limiting
+// and request blocking. An empty string means no protection is configured.
+func GenerateProtectionSnippet(appName string, p config.ProtectionConfig) (string, error) {
+	var b strings.Builder
+
+	if len(p.BlockUserAgents) > 0 {
+		var agents []string
+		for _, ua := range p.BlockUserAgents {
+			if !strings.Contains(ua, "*") {
+				ua = "*" + ua + "*"
+			}
+			agents = append(agents, fmt.Sprintf("%q", ua))
+		}
+		b.WriteString(fmt.Sprintf("@mushak_blocked_agents header User-Agent %s\n", strings.Join(agents, " ")))
+		b.WriteString("respond @mushak_blocked_agents 403\n")
+	}
+
+	if len(p.BlockPaths) > 0 {
+		b.WriteString(fmt.Sprintf("@mushak_blocked_paths path %s\n", strings.Join(p.BlockPaths, " ")))
+		b.WriteString("respond @mushak_blocked_paths 403\n")
+	}
+
+	if p.RateLimit.Requests > 0 {
+		window := p.RateLimit.Window
+		if window == "" {
+			window = "1m"
+		}
+		if _, err := time.ParseDuration(window); err != nil {
+			return "", fmt.Errorf("invalid protection.rate_limit.window %q: %w", window, err)
+		}
+
+		// Wrapped in a route so no global 'order' option is needed for the plugin
+		b.WriteString(fmt.Sprintf(`route {
+	rate_limit {
+		zone %s_per_ip {
+			key {remote_host}
+			events %d
+			window %s
+		}
+	}
+}
+`, appName, p.RateLimit.Requests, window))
+	}
+
+	return b.String(), nil
+}
+
+// GenerateProxyProtectionSnippet renders directives for the app's reverse_proxy block
+func GenerateProxyProtectionSnippet(p config.ProtectionConfig) string {
+	if p.MaxConnections <= 0 {
+		return ""
+	}
+
+	// Requests beyond the limit wait for a free connection instead of piling onto the app
+	return fmt.Sprintf(`transport http {
+	max_conns_per_host %d
+}
+`, p.MaxConnections)
+}
+
+// ApplyProtectionConfig writes the app's protection snippets and reloads Caddy if they changed
+func ApplyProtectionConfig(executor *ssh.Executor, appName string, p config.ProtectionConfig) error {
+	site, err := GenerateProtectionSnippet(appName, p)
+	if err != nil {
+		return err
+	}
+	proxy := GenerateProxyProtectionSnippet(p)
+
+	// Rate limiting is not part of standard Caddy builds
+	if p.RateLimit.Requests > 0 {
+		if err := ensureCaddyModule(executor, "http.handlers.rate_limit", "github.com/mholt/caddy-ratelimit"); err != nil {
+			return err
+		}
+	}
+
+	changed := false
+	for name, content := range map[string]string{"protection": site, "protection.proxy": proxy} {
+		var c bool
+		if content == "" {
+			c, err = removeAppSnippet(executor, appName, name)
+		} else {
+			c, err = writeAppSnippet(executor, appName, name, content)
+		}
+		if err != nil {
+			return err
+		}
+		changed = changed || c
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := ReloadCaddy(executor); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess("Proxy protection settings applied")
+	return nil
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hmontazeri/mushak/internal/config"
+)
+
+func TestGenerateProtectionSnippet(t *testing.T) {
+	t.Run("no protection", func(t *testing.T) {
+		snippet, err := GenerateProtectionSnippet("myapp", config.ProtectionConfig{})
+		if err != nil {
+			t.Fatalf("GenerateProtectionSnippet() error = %v", err)
+		}
+		if snippet != "" {
+			t.Errorf("GenerateProtectionSnippet() = %q, want empty", snippet)
+		}
+	})
+
+	t.Run("full protection", func(t *testing.T) {
+		snippet, err := GenerateProtectionSnippet("myapp", config.ProtectionConfig{
+			RateLimit:       config.RateLimitConfig{Requests: 100},
+			BlockUserAgents: []string{"AhrefsBot", "*curl*"},
+			BlockPaths:      []string{"/wp-admin*", "/.env"},
+		})
+		if err != nil {
+			t.Fatalf("GenerateProtectionSnippet() error = %v", err)
+		}
+
+		expected := []string{
+			`@mushak_blocked_agents header User-Agent "*AhrefsBot*" "*curl*"`,
+			"respond @mushak_blocked_agents 403",
+			"@mushak_blocked_paths path /wp-admin* /.env",
+			"respond @mushak_blocked_paths 403",
+			"zone myapp_per_ip {",
+			"key {remote_host}",
+			"events 100",
+			"window 1m",
+		}
+		for _, e := range expected {
+			if !strings.Contains(snippet, e) {
+				t.Errorf("snippet missing %q:\n%s", e, snippet)
+			}
+		}
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		_, err := GenerateProtectionSnippet("myapp", config.ProtectionConfig{
+			RateLimit: config.RateLimitConfig{Requests: 10, Window: "soon"},
+		})
+		if err == nil {
+			t.Error("GenerateProtectionSnippet() should reject an invalid window")
+		}
+	})
+}
+
+func TestGenerateProxyProtectionSnippet(t *testing.T) {
+	if got := GenerateProxyProtectionSnippet(config.ProtectionConfig{}); got != "" {
+		t.Errorf("GenerateProxyProtectionSnippet() = %q, want empty", got)
+	}
+
+	got := GenerateProxyProtectionSnippet(config.ProtectionConfig{MaxConnections: 50})
+	if !strings.Contains(got, "max_conns_per_host 50") {
+		t.Errorf("GenerateProxyProtectionSnippet() = %q, want max_conns_per_host 50", got)
+	}
+}
+
+func TestSnippetFile(t *testing.T) {
+	if got := snippetFile("tls"); got != "tls.conf" {
+		t.Errorf("snippetFile(tls) = %q, want tls.conf", got)
+	}
+	if got := snippetFile("protection.proxy"); got != "protection.proxy" {
+		t.Errorf("snippetFile(protection.proxy) = %q, want protection.proxy", got)
+	}
+}
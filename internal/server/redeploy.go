@@ -2,11 +2,14 @@ package server
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/hooks"
 	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/utils"
 )
 
 // TriggerRedeploy triggers a redeployment using the existing code on the server
@@ -24,18 +27,142 @@ func TriggerRedeploy(executor *ssh.Executor, cfg *config.DeployConfig) error {
 		return fmt.Errorf("invalid SHA retrieved from server: %s", sha)
 	}
 
+	if _, err := runPostReceiveHook(executor, cfg, sha, sha, "refs/heads/"+cfg.Branch); err != nil {
+		return fmt.Errorf("redeploy failed: %w", err)
+	}
+	return nil
+}
+
+// DeployCommit deploys a commit that is already in the server's repository:
+// the deploy branch is pointed at it and the post-receive hook runs as if it
+// had been pushed. It reports whether a new release was deployed: the hook
+// skips monorepo commits that change nothing in the app's directory.
+func DeployCommit(executor *ssh.Executor, cfg *config.DeployConfig, commit string) (bool, error) {
+	repo := fmt.Sprintf("/var/repo/%s.git", cfg.AppName)
+	branchRef := fmt.Sprintf("refs/heads/%s", cfg.Branch)
+
+	oldrev, err := executor.Run(fmt.Sprintf("git --git-dir=%s rev-parse --verify --quiet %s || echo 0000000000000000000000000000000000000000", repo, branchRef))
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", cfg.Branch, err)
+	}
+
+	if _, err := executor.Run(fmt.Sprintf("git --git-dir=%s update-ref %s %s", repo, branchRef, commit)); err != nil {
+		return false, fmt.Errorf("failed to update %s: %w", cfg.Branch, err)
+	}
+
+	skipped, err := runPostReceiveHook(executor, cfg, strings.TrimSpace(oldrev), commit, branchRef)
+	if err != nil {
+		return false, fmt.Errorf("deployment failed: %w", err)
+	}
+	return !skipped, nil
+}
+
+// DirtyRef is the scratch ref uncommitted work is deployed from. The
+// post-receive hook names releases deployed from it dirty-<tree hash>.
+const DirtyRef = "refs/mushak/dirty"
+
+// DeployArchive deploys a gzipped tarball of a working tree without git push:
+// it is committed to DirtyRef in the server's repository and run through the
+// post-receive hook like a pushed commit
+func DeployArchive(executor *ssh.Executor, cfg *config.DeployConfig, archive io.Reader, message string) error {
+	commit, err := executor.RunWithInput(generateImportArchiveScript(cfg.AppName, cfg.Branch, cfg.RootDir, message, utils.DeployUser()), archive)
+	if err != nil {
+		return fmt.Errorf("failed to upload archive: %w", err)
+	}
+	commit = strings.TrimSpace(commit)
+
+	if _, err := runPostReceiveHook(executor, cfg, "0000000000000000000000000000000000000000", commit, DirtyRef); err != nil {
+		return fmt.Errorf("deployment failed: %w", err)
+	}
+	return nil
+}
+
+// generateImportArchiveScript returns a script that extracts the tarball on
+// stdin, commits it on top of the deploy branch, points DirtyRef at the commit
+// and prints its SHA. In a monorepo the tarball holds rootDir, and the rest of
+// the tree is taken from the deploy branch.
+func generateImportArchiveScript(appName, branch, rootDir, message, author string) string {
+	return fmt.Sprintf(`set -e
+export GIT_DIR=/var/repo/%s.git
+ROOT_DIR=%s
+WORK_TREE=$(mktemp -d)
+trap 'rm -rf "$WORK_TREE" "$WORK_TREE.index"' EXIT
+mkdir -p "$WORK_TREE/$ROOT_DIR"
+tar -xzf - -C "$WORK_TREE/$ROOT_DIR"
+
+export GIT_WORK_TREE="$WORK_TREE" GIT_INDEX_FILE="$WORK_TREE.index"
+export GIT_AUTHOR_NAME=%s GIT_AUTHOR_EMAIL= GIT_COMMITTER_NAME=%s GIT_COMMITTER_EMAIL=
+PARENT=$(git rev-parse --verify --quiet refs/heads/%s || true)
+if [ -n "$ROOT_DIR" ] && [ -n "$PARENT" ]; then
+    git read-tree "$PARENT"
+    git add -A -- "$ROOT_DIR"
+else
+    git add -A
+fi
+TREE=$(git write-tree)
+COMMIT=$(git commit-tree "$TREE" ${PARENT:+-p "$PARENT"} -m %s)
+git update-ref %s "$COMMIT"
+echo "$COMMIT"
+`, appName, shellQuote(rootDir), shellQuote(author), shellQuote(author), branch, shellQuote(message), DirtyRef)
+}
+
+// ResolveServerCommit returns the full SHA of rev in the server's repository,
+// or "" if the server doesn't have it
+func ResolveServerCommit(executor *ssh.Executor, appName, rev string) (string, error) {
+	cmd := fmt.Sprintf("git --git-dir=/var/repo/%s.git rev-parse --verify --quiet %s || true", appName, shellQuote(rev+"^{commit}"))
+	sha, err := executor.Run(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s on the server: %w", rev, err)
+	}
+	return strings.TrimSpace(sha), nil
+}
+
+// LiveCommit returns the live release's SHA and the full SHA of its commit in
+// the server's repository. Both are empty if nothing is live, the commit is
+// empty for releases of images or uncommitted work the repository lacks.
+func LiveCommit(executor *ssh.Executor, appName string) (string, string, error) {
+	live, err := getCurrentSHA(executor, appName)
+	if err != nil {
+		// Nothing is live yet
+		return "", "", nil
+	}
+
+	liveCommit, err := ResolveServerCommit(executor, appName, live)
+	return live, liveCommit, err
+}
+
+// IsBehindLive reports whether commit is an ancestor of the live release, so
+// deploying it would go back in history. It also returns the live release's SHA.
+func IsBehindLive(executor *ssh.Executor, appName, commit string) (bool, string, error) {
+	live, liveCommit, err := LiveCommit(executor, appName)
+	if err != nil || liveCommit == "" || liveCommit == commit {
+		return false, live, err
+	}
+
+	out, err := executor.Run(fmt.Sprintf("git --git-dir=/var/repo/%s.git merge-base --is-ancestor %s %s && echo behind || true", appName, commit, liveCommit))
+	if err != nil {
+		return false, live, fmt.Errorf("failed to compare with the live release: %w", err)
+	}
+	return strings.TrimSpace(out) == "behind", live, nil
+}
+
+// runPostReceiveHook feeds a ref update to the post-receive hook the way git
+// does on push. It reports whether the hook skipped the deployment.
+func runPostReceiveHook(executor *ssh.Executor, cfg *config.DeployConfig, oldrev, newrev, refname string) (bool, error) {
 	// Trigger hook
 	// We need to run it as the user, but referencing the script which is chmod +x
-	redeployCmd := fmt.Sprintf(
-		"echo \"%s %s refs/heads/%s\" | GIT_DIR=/var/repo/%s.git /var/repo/%s.git/hooks/post-receive",
-		sha, sha, cfg.Branch, cfg.AppName, cfg.AppName,
+	// Pass who is deploying the way git passes push options
+	hookCmd := fmt.Sprintf(
+		"echo \"%s %s %s\" | GIT_PUSH_OPTION_COUNT=1 GIT_PUSH_OPTION_0=%s GIT_DIR=/var/repo/%s.git /var/repo/%s.git/hooks/post-receive",
+		oldrev, newrev, refname, shellQuote("deploy-user="+utils.DeployUser()), cfg.AppName, cfg.AppName,
 	)
 
 	fmt.Println("----------------------------------------")
-	if err := executor.StreamRun(redeployCmd, os.Stdout, os.Stderr); err != nil {
-		return fmt.Errorf("redeploy failed: %w", err)
+	stdout := utils.NewOutputWatcher(os.Stdout, hooks.DeploymentSkippedNotice)
+	if err := executor.StreamRun(hookCmd, stdout, os.Stderr); err != nil {
+		return false, err
 	}
 	fmt.Println("----------------------------------------")
 
-	return nil
+	return stdout.Found(), nil
 }
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateImportArchiveScript(t *testing.T) {
+	script := generateImportArchiveScript("myapp", "main", "", "Uncommitted changes on top of abc1234", "Jo O'Neil")
+
+	elements := []string{
+		"export GIT_DIR=/var/repo/myapp.git",
+		"ROOT_DIR=''",
+		`tar -xzf - -C "$WORK_TREE/$ROOT_DIR"`,
+		`GIT_AUTHOR_NAME='Jo O'\''Neil'`,
+		"PARENT=$(git rev-parse --verify --quiet refs/heads/main || true)",
+		`-m 'Uncommitted changes on top of abc1234'`,
+		`git update-ref refs/mushak/dirty "$COMMIT"`,
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing element: %q", element)
+		}
+	}
+}
+
+func TestGenerateImportArchiveScript_RootDir(t *testing.T) {
+	script := generateImportArchiveScript("api", "main", "services/api", "Uncommitted changes", "jo")
+
+	elements := []string{
+		"ROOT_DIR='services/api'",
+		`git read-tree "$PARENT"`,
+		`git add -A -- "$ROOT_DIR"`,
+	}
+
+	for _, element := range elements {
+		if !strings.Contains(script, element) {
+			t.Errorf("Script missing monorepo element: %q", element)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/ssh"
+)
+
+// registryPattern matches registry hosts like ghcr.io or localhost:5000
+var registryPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*(:[0-9]+)?$`)
+
+// ValidateRegistry checks that registry is a registry host
+func ValidateRegistry(registry string) error {
+	if !registryPattern.MatchString(registry) {
+		return fmt.Errorf("invalid registry: %s (use a host like ghcr.io or localhost:5000)", registry)
+	}
+	return nil
+}
+
+// RegistryLogin stores registry credentials on the server with 'docker login',
+// so deploys can pull private images. The password is passed on stdin and never
+// appears in a command line.
+func RegistryLogin(executor *ssh.Executor, registry, username, password string) error {
+	if err := ValidateRegistry(registry); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("docker login %s --username %s --password-stdin", registry, shellQuote(username))
+	if _, err := executor.RunWithInput(cmd, strings.NewReader(password)); err != nil {
+		return fmt.Errorf("failed to log in to %s: %w", registry, err)
+	}
+	return nil
+}
+
+// RegistryLogout removes the server's credentials for registry
+func RegistryLogout(executor *ssh.Executor, registry string) error {
+	if err := ValidateRegistry(registry); err != nil {
+		return err
+	}
+
+	if _, err := executor.Run(fmt.Sprintf("docker logout %s", registry)); err != nil {
+		return fmt.Errorf("failed to log out of %s: %w", registry, err)
+	}
+	return nil
+}
+
+// RegistryLogins returns the registries the server has credentials for
+func RegistryLogins(executor *ssh.Executor) ([]string, error) {
+	output, err := executor.Run("cat ~/.docker/config.json 2>/dev/null || true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker config: %w", err)
+	}
+	return parseRegistryLogins(output)
+}
+
+// parseRegistryLogins returns the registries with credentials in a docker config.json
+func parseRegistryLogins(content string) ([]string, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, nil
+	}
+
+	var dockerConfig struct {
+		Auths map[string]json.RawMessage `json:"auths"`
+	}
+	if err := json.Unmarshal([]byte(content), &dockerConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config: %w", err)
+	}
+
+	registries := make([]string, 0, len(dockerConfig.Auths))
+	for registry := range dockerConfig.Auths {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries, nil
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateRegistry(t *testing.T) {
+	for _, registry := range []string{"ghcr.io", "localhost:5000", "registry.example.com"} {
+		if err := ValidateRegistry(registry); err != nil {
+			t.Errorf("ValidateRegistry(%q) error = %v", registry, err)
+		}
+	}
+	for _, registry := range []string{"", "ghcr.io/acme", "host;reboot", "https://ghcr.io"} {
+		if err := ValidateRegistry(registry); err == nil {
+			t.Errorf("ValidateRegistry(%q) should fail", registry)
+		}
+	}
+}
+
+func TestParseRegistryLogins(t *testing.T) {
+	content := `{
+	"auths": {
+		"localhost:5000": {"auth": "dXNlcjpwYXNz"},
+		"ghcr.io": {}
+	},
+	"credsStore": "desktop"
+}`
+
+	registries, err := parseRegistryLogins(content)
+	if err != nil {
+		t.Fatalf("parseRegistryLogins() error = %v", err)
+	}
+	if want := []string{"ghcr.io", "localhost:5000"}; !reflect.DeepEqual(registries, want) {
+		t.Errorf("parseRegistryLogins() = %v, want %v", registries, want)
+	}
+
+	if registries, err := parseRegistryLogins(""); err != nil || len(registries) != 0 {
+		t.Errorf("parseRegistryLogins(\"\") = %v, %v, want none", registries, err)
+	}
+}
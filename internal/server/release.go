@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/ssh"
+)
+
+// ReleaseInfo is the metadata the post-receive hook records for each release
+type ReleaseInfo struct {
+	Commit            string // full commit SHA
+	Subject           string // first line of the commit message
+	Author            string // commit author, "Name <email>"
+	DeployedBy        string // who ran the deploy
+	ConfigFingerprint string // hash of mushak.yaml, "none" without one
+	EnvFingerprint    string // hash of the release's env files
+	Type              string // "static" for static sites, empty for containers
+}
+
+// ReleaseInfoPath returns where the hook stores a release's metadata
+func ReleaseInfoPath(appName, sha string) string {
+	return fmt.Sprintf("/var/www/%s/%s/.mushak-release", appName, sha)
+}
+
+// ParseReleaseInfo parses the key=value lines of a release metadata file
+func ParseReleaseInfo(content string) ReleaseInfo {
+	var info ReleaseInfo
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "commit":
+			info.Commit = value
+		case "subject":
+			info.Subject = value
+		case "author":
+			info.Author = value
+		case "deployed_by":
+			info.DeployedBy = value
+		case "config":
+			info.ConfigFingerprint = value
+		case "env":
+			info.EnvFingerprint = value
+		case "type":
+			info.Type = value
+		}
+	}
+	return info
+}
+
+// ReadReleaseInfos returns the metadata of every release on the server, by SHA.
+// Releases deployed before metadata was recorded are missing.
+func ReadReleaseInfos(executor *ssh.Executor, appName string) (map[string]ReleaseInfo, error) {
+	cmd := fmt.Sprintf(`for f in /var/www/%s/*/.mushak-release; do [ -f "$f" ] || continue; echo "release=$(basename "$(dirname "$f")")"; cat "$f"; done`, appName)
+	output, err := executor.Run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release metadata: %w", err)
+	}
+	return parseReleaseInfos(output), nil
+}
+
+// parseReleaseInfos splits the output of ReadReleaseInfos at its release= lines
+func parseReleaseInfos(output string) map[string]ReleaseInfo {
+	infos := make(map[string]ReleaseInfo)
+
+	sha := ""
+	var block []string
+	flush := func() {
+		if sha != "" {
+			infos[sha] = ParseReleaseInfo(strings.Join(block, "\n"))
+		}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		if name, ok := strings.CutPrefix(line, "release="); ok {
+			flush()
+			sha = name
+			block = nil
+			continue
+		}
+		block = append(block, line)
+	}
+	flush()
+
+	return infos
+}
+
+// ReleaseLog returns the commits reachable from to but not from, one per line,
+// from the app's bare repository. A monorepo app only gets the commits that
+// touch rootDir.
+func ReleaseLog(executor *ssh.Executor, appName, rootDir, from, to string) ([]string, error) {
+	cmd := fmt.Sprintf("git --git-dir=/var/repo/%s.git log --format='%%h %%s (%%an)' %s..%s", appName, shellQuote(from), shellQuote(to))
+	if rootDir != "" {
+		cmd += " -- " + shellQuote(rootDir)
+	}
+	output, err := executor.Run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git log: %w", err)
+	}
+
+	var commits []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line != "" {
+			commits = append(commits, line)
+		}
+	}
+	return commits, nil
+}
+
+// ReadRepoFile returns a file's content at a commit of the app's bare
+// repository, or "" if the commit doesn't have it
+func ReadRepoFile(executor *ssh.Executor, appName, commit, path string) (string, error) {
+	cmd := fmt.Sprintf("git --git-dir=/var/repo/%s.git show %s 2>/dev/null || true", appName, shellQuote(commit+":"+path))
+	output, err := executor.Run(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return output, nil
+}
+
+// ReadReleaseFile returns a file from a release's directory, or "" if it doesn't exist
+func ReadReleaseFile(executor *ssh.Executor, appName, sha, path string) (string, error) {
+	output, err := executor.Run(fmt.Sprintf("cat /var/www/%s/%s/%s 2>/dev/null || true", appName, sha, path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return output, nil
+}
+
+// ReadReleaseEnvFiles returns the env files a release was started with, by
+// path relative to the release directory: .env and .env.d/<service>.env
+func ReadReleaseEnvFiles(executor *ssh.Executor, appName, sha string) (map[string]string, error) {
+	dir := fmt.Sprintf("/var/www/%s/%s", appName, sha)
+
+	listing, err := executor.Run(fmt.Sprintf("cd %s && ls .env .env.d/*.env 2>/dev/null || true", dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list env files: %w", err)
+	}
+
+	files := make(map[string]string)
+	for _, name := range strings.Split(strings.TrimSpace(listing), "\n") {
+		if name == "" {
+			continue
+		}
+		content, err := executor.Run(fmt.Sprintf("cat %s/%s", dir, shellQuote(name)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files[name] = content
+	}
+	return files, nil
+}
@@ -0,0 +1,32 @@
+package server
+
+import "testing"
+
+func TestParseReleaseInfos(t *testing.T) {
+	output := "release=abc1234\ncommit=abc1234def\nsubject=Fix login = redirect\nauthor=Jane <jane@example.com>\ndeployed_by=jane\nconfig=none\nenv=0123456789ab\n" +
+		"release=def5678\ncommit=def5678abc\nsubject=Add worker\ntype=static\n"
+
+	infos := parseReleaseInfos(output)
+	if len(infos) != 2 {
+		t.Fatalf("parseReleaseInfos() returned %d releases, want 2", len(infos))
+	}
+
+	want := ReleaseInfo{
+		Commit:            "abc1234def",
+		Subject:           "Fix login = redirect",
+		Author:            "Jane <jane@example.com>",
+		DeployedBy:        "jane",
+		ConfigFingerprint: "none",
+		EnvFingerprint:    "0123456789ab",
+	}
+	if got := infos["abc1234"]; got != want {
+		t.Errorf("infos[abc1234] = %+v, want %+v", got, want)
+	}
+
+	if got := infos["def5678"].Subject; got != "Add worker" {
+		t.Errorf("infos[def5678].Subject = %q", got)
+	}
+	if got := infos["def5678"].Type; got != "static" {
+		t.Errorf("infos[def5678].Type = %q, want static", got)
+	}
+}
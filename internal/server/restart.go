@@ -0,0 +1,260 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/hooks"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+)
+
+// RestartWithEnv applies changed environment variables without rebuilding.
+// Fresh containers are started from the current release's images with the
+// server's env files, health-checked and switched to like a deploy. Falls back
+// to a full redeploy if the current release has no tagged image.
+func RestartWithEnv(executor *ssh.Executor, cfg *config.DeployConfig) error {
+	sha, err := getCurrentSHA(executor, cfg.AppName)
+	if err != nil {
+		ui.PrintWarning("No running release found, running a full redeploy")
+		return TriggerRedeploy(executor, cfg)
+	}
+
+	// Static sites bake the environment into their files at build time
+	if isStaticRelease(executor, cfg.AppName, sha) {
+		ui.PrintInfo("Static sites are rebuilt to apply environment changes, running a full redeploy")
+		return TriggerRedeploy(executor, cfg)
+	}
+
+	imageID, err := executor.Run(fmt.Sprintf("docker images mushak-%s:%s -q", cfg.AppName, sha))
+	if err != nil || strings.TrimSpace(imageID) == "" {
+		ui.PrintWarning(fmt.Sprintf("No tagged image for %s, running a full redeploy", sha))
+		return TriggerRedeploy(executor, cfg)
+	}
+
+	dirExists, _ := executor.Run(fmt.Sprintf("test -d /var/www/%s/%s && echo 'exists'", cfg.AppName, sha))
+	if strings.TrimSpace(dirExists) != "exists" {
+		ui.PrintWarning(fmt.Sprintf("Release directory for %s not found, running a full redeploy", sha))
+		return TriggerRedeploy(executor, cfg)
+	}
+
+	restartScript := generateRestartScript(cfg.AppName, cfg.Domain, sha)
+
+	fmt.Println("----------------------------------------")
+	if err := executor.StreamRun(restartScript, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("restart failed: %w", err)
+	}
+	fmt.Println("----------------------------------------")
+
+	return nil
+}
+
+// generateRestartScript generates a bash script that restarts the current release with fresh env files
+func generateRestartScript(appName, domain, sha string) string {
+	return fmt.Sprintf(`#!/bin/bash
+set -e
+
+APP_NAME="%s"
+DOMAIN="%s"
+TARGET_SHA="%s"
+DEPLOY_DIR="/var/www/$APP_NAME/$TARGET_SHA"
+# A project of its own lets the new containers run next to the current ones
+PROJECT_NAME="mushak-$APP_NAME-$TARGET_SHA-r$(date +%%s)"
+IMAGE_REPO="mushak-$APP_NAME"
+NETWORK_NAME="mushak-${APP_NAME}-net"
+
+%s
+%s
+echo "========================================="
+echo "Mushak Env Restart Started"
+echo "========================================="
+echo "App: $APP_NAME"
+echo "Release: $TARGET_SHA"
+
+echo ""
+echo "→ Finding available port..."
+
+HOST_PORT=$(find_free_port)
+echo "  Using port: $HOST_PORT"
+
+cd "$DEPLOY_DIR"
+
+# Ensure network exists
+docker network create $NETWORK_NAME 2>/dev/null || true
+
+echo ""
+%s
+
+# The release keeps its env files until the new containers are healthy. The
+# new files are needed to start them, so the release's own are set aside and
+# put back if the restart fails at any point
+ENV_BACKUP=$(mktemp -d)
+for env_path in .env .env.prod .env.d; do
+    if [ -e "$env_path" ]; then
+        cp -a "$env_path" "$ENV_BACKUP/"
+    fi
+done
+restore_release_env() {
+    rm -rf "$DEPLOY_DIR/.env" "$DEPLOY_DIR/.env.prod" "$DEPLOY_DIR/.env.d"
+    cp -a "$ENV_BACKUP/." "$DEPLOY_DIR/"
+    rm -rf "$ENV_BACKUP"
+    echo "  Kept the release's previous environment files"
+}
+trap 'restore_release_env; remove_decrypted_secrets' EXIT
+
+%s
+
+%s
+
+# Read settings from mushak.yaml if exists
+INTERNAL_PORT=80
+HEALTH_PATH="/"
+HEALTH_TIMEOUT=30
+DRAIN_SECONDS=10
+STOP_GRACE_PERIOD=10
+
+if [ -f "mushak.yaml" ]; then
+    if grep -q "internal_port:" mushak.yaml; then
+        INTERNAL_PORT=$(grep "internal_port:" mushak.yaml | awk '{print $2}')
+    fi
+    if grep -q "health_path:" mushak.yaml; then
+        HEALTH_PATH=$(grep "health_path:" mushak.yaml | awk '{print $2}')
+    fi
+    if grep -q "health_timeout:" mushak.yaml; then
+        HEALTH_TIMEOUT=$(grep "health_timeout:" mushak.yaml | awk '{print $2}')
+    fi
+    if grep -q "drain_seconds:" mushak.yaml; then
+        DRAIN_SECONDS=$(grep "drain_seconds:" mushak.yaml | awk '{print $2}')
+    fi
+    if grep -q "stop_grace_period:" mushak.yaml; then
+        STOP_GRACE_PERIOD=$(grep "stop_grace_period:" mushak.yaml | awk '{print $2}')
+    fi
+fi
+
+# The release that currently receives traffic
+OLD_PORT=$(grep -oE "localhost:[0-9]+" /etc/caddy/apps/$APP_NAME.caddy 2>/dev/null | head -1 | cut -d: -f2)
+
+echo ""
+echo "→ Starting containers from existing images..."
+
+if [ -f "docker-compose.yml" ] || [ -f "docker-compose.yaml" ]; then
+    BUILD_METHOD="compose"
+    COMPOSE_FILE="docker-compose.yml"
+    [ -f "docker-compose.yaml" ] && COMPOSE_FILE="docker-compose.yaml"
+
+    # Get the web service name
+    SERVICE_NAME=$(grep -E "^  [a-zA-Z0-9_-]*web[a-zA-Z0-9_-]*:" $COMPOSE_FILE | head -1 | sed 's/://g' | tr -d ' ')
+    if [ -z "$SERVICE_NAME" ]; then
+        SERVICE_NAME=$(grep -E "^  [a-zA-Z0-9_-]+:" $COMPOSE_FILE | head -1 | sed 's/://g' | tr -d ' ')
+    fi
+    CONTAINER_NAME="${PROJECT_NAME}-${SERVICE_NAME}"
+
+    # Restart every application service of the running release with the image it runs
+    CURRENT_PROJECT=""
+    if [ -n "$OLD_PORT" ]; then
+        CURRENT_PROJECT=$(docker ps --filter "publish=$OLD_PORT" --format '{{.Label "com.docker.compose.project"}}' | head -1)
+    fi
+    if [ -z "$CURRENT_PROJECT" ]; then
+        echo "ERROR: Could not find the running release. Run 'mushak redeploy' instead" >&2
+        exit 1
+    fi
+
+    INFRA_SERVICES=$(docker ps --format "{{.Names}}" | grep "^${APP_NAME}_" | sed "s/^${APP_NAME}_//" || true)
+
+    compose_override_start
+
+    APP_SERVICES=""
+    while read app_svc image; do
+        [ -z "$app_svc" ] && continue
+        APP_SERVICES="$APP_SERVICES $app_svc"
+
+        # The web service runs the image tagged for rollback
+        if [ "$app_svc" = "$SERVICE_NAME" ]; then
+            image="${IMAGE_REPO}:${TARGET_SHA}"
+        fi
+
+        compose_override_service "$app_svc" "$image"
+        check_service_env_schema "$app_svc" || exit 1
+        compose_override_runtime "$app_svc"
+    done < <(docker ps --filter "label=com.docker.compose.project=$CURRENT_PROJECT" --format '{{.Label "com.docker.compose.service"}} {{.Image}}')
+
+    echo "  Application services:$APP_SERVICES"
+    docker compose -p $PROJECT_NAME up -d --no-build --no-deps $APP_SERVICES
+else
+    BUILD_METHOD="dockerfile"
+    CONTAINER_NAME="$PROJECT_NAME"
+
+    ENV_OPTS=""
+    if [ -f ".env" ]; then
+        ENV_OPTS="--env-file .env"
+    fi
+    if [ -n "$SECRETS_ENV" ]; then
+        ENV_OPTS="$ENV_OPTS --env-file $SECRETS_ENV"
+    fi
+
+    docker run -d --name "$CONTAINER_NAME" --network "$NETWORK_NAME" $ENV_OPTS -p $HOST_PORT:$INTERNAL_PORT "${IMAGE_REPO}:${TARGET_SHA}"
+fi
+
+echo "  Container started: $CONTAINER_NAME"
+
+echo ""
+echo "→ Waiting for service to be healthy..."
+
+RETRY_COUNT=0
+until curl -sf http://localhost:$HOST_PORT$HEALTH_PATH > /dev/null 2>&1; do
+    RETRY_COUNT=$((RETRY_COUNT + 1))
+
+    if [ $RETRY_COUNT -ge $HEALTH_TIMEOUT ]; then
+        echo ""
+        echo "ERROR: Health check failed after $HEALTH_TIMEOUT seconds" >&2
+        echo "Keeping the running release..."
+
+        if [ "$BUILD_METHOD" = "compose" ]; then
+            docker compose -p $PROJECT_NAME down 2>/dev/null || true
+        else
+            docker stop "$CONTAINER_NAME" 2>/dev/null || true
+            docker rm "$CONTAINER_NAME" 2>/dev/null || true
+        fi
+
+        exit 1
+    fi
+
+    echo -n "."
+    sleep 1
+done
+
+echo ""
+echo "  Service is healthy!"
+
+# The new environment is in use, so it becomes the release's
+trap remove_decrypted_secrets EXIT
+rm -rf "$ENV_BACKUP"
+
+echo ""
+echo "→ Updating Caddy configuration..."
+
+%s
+%s
+
+echo ""
+echo "→ Stopping previous containers..."
+
+%s
+DEPLOYMENTS_FILE="/var/www/$APP_NAME/.deployments"
+echo "${TARGET_SHA} $(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ) ${HOST_PORT} restart" >> "$DEPLOYMENTS_FILE"
+
+echo ""
+echo "========================================="
+echo "✓ Env Restart Successful!"
+echo "========================================="
+echo "App: $APP_NAME"
+echo "SHA: $TARGET_SHA"
+echo "Port: $HOST_PORT"
+echo "URL: https://$DOMAIN"
+echo "========================================="
+`, appName, domain, sha, hooks.FindFreePortScript, hooks.ComposeOverrideScript,
+		hooks.DecryptSecretsScript, hooks.CopyEnvFilesScript, hooks.EnvSchemaScript,
+		hooks.CaddyProxyScript, hooks.DrainConnectionsScript, hooks.StopPreviousContainersScript)
+}
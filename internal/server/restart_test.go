@@ -0,0 +1,63 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateRestartScript(t *testing.T) {
+	script := generateRestartScript("myapp", "example.com", "abc1234")
+
+	for _, element := range []string{
+		// New containers get their own project next to the running ones
+		`PROJECT_NAME="mushak-$APP_NAME-$TARGET_SHA-r$(date +%s)"`,
+		// Fresh env files, secrets and schema validation
+		`cp "/var/www/$APP_NAME/.env.prod" .env`,
+		"cp /var/www/$APP_NAME/.env.d/*.env .env.d/",
+		"openssl enc -d -aes-256-cbc",
+		`if [ -f "/var/www/$APP_NAME/.env-schema" ]; then`,
+		// Existing images, no build
+		`docker compose -p $PROJECT_NAME up -d --no-build --no-deps $APP_SERVICES`,
+		`image="${IMAGE_REPO}:${TARGET_SHA}"`,
+		`"${IMAGE_REPO}:${TARGET_SHA}"`,
+		`curl -sf http://localhost:$HOST_PORT$HEALTH_PATH`,
+		"reverse_proxy localhost:$HOST_PORT",
+		`if [ "$project" = "$PROJECT_NAME" ] || [ "$container" = "$PROJECT_NAME" ]; then`,
+		// The release's env files are put back unless the restart succeeds
+		`cp -a "$ENV_BACKUP/." "$DEPLOY_DIR/"`,
+	} {
+		if !strings.Contains(script, element) {
+			t.Errorf("restart script missing %q", element)
+		}
+	}
+
+	if strings.Contains(script, "--build") || strings.Contains(script, "docker build") {
+		t.Error("restart script should not build images")
+	}
+
+	// The new env files only replace the release's once the health check passed
+	// Decrypting sets the EXIT trap that deletes the plaintext secrets, so it
+	// must run before the trap that also restores the env files
+	envOrder := []string{"trap remove_decrypted_secrets EXIT", "trap 'restore_release_env; remove_decrypted_secrets' EXIT",
+		`cp "/var/www/$APP_NAME/.env.prod" .env`, "Service is healthy", "Updating Caddy configuration"}
+	for _, element := range envOrder {
+		if !strings.Contains(script, element) {
+			t.Errorf("restart script missing %q", element)
+		}
+	}
+	if !strings.Contains(script, "Service is healthy!\"\n\n# The new environment is in use, so it becomes the release's\ntrap remove_decrypted_secrets EXIT\n") {
+		t.Error("restart script should stop restoring the env files once the service is healthy, but keep deleting the secrets")
+	}
+	for i := 1; i < len(envOrder); i++ {
+		if strings.Index(script, envOrder[i-1]) > strings.Index(script, envOrder[i]) {
+			t.Errorf("%q should come before %q", envOrder[i-1], envOrder[i])
+		}
+	}
+
+	order := []string{"Waiting for service to be healthy", "Updating Caddy configuration", "Draining connections", "Stopping previous containers"}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(script, order[i-1]) > strings.Index(script, order[i]) {
+			t.Errorf("%q should come before %q", order[i-1], order[i])
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/ssh"
+)
+
+// RetentionPath returns where the retention settings are stored on the server.
+// The release cleanup shared by the hook and rollback reads them.
+func RetentionPath(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.retention", appName)
+}
+
+// PinnedReleasesPath returns the file listing releases exempt from cleanup, one SHA per line
+func PinnedReleasesPath(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.pinned", appName)
+}
+
+// GenerateRetentionFile renders the retention settings for the cleanup script
+func GenerateRetentionFile(r config.RetentionConfig) (string, error) {
+	if err := r.Check(); err != nil {
+		return "", err
+	}
+
+	minAge, _ := r.MinAgeDuration()
+	return fmt.Sprintf("releases=%d\nmin_age=%d", r.KeepReleases(), int64(minAge.Seconds())), nil
+}
+
+// SyncRetention uploads the retention settings from mushak.yaml
+func SyncRetention(executor *ssh.Executor, appName string, r config.RetentionConfig) error {
+	content, err := GenerateRetentionFile(r)
+	if err != nil {
+		return fmt.Errorf("invalid mushak.yaml: %w", err)
+	}
+
+	if err := executor.WriteFile(RetentionPath(appName), content); err != nil {
+		return fmt.Errorf("failed to upload retention settings: %w", err)
+	}
+	return nil
+}
+
+// PinnedReleases returns the SHAs of pinned releases
+func PinnedReleases(executor *ssh.Executor, appName string) (map[string]bool, error) {
+	output, err := executor.Run(fmt.Sprintf("cat %s 2>/dev/null || true", PinnedReleasesPath(appName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pinned releases: %w", err)
+	}
+
+	pinned := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if sha := strings.TrimSpace(line); sha != "" {
+			pinned[sha] = true
+		}
+	}
+	return pinned, nil
+}
+
+// PinRelease exempts a release from cleanup
+func PinRelease(executor *ssh.Executor, appName, sha string) error {
+	path := PinnedReleasesPath(appName)
+	cmd := fmt.Sprintf("grep -qx '%s' %s 2>/dev/null || echo '%s' >> %s", sha, path, sha, path)
+	if _, err := executor.Run(cmd); err != nil {
+		return fmt.Errorf("failed to pin release: %w", err)
+	}
+	return nil
+}
+
+// UnpinRelease makes a pinned release subject to cleanup again
+func UnpinRelease(executor *ssh.Executor, appName, sha string) error {
+	path := PinnedReleasesPath(appName)
+	cmd := fmt.Sprintf("if [ -f %s ]; then grep -vx '%s' %s > %s.tmp || true; mv %s.tmp %s; fi", path, sha, path, path, path, path)
+	if _, err := executor.Run(cmd); err != nil {
+		return fmt.Errorf("failed to unpin release: %w", err)
+	}
+	return nil
+}
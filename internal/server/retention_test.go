@@ -0,0 +1,32 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/hmontazeri/mushak/internal/config"
+)
+
+func TestGenerateRetentionFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention config.RetentionConfig
+		want      string
+		wantErr   bool
+	}{
+		{name: "defaults", want: "releases=3\nmin_age=0"},
+		{name: "configured", retention: config.RetentionConfig{Releases: 10, MinAge: "7d"}, want: "releases=10\nmin_age=604800"},
+		{name: "invalid min_age", retention: config.RetentionConfig{MinAge: "soon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateRetentionFile(tt.retention)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateRetentionFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateRetentionFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,12 @@
 package server
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/hooks"
 	"github.com/hmontazeri/mushak/internal/ssh"
 	"github.com/hmontazeri/mushak/internal/ui"
 )
@@ -20,6 +20,8 @@ type DeploymentVersion struct {
 	HasImage   bool
 	HasDir     bool
 	IsCurrent  bool
+	IsPinned   bool
+	Info       ReleaseInfo
 }
 
 // ListVersions lists available versions for rollback
@@ -54,34 +56,30 @@ func ListVersions(executor *ssh.Executor, appName string) ([]DeploymentVersion,
 		}
 	}
 
-	// Parse manifest and build version list
-	var versions []DeploymentVersion
-	seenSHAs := make(map[string]bool)
-
-	scanner := bufio.NewScanner(strings.NewReader(manifest))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
+	// Pinned releases are exempt from cleanup
+	pinned, err := PinnedReleases(executor, appName)
+	if err != nil {
+		return nil, err
+	}
 
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			continue
-		}
+	// Commit details recorded by the hook
+	infos, err := ReadReleaseInfos(executor, appName)
+	if err != nil {
+		return nil, err
+	}
 
+	// Build the version list from the manifest, newest first
+	var versions []DeploymentVersion
+	for _, parts := range latestDeployments(manifest) {
 		sha := parts[0]
-		if seenSHAs[sha] {
-			continue // Skip duplicates, keep latest entry
-		}
-		seenSHAs[sha] = true
-
 		version := DeploymentVersion{
 			SHA:       sha,
 			Timestamp: parts[1],
 			HasImage:  availableImages[sha],
 			HasDir:    availableDirs[sha],
 			IsCurrent: sha == currentSHA,
+			IsPinned:  pinned[sha],
+			Info:      infos[sha],
 		}
 
 		if len(parts) >= 3 {
@@ -91,24 +89,40 @@ func ListVersions(executor *ssh.Executor, appName string) ([]DeploymentVersion,
 			version.Method = parts[3]
 		}
 
-		// Only include versions that have an image (can be rolled back to)
-		if version.HasImage {
+		// Only include versions that have an image, or the files of a static
+		// site (can be rolled back to)
+		if version.HasImage || (version.Info.Type == "static" && version.HasDir) {
 			versions = append(versions, version)
 		}
 	}
 
-	// Reverse to show newest first
-	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
-		versions[i], versions[j] = versions[j], versions[i]
-	}
-
 	return versions, nil
 }
 
+// latestDeployments returns the fields of the deployment manifest's entries,
+// newest first. Rollbacks and env restarts append a release again, so each
+// release is listed once, at its latest entry: the order releases were live in.
+func latestDeployments(manifest string) [][]string {
+	var entries [][]string
+	seenSHAs := make(map[string]bool)
+
+	lines := strings.Split(manifest, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		parts := strings.Fields(lines[i])
+		if len(parts) < 2 || seenSHAs[parts[0]] {
+			continue
+		}
+		seenSHAs[parts[0]] = true
+		entries = append(entries, parts)
+	}
+	return entries
+}
+
 // getCurrentSHA gets the SHA of the currently deployed version
 func getCurrentSHA(executor *ssh.Executor, appName string) (string, error) {
-	// Try to get from running container
-	cmd := fmt.Sprintf("docker ps --filter 'name=mushak-%s-' --format '{{.Names}}' | head -1 | sed 's/mushak-%s-//' | cut -d'-' -f1", appName, appName)
+	// Try to get from running container. Releases of uncommitted work and
+	// images are named dirty-<hash> and image-<id>
+	cmd := fmt.Sprintf("docker ps --filter 'name=mushak-%s-' --format '{{.Names}}' | head -1 | sed 's/mushak-%s-//' | sed -E 's/^((dirty-|image-)?[^-]+).*/\\1/'", appName, appName)
 	sha, err := executor.Run(cmd)
 	if err == nil && strings.TrimSpace(sha) != "" {
 		return strings.TrimSpace(sha), nil
@@ -131,6 +145,11 @@ func ExecuteRollback(executor *ssh.Executor, cfg *config.DeployConfig, targetSHA
 
 	ui.PrintInfo(fmt.Sprintf("Rolling back to version %s...", targetSHA))
 
+	// Static sites have no image, only their files
+	if isStaticRelease(executor, appName, targetSHA) {
+		return rollbackStatic(executor, cfg, targetSHA)
+	}
+
 	// Verify the target image exists
 	checkCmd := fmt.Sprintf("docker images mushak-%s:%s -q", appName, targetSHA)
 	imageID, err := executor.Run(checkCmd)
@@ -173,6 +192,8 @@ PROJECT_NAME="mushak-$APP_NAME-$TARGET_SHA"
 IMAGE_REPO="mushak-$APP_NAME"
 NETWORK_NAME="mushak-${APP_NAME}-net"
 
+%s
+%s
 echo "========================================="
 echo "Mushak Rollback Started"
 echo "========================================="
@@ -182,18 +203,6 @@ echo "Target: $TARGET_SHA"
 echo ""
 echo "→ Finding available port..."
 
-# Find a free port (8000-9000)
-find_free_port() {
-    for port in {8000..9000}; do
-        if ! ss -tuln | grep -q ":$port "; then
-            echo $port
-            return 0
-        fi
-    done
-    echo "ERROR: No free ports available in range 8000-9000" >&2
-    exit 1
-}
-
 HOST_PORT=$(find_free_port)
 echo "  Using port: $HOST_PORT"
 
@@ -230,6 +239,8 @@ fi
 INTERNAL_PORT=80
 HEALTH_PATH="/"
 HEALTH_TIMEOUT=30
+DRAIN_SECONDS=10
+STOP_GRACE_PERIOD=10
 
 if [ -f "mushak.yaml" ]; then
     if grep -q "internal_port:" mushak.yaml; then
@@ -241,39 +252,66 @@ if [ -f "mushak.yaml" ]; then
     if grep -q "health_timeout:" mushak.yaml; then
         HEALTH_TIMEOUT=$(grep "health_timeout:" mushak.yaml | awk '{print $2}')
     fi
+    if grep -q "drain_seconds:" mushak.yaml; then
+        DRAIN_SECONDS=$(grep "drain_seconds:" mushak.yaml | awk '{print $2}')
+    fi
+    if grep -q "stop_grace_period:" mushak.yaml; then
+        STOP_GRACE_PERIOD=$(grep "stop_grace_period:" mushak.yaml | awk '{print $2}')
+    fi
 fi
 
-echo ""
-echo "→ Starting container from cached image..."
+%s
 
-# Stop any existing container with the same name
-docker stop "$CONTAINER_NAME" 2>/dev/null || true
-docker rm "$CONTAINER_NAME" 2>/dev/null || true
+echo ""
+echo "→ Starting containers from cached images..."
 
 if [ "$BUILD_METHOD" = "compose" ]; then
-    # For compose, we need to recreate the override file with the new port
-    cat > docker-compose.override.yml <<EOF
-version: '3'
-networks:
-  default:
-    external: true
-    name: $NETWORK_NAME
-services:
-  $SERVICE_NAME:
-    image: ${IMAGE_REPO}:${TARGET_SHA}
-    container_name: ${CONTAINER_NAME}
-    ports:
-      - "$HOST_PORT:$INTERNAL_PORT"
-EOF
-
-    # Start the service using the pre-built image
-    docker compose -p $PROJECT_NAME up -d --no-build $SERVICE_NAME
+    # Every application service of the release was tagged when it was deployed:
+    # the web service as SHA, the others as SHA-service
+    APP_SERVICES="$SERVICE_NAME"
+    for tag in $(docker images "$IMAGE_REPO" --format '{{.Tag}}' | grep "^${TARGET_SHA}-" || true); do
+        APP_SERVICES="$APP_SERVICES ${tag#${TARGET_SHA}-}"
+    done
+
+    # Infrastructure services keep running under their static names
+    INFRA_SERVICES=$(docker ps --format "{{.Names}}" | grep "^${APP_NAME}_" | sed "s/^${APP_NAME}_//" || true)
+
+    # Recreate the override file with the new port and the tagged images
+    compose_override_start
+
+    for app_svc in $APP_SERVICES; do
+        if [ "$app_svc" = "$SERVICE_NAME" ]; then
+            image="${IMAGE_REPO}:${TARGET_SHA}"
+        else
+            image="${IMAGE_REPO}:${TARGET_SHA}-${app_svc}"
+        fi
+
+        # Stop any existing container with the same name
+        docker stop "${PROJECT_NAME}-${app_svc}" 2>/dev/null || true
+        docker rm "${PROJECT_NAME}-${app_svc}" 2>/dev/null || true
+
+        # Per-service variables were copied into the release when it was deployed
+        compose_override_service "$app_svc" "$image"
+        compose_override_runtime "$app_svc"
+    done
+
+    echo "  Application services: $APP_SERVICES"
+
+    # Start the services using the pre-built images, leaving infrastructure alone
+    docker compose -p $PROJECT_NAME up -d --no-build --no-deps $APP_SERVICES
 else
+    # Stop any existing container with the same name
+    docker stop "$CONTAINER_NAME" 2>/dev/null || true
+    docker rm "$CONTAINER_NAME" 2>/dev/null || true
+
     # For Dockerfile deployments, run the tagged image
     ENV_OPTS=""
     if [ -f ".env" ]; then
         ENV_OPTS="--env-file .env"
     fi
+    if [ -n "$SECRETS_ENV" ]; then
+        ENV_OPTS="$ENV_OPTS --env-file $SECRETS_ENV"
+    fi
     
     docker run -d --name "$CONTAINER_NAME" --network "$NETWORK_NAME" $ENV_OPTS -p $HOST_PORT:$INTERNAL_PORT "${IMAGE_REPO}:${TARGET_SHA}"
 fi
@@ -293,9 +331,13 @@ until curl -sf http://localhost:$HOST_PORT$HEALTH_PATH > /dev/null 2>&1; do
         echo "ERROR: Health check failed after $HEALTH_TIMEOUT seconds" >&2
         echo "Aborting rollback..."
         
-        # Cleanup failed rollback container
-        docker stop "$CONTAINER_NAME" 2>/dev/null || true
-        docker rm "$CONTAINER_NAME" 2>/dev/null || true
+        # Cleanup failed rollback containers
+        if [ "$BUILD_METHOD" = "compose" ]; then
+            docker compose -p $PROJECT_NAME down 2>/dev/null || true
+        else
+            docker stop "$CONTAINER_NAME" 2>/dev/null || true
+            docker rm "$CONTAINER_NAME" 2>/dev/null || true
+        fi
         
         exit 1
     fi
@@ -310,35 +352,27 @@ echo "  Service is healthy!"
 echo ""
 echo "→ Updating Caddy configuration..."
 
-# Update Caddy config
-sudo tee /etc/caddy/apps/$APP_NAME.caddy > /dev/null <<EOF
-$DOMAIN {
-	reverse_proxy localhost:$HOST_PORT
-}
-EOF
-
-# Reload Caddy
-sudo systemctl reload caddy
-
-echo "  Caddy updated and reloaded"
+# Remember the previous release's port so its connections can be drained
+OLD_PORT=$(grep -oE "localhost:[0-9]+" /etc/caddy/apps/$APP_NAME.caddy 2>/dev/null | head -1 | cut -d: -f2)
 
+%s
 # Update current symlink
 ln -snf "$DEPLOY_DIR" "$CURRENT_LINK"
 
+%s
 echo ""
 echo "→ Stopping old containers..."
 
-# Stop old containers (exclude the rollback target)
-docker ps -a --format "{{.Names}}" | grep "^mushak-$APP_NAME-" | grep -v "$TARGET_SHA" | grep -v "_" | while read container; do
-    echo "  Stopping $container"
-    docker stop "$container" 2>/dev/null || true
-    docker rm "$container" 2>/dev/null || true
-done
-
+# Stop everything but the rollback target's project, including env restarts
+# of the target SHA, which run under projects of their own
+%s
 # Record rollback in deployment manifest
 DEPLOYMENTS_FILE="/var/www/$APP_NAME/.deployments"
 echo "${TARGET_SHA} $(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ) ${HOST_PORT} rollback" >> "$DEPLOYMENTS_FILE"
 
+echo ""
+%s
+
 echo ""
 echo "========================================="
 echo "✓ Rollback Successful!"
@@ -348,6 +382,7 @@ echo "SHA: $TARGET_SHA"
 echo "Port: $HOST_PORT"
 echo "URL: https://$DOMAIN"
 echo "========================================="
-`, appName, domain, targetSHA)
+`, appName, domain, targetSHA, hooks.FindFreePortScript, hooks.ComposeOverrideScript, hooks.DecryptSecretsScript,
+		hooks.CaddyProxyScript, hooks.DrainConnectionsScript, hooks.StopPreviousContainersScript, hooks.CleanupReleasesScript)
 }
 
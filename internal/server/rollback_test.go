@@ -0,0 +1,138 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateRollbackScript_ConnectionDraining(t *testing.T) {
+	script := generateRollbackScript("myapp", "example.com", "abc1234")
+
+	for _, element := range []string{
+		`grep "drain_seconds:" mushak.yaml`,
+		`ss -Htn state established "( dport = :$OLD_PORT )"`,
+		`docker stop -t $STOP_GRACE_PERIOD "$container"`,
+	} {
+		if !strings.Contains(script, element) {
+			t.Errorf("rollback script missing %q", element)
+		}
+	}
+
+	if strings.Index(script, "Draining connections") > strings.Index(script, "Stopping old containers") {
+		t.Error("rollback should drain connections before stopping old containers")
+	}
+}
+
+func TestGenerateRollbackScript_StopsRestartsOfTarget(t *testing.T) {
+	script := generateRollbackScript("myapp", "example.com", "abc1234")
+
+	// Env restarts of the target SHA run as mushak-<app>-<sha>-r<ts>, so old
+	// containers are matched by exact project, not by SHA
+	if !strings.Contains(script, `if [ "$project" = "$PROJECT_NAME" ] || [ "$container" = "$PROJECT_NAME" ]; then`) {
+		t.Error("rollback should keep only the target's own project")
+	}
+	if strings.Contains(script, `grep -v "$TARGET_SHA"`) {
+		t.Error("rollback should not keep every container whose name contains the target SHA")
+	}
+}
+
+func TestGenerateRollbackScript_Secrets(t *testing.T) {
+	script := generateRollbackScript("myapp", "example.com", "abc1234")
+
+	for _, element := range []string{
+		"openssl enc -d -aes-256-cbc -pbkdf2 -iter 100000 -md sha256 -a -pass file:/var/www/$APP_NAME/.secrets.key",
+		`echo "      - $SECRETS_ENV" >> docker-compose.override.yml`,
+		`ENV_OPTS="$ENV_OPTS --env-file $SECRETS_ENV"`,
+		// Decrypted again for the rollback and deleted when it ends
+		"trap remove_decrypted_secrets EXIT",
+	} {
+		if !strings.Contains(script, element) {
+			t.Errorf("rollback script missing %q", element)
+		}
+	}
+}
+
+func TestGenerateRollbackScript_ServiceEnvFiles(t *testing.T) {
+	script := generateRollbackScript("myapp", "example.com", "abc1234")
+
+	if !strings.Contains(script, `echo "      - .env.d/$app_svc.env" >> docker-compose.override.yml`) {
+		t.Error("rollback script should pass each service's env file")
+	}
+}
+
+func TestGenerateRollbackScript_ComposeStack(t *testing.T) {
+	script := generateRollbackScript("myapp", "example.com", "abc1234")
+
+	for _, element := range []string{
+		// Every application service tagged for the release comes back
+		`grep "^${TARGET_SHA}-"`,
+		`APP_SERVICES="$APP_SERVICES ${tag#${TARGET_SHA}-}"`,
+		`image="${IMAGE_REPO}:${TARGET_SHA}-${app_svc}"`,
+		`echo "      - .env.d/$app_svc.env" >> docker-compose.override.yml`,
+		`echo "      - ${APP_NAME}_${infra_svc}:${infra_svc}" >> docker-compose.override.yml`,
+		"    name: $NETWORK_NAME",
+		"docker compose -p $PROJECT_NAME up -d --no-build --no-deps $APP_SERVICES",
+		"docker compose -p $PROJECT_NAME down",
+	} {
+		if !strings.Contains(script, element) {
+			t.Errorf("rollback script missing %q", element)
+		}
+	}
+}
+
+func TestGenerateRollbackScript_ReleaseCleanup(t *testing.T) {
+	script := generateRollbackScript("myapp", "example.com", "abc1234")
+
+	if !strings.Contains(script, `RETENTION_FILE="$RELEASES_DIR/.retention"`) {
+		t.Error("rollback script should clean up old releases")
+	}
+
+	if strings.Index(script, "rollback\" >> \"$DEPLOYMENTS_FILE\"") > strings.Index(script, "Cleaning up old releases") {
+		t.Error("rollback should be recorded before cleaning up releases")
+	}
+}
+
+func TestGenerateStaticRollbackScript(t *testing.T) {
+	script := generateStaticRollbackScript("myapp", "example.com", "abc1234")
+
+	for _, element := range []string{
+		`DEPLOY_DIR="/var/www/$APP_NAME/$TARGET_SHA"`,
+		"root * $DEPLOY_DIR/public",
+		"\n}\nEOF\n",
+		`ln -snf "$DEPLOY_DIR" "$CURRENT_LINK"`,
+	} {
+		if !strings.Contains(script, element) {
+			t.Errorf("static rollback script missing %q", element)
+		}
+	}
+
+	if strings.Contains(script, "docker run") {
+		t.Error("static rollback should not start a container")
+	}
+
+	if strings.Index(script, "rollback\" >> \"$DEPLOYMENTS_FILE\"") > strings.Index(script, "Cleaning up old releases") {
+		t.Error("rollback should be recorded before cleaning up releases")
+	}
+}
+
+func TestLatestDeployments(t *testing.T) {
+	// A was rolled back to after B, so it was live before C
+	manifest := "aaa1111 2026-01-01T00:00:00Z 8000 compose\n" +
+		"bbb2222 2026-01-02T00:00:00Z 8001 compose\n" +
+		"aaa1111 2026-01-03T00:00:00Z 8002 compose\n" +
+		"\n" +
+		"ccc3333 2026-01-04T00:00:00Z 8003 compose\n"
+
+	entries := latestDeployments(manifest)
+	var shas []string
+	for _, parts := range entries {
+		shas = append(shas, parts[0])
+	}
+	if got := strings.Join(shas, " "); got != "ccc3333 aaa1111 bbb2222" {
+		t.Errorf("latestDeployments() order = %q, want %q", got, "ccc3333 aaa1111 bbb2222")
+	}
+	// Each release keeps the fields of its latest entry
+	if entries[1][1] != "2026-01-03T00:00:00Z" || entries[1][2] != "8002" {
+		t.Errorf("latestDeployments() entry for aaa1111 = %v, want its latest entry", entries[1])
+	}
+}
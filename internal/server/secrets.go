@@ -0,0 +1,36 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+)
+
+// SecretsKeyPath returns where the team key is stored on the server.
+// The deploy scripts read it to decrypt secrets.env.enc.
+func SecretsKeyPath(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.secrets.key", appName)
+}
+
+// SyncSecretsKey uploads the team key to the server if it is missing or different
+func SyncSecretsKey(executor *ssh.Executor, appName, key string) error {
+	path := SecretsKeyPath(appName)
+
+	if current, err := executor.Run(fmt.Sprintf("cat %s 2>/dev/null", path)); err == nil && strings.TrimSpace(current) == key {
+		return nil
+	}
+
+	// Restrict permissions before the key is written
+	if _, err := executor.Run(fmt.Sprintf("touch %s && chmod 600 %s", path, path)); err != nil {
+		return fmt.Errorf("failed to set secrets key permissions: %w", err)
+	}
+
+	if err := executor.WriteFile(path, key); err != nil {
+		return fmt.Errorf("failed to upload secrets key: %w", err)
+	}
+
+	ui.PrintSuccess("Secrets key synced to server")
+	return nil
+}
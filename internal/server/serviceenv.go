@@ -0,0 +1,23 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// serviceNamePattern matches valid docker compose service names
+var serviceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ServiceEnvPath returns the env file for a single compose service. The deploy
+// hook copies these files into the release and passes each one only to its service.
+func ServiceEnvPath(appName, service string) string {
+	return fmt.Sprintf("/var/www/%s/.env.d/%s.env", appName, service)
+}
+
+// ValidateServiceName checks that service is a valid compose service name
+func ValidateServiceName(service string) error {
+	if !serviceNamePattern.MatchString(service) {
+		return fmt.Errorf("invalid service name: %s", service)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package server
+
+import "testing"
+
+func TestServiceEnvPath(t *testing.T) {
+	if got := ServiceEnvPath("myapp", "worker"); got != "/var/www/myapp/.env.d/worker.env" {
+		t.Errorf("ServiceEnvPath() = %s", got)
+	}
+}
+
+func TestValidateServiceName(t *testing.T) {
+	for _, name := range []string{"web", "worker_1", "api.v2", "db-replica"} {
+		if err := ValidateServiceName(name); err != nil {
+			t.Errorf("ValidateServiceName(%q) error = %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", "../web", "a/b", "-web", "web env", "$(id)"} {
+		if err := ValidateServiceName(name); err == nil {
+			t.Errorf("ValidateServiceName(%q) should fail", name)
+		}
+	}
+}
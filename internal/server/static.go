@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/hooks"
+	"github.com/hmontazeri/mushak/internal/ssh"
+)
+
+// StaticSettingsPath returns where the static site settings are stored on the
+// server. The post-receive hook deploys apps that have them as static sites.
+func StaticSettingsPath(appName string) string {
+	return fmt.Sprintf("/var/www/%s/.static", appName)
+}
+
+// GenerateStaticSettingsFile renders the static site settings for the hook,
+// one key=value per line, or "" for container apps
+func GenerateStaticSettingsFile(cfg *config.AppConfig) (string, error) {
+	if cfg == nil {
+		return "", nil
+	}
+	if err := cfg.CheckType(); err != nil {
+		return "", err
+	}
+	if !cfg.IsStatic() {
+		return "", nil
+	}
+
+	lines := []string{
+		"image=" + cfg.Static.BuildImage(),
+		"output=" + cfg.Static.OutputDir(),
+	}
+	if cfg.Static.Build != "" {
+		lines = append(lines, "build="+cfg.Static.Build)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// SyncStaticSettings uploads the static site settings from mushak.yaml, or
+// removes them for container apps
+func SyncStaticSettings(executor *ssh.Executor, appName string, cfg *config.AppConfig) error {
+	path := StaticSettingsPath(appName)
+
+	content, err := GenerateStaticSettingsFile(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid mushak.yaml: %w", err)
+	}
+
+	if content == "" {
+		if _, err := executor.Run(fmt.Sprintf("rm -f %s", path)); err != nil {
+			return fmt.Errorf("failed to remove static site settings: %w", err)
+		}
+		return nil
+	}
+
+	if err := executor.WriteFile(path, content); err != nil {
+		return fmt.Errorf("failed to upload static site settings: %w", err)
+	}
+	return nil
+}
+
+// isStaticRelease reports whether the hook deployed release sha as a static site
+func isStaticRelease(executor *ssh.Executor, appName, sha string) bool {
+	out, _ := executor.Run(fmt.Sprintf("grep -qx type=static %s 2>/dev/null && echo static || true", ReleaseInfoPath(appName, sha)))
+	return strings.TrimSpace(out) == "static"
+}
+
+// rollbackStatic switches Caddy back to the files of a static release
+func rollbackStatic(executor *ssh.Executor, cfg *config.DeployConfig, targetSHA string) error {
+	publicDir := fmt.Sprintf("/var/www/%s/%s/public", cfg.AppName, targetSHA)
+	dirExists, _ := executor.Run(fmt.Sprintf("test -d %s && echo 'exists'", publicDir))
+	if strings.TrimSpace(dirExists) != "exists" {
+		return fmt.Errorf("files not found for static release %s. Cannot rollback", targetSHA)
+	}
+
+	fmt.Println("----------------------------------------")
+	if err := executor.StreamRun(generateStaticRollbackScript(cfg.AppName, cfg.Domain, targetSHA), os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+	fmt.Println("----------------------------------------")
+
+	return nil
+}
+
+// generateStaticRollbackScript generates a bash script that serves the files
+// of a static release again
+func generateStaticRollbackScript(appName, domain, targetSHA string) string {
+	return fmt.Sprintf(`#!/bin/bash
+set -e
+
+APP_NAME="%s"
+DOMAIN="%s"
+TARGET_SHA="%s"
+DEPLOY_DIR="/var/www/$APP_NAME/$TARGET_SHA"
+CURRENT_LINK="/var/www/$APP_NAME/current"
+
+echo "========================================="
+echo "Mushak Rollback Started"
+echo "========================================="
+echo "App: $APP_NAME"
+echo "Target: $TARGET_SHA (static)"
+
+echo ""
+echo "→ Updating Caddy configuration..."
+
+%s
+
+# Update current symlink
+ln -snf "$DEPLOY_DIR" "$CURRENT_LINK"
+
+# Record rollback in deployment manifest
+DEPLOYMENTS_FILE="/var/www/$APP_NAME/.deployments"
+echo "${TARGET_SHA} $(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ) - rollback" >> "$DEPLOYMENTS_FILE"
+
+echo ""
+%s
+
+echo ""
+echo "========================================="
+echo "✓ Rollback Successful!"
+echo "========================================="
+echo "App: $APP_NAME"
+echo "SHA: $TARGET_SHA"
+echo "URL: https://$DOMAIN"
+echo "========================================="
+`, appName, domain, targetSHA, hooks.StaticCaddyScript, hooks.CleanupReleasesScript)
+}
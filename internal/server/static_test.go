@@ -0,0 +1,42 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/hmontazeri/mushak/internal/config"
+)
+
+func TestGenerateStaticSettingsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *config.AppConfig
+		want    string
+		wantErr bool
+	}{
+		{name: "no config", want: ""},
+		{name: "container app", cfg: &config.AppConfig{}, want: ""},
+		{name: "plain files", cfg: &config.AppConfig{Type: config.AppTypeStatic, Static: config.StaticConfig{Output: "public"}}, want: "image=node:lts-alpine\noutput=public"},
+		{name: "repository root", cfg: &config.AppConfig{Type: config.AppTypeStatic}, wantErr: true},
+		{
+			name: "with build",
+			cfg: &config.AppConfig{Type: config.AppTypeStatic, Static: config.StaticConfig{
+				Build: "npm ci && npm run build",
+				Image: "node:20-alpine",
+			}},
+			want: "image=node:20-alpine\noutput=dist\nbuild=npm ci && npm run build",
+		},
+		{name: "invalid", cfg: &config.AppConfig{Type: config.AppTypeStatic, Static: config.StaticConfig{Output: "../site"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateStaticSettingsFile(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateStaticSettingsFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateStaticSettingsFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+)
+
+// TLS modes supported in mushak.yaml and .mushak/mushak.yaml
+const (
+	TLSModeAuto     = "auto"
+	TLSModeInternal = "internal"
+	TLSModeCustom   = "custom"
+	TLSModeDNS      = "dns"
+)
+
+// TLSStatus describes the certificate Caddy is serving for a domain
+type TLSStatus struct {
+	Domain   string
+	Issuer   string
+	NotAfter string
+	Errors   []string
+}
+
+func certDir(appName string) string {
+	return fmt.Sprintf("/etc/caddy/certs/%s", appName)
+}
+
+func dnsCredentialsPath(appName string) string {
+	return fmt.Sprintf("/etc/caddy/dns/%s.env", appName)
+}
+
+func caddyDropInPath(appName string) string {
+	return fmt.Sprintf("/etc/systemd/system/caddy.service.d/mushak-%s.conf", appName)
+}
+
+// dnsCredentialPrefix returns the prefix of the app's DNS credential variables.
+// Every app's credentials are loaded into the one Caddy process, so each app
+// gets variable names of its own.
+func dnsCredentialPrefix(appName string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, appName)
+	return "MUSHAK_" + strings.ToUpper(name) + "_"
+}
+
+// GenerateTLSDirective renders the Caddy tls directive for the given mode.
+// An empty string means Caddy's automatic HTTPS is used.
+func GenerateTLSDirective(appName, mode, dnsProvider string, credentialKeys []string) (string, error) {
+	switch mode {
+	case "", TLSModeAuto:
+		return "", nil
+	case TLSModeInternal:
+		return "tls internal\n", nil
+	case TLSModeCustom:
+		return fmt.Sprintf("tls %s/cert.pem %s/key.pem\n", certDir(appName), certDir(appName)), nil
+	case TLSModeDNS:
+		if dnsProvider == "" {
+			return "", fmt.Errorf("tls mode 'dns' requires a dns_provider")
+		}
+		// A single credential is passed positionally (e.g. cloudflare's API token),
+		// several as provider options named after the credential (e.g. route53's
+		// access_key_id). Credentials stored before they had per-app names are
+		// read by the provider from Caddy's environment.
+		prefix := dnsCredentialPrefix(appName)
+		dns := "dns " + dnsProvider
+		if len(credentialKeys) == 1 {
+			dns += fmt.Sprintf(" {env.%s}", credentialKeys[0])
+		} else if len(credentialKeys) > 1 && strings.HasPrefix(credentialKeys[0], prefix) {
+			dns += " {\n"
+			for _, key := range credentialKeys {
+				dns += fmt.Sprintf("\t\t%s {env.%s}\n", strings.ToLower(strings.TrimPrefix(key, prefix)), key)
+			}
+			dns += "\t}"
+		}
+		return fmt.Sprintf("tls {\n\t%s\n}\n", dns), nil
+	default:
+		return "", fmt.Errorf("unknown tls mode %q (expected auto, internal, custom or dns)", mode)
+	}
+}
+
+// ApplyTLSConfig writes the app's tls snippet for the given mode and reloads Caddy if it changed
+func ApplyTLSConfig(executor *ssh.Executor, appName, mode, dnsProvider string) error {
+	var credentialKeys []string
+
+	switch mode {
+	case TLSModeCustom:
+		if exists, _ := executor.FileExists(certDir(appName) + "/cert.pem"); !exists {
+			return fmt.Errorf("no custom certificate uploaded. Run 'mushak tls upload' first")
+		}
+	case TLSModeDNS:
+		content, err := executor.RunSudo(fmt.Sprintf("cat %s", dnsCredentialsPath(appName)))
+		if err != nil {
+			return fmt.Errorf("no DNS credentials stored. Run 'mushak tls dns' first")
+		}
+		credentialKeys = parseCredentialKeys(content)
+	}
+
+	directive, err := GenerateTLSDirective(appName, mode, dnsProvider, credentialKeys)
+	if err != nil {
+		return err
+	}
+
+	var changed bool
+	if directive == "" {
+		changed, err = removeAppSnippet(executor, appName, "tls")
+	} else {
+		changed, err = writeAppSnippet(executor, appName, "tls", directive)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := ReloadCaddy(executor); err != nil {
+		return err
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("TLS mode set to %s", tlsModeLabel(mode)))
+	return nil
+}
+
+// UploadCertificate stores a custom certificate and key for the app
+func UploadCertificate(executor *ssh.Executor, appName, certPEM, keyPEM string) error {
+	ui.PrintInfo("Uploading certificate...")
+
+	dir := certDir(appName)
+	if _, err := executor.RunSudo(fmt.Sprintf("mkdir -p %s", dir)); err != nil {
+		return fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+
+	if err := executor.WriteFileSudo(dir+"/cert.pem", certPEM); err != nil {
+		return fmt.Errorf("failed to upload certificate: %w", err)
+	}
+
+	if err := executor.WriteFileSudo(dir+"/key.pem", keyPEM); err != nil {
+		return fmt.Errorf("failed to upload key: %w", err)
+	}
+
+	// Caddy runs as its own user and must be able to read the key
+	if _, err := executor.RunSudo(fmt.Sprintf("chown -R caddy:caddy %s && chmod 600 %s/key.pem", dir, dir)); err != nil {
+		return fmt.Errorf("failed to set certificate permissions: %w", err)
+	}
+
+	ui.PrintSuccess("Certificate uploaded")
+	return nil
+}
+
+// StoreDNSCredentials stores DNS provider credentials for Caddy's DNS-01 challenge.
+// Credentials are kept in a root-only env file that is loaded into the Caddy
+// service through a systemd drop-in. Variables are prefixed with the app's
+// name, so apps using the same provider don't overwrite each other's credentials.
+func StoreDNSCredentials(executor *ssh.Executor, appName, provider string, credentials map[string]string) error {
+	ui.PrintInfo(fmt.Sprintf("Checking Caddy DNS provider module for %s...", provider))
+
+	if err := ensureCaddyModule(executor, "dns.providers."+provider, "github.com/caddy-dns/"+provider); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(credentials))
+	for k := range credentials {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var content strings.Builder
+	for _, k := range keys {
+		content.WriteString(fmt.Sprintf("%s%s=%s\n", dnsCredentialPrefix(appName), k, credentials[k]))
+	}
+
+	if _, err := executor.RunSudo("mkdir -p /etc/caddy/dns /etc/systemd/system/caddy.service.d"); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+
+	path := dnsCredentialsPath(appName)
+	if err := executor.WriteFileSudo(path, content.String()); err != nil {
+		return fmt.Errorf("failed to write DNS credentials: %w", err)
+	}
+
+	if _, err := executor.RunSudo(fmt.Sprintf("chmod 600 %s", path)); err != nil {
+		return fmt.Errorf("failed to set credentials permissions: %w", err)
+	}
+
+	dropIn := fmt.Sprintf("[Service]\nEnvironmentFile=%s\n", path)
+	if err := executor.WriteFileSudo(caddyDropInPath(appName), dropIn); err != nil {
+		return fmt.Errorf("failed to write Caddy service drop-in: %w", err)
+	}
+
+	// Environment changes need a full restart, a reload is not enough
+	if _, err := executor.RunSudo("systemctl daemon-reload && systemctl restart caddy"); err != nil {
+		return fmt.Errorf("failed to restart Caddy: %w", err)
+	}
+
+	ui.PrintSuccess("DNS credentials stored")
+	return nil
+}
+
+// GetTLSStatus reports the certificate served for each domain and recent Caddy errors
+func GetTLSStatus(executor *ssh.Executor, domains []string) []TLSStatus {
+	var statuses []TLSStatus
+
+	for _, domain := range domains {
+		status := TLSStatus{Domain: domain}
+
+		certCmd := fmt.Sprintf("echo | timeout 10 openssl s_client -connect 127.0.0.1:443 -servername %s 2>/dev/null | openssl x509 -noout -issuer -enddate 2>/dev/null", domain)
+		if out, err := executor.Run(certCmd); err == nil {
+			status.Issuer, status.NotAfter = parseCertificateInfo(out)
+		}
+
+		logCmd := fmt.Sprintf("sudo journalctl -u caddy --since '24 hours ago' --no-pager -o cat 2>/dev/null | grep '\"level\":\"error\"' | grep -F '%s' | tail -n 5", domain)
+		if out, err := executor.Run(logCmd); err == nil {
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+				if line != "" {
+					status.Errors = append(status.Errors, line)
+				}
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// parseCertificateInfo extracts issuer and expiry from `openssl x509 -issuer -enddate` output
+func parseCertificateInfo(output string) (string, string) {
+	var issuer, notAfter string
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "issuer=") {
+			issuer = strings.TrimSpace(strings.TrimPrefix(line, "issuer="))
+		} else if strings.HasPrefix(line, "notAfter=") {
+			notAfter = strings.TrimSpace(strings.TrimPrefix(line, "notAfter="))
+		}
+	}
+
+	return issuer, notAfter
+}
+
+// parseCredentialKeys returns the variable names from a credentials env file
+func parseCredentialKeys(content string) []string {
+	var keys []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
+			keys = append(keys, parts[0])
+		}
+	}
+	return keys
+}
+
+// removeTLSFiles removes certificates and DNS credentials stored for the app
+func removeTLSFiles(executor *ssh.Executor, appName string) error {
+	if _, err := executor.RunSudo(fmt.Sprintf("rm -rf %s %s", certDir(appName), dnsCredentialsPath(appName))); err != nil {
+		return fmt.Errorf("failed to remove TLS files: %w", err)
+	}
+
+	if exists, _ := executor.FileExists(caddyDropInPath(appName)); exists {
+		if _, err := executor.RunSudo(fmt.Sprintf("rm -f %s && systemctl daemon-reload", caddyDropInPath(appName))); err != nil {
+			return fmt.Errorf("failed to remove Caddy service drop-in: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func tlsModeLabel(mode string) string {
+	if mode == "" {
+		return TLSModeAuto
+	}
+	return mode
+}
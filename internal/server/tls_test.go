@@ -0,0 +1,134 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateTLSDirective(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		provider string
+		keys     []string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "default mode",
+			mode: "",
+			want: "",
+		},
+		{
+			name: "auto mode",
+			mode: TLSModeAuto,
+			want: "",
+		},
+		{
+			name: "internal CA",
+			mode: TLSModeInternal,
+			want: "tls internal\n",
+		},
+		{
+			name: "custom certificate",
+			mode: TLSModeCustom,
+			want: "tls /etc/caddy/certs/myapp/cert.pem /etc/caddy/certs/myapp/key.pem\n",
+		},
+		{
+			name:     "dns with single credential",
+			mode:     TLSModeDNS,
+			provider: "cloudflare",
+			keys:     []string{"MUSHAK_MYAPP_CLOUDFLARE_API_TOKEN"},
+			want:     "tls {\n\tdns cloudflare {env.MUSHAK_MYAPP_CLOUDFLARE_API_TOKEN}\n}\n",
+		},
+		{
+			name:     "dns with several credentials",
+			mode:     TLSModeDNS,
+			provider: "route53",
+			keys:     []string{"MUSHAK_MYAPP_ACCESS_KEY_ID", "MUSHAK_MYAPP_SECRET_ACCESS_KEY"},
+			want:     "tls {\n\tdns route53 {\n\t\taccess_key_id {env.MUSHAK_MYAPP_ACCESS_KEY_ID}\n\t\tsecret_access_key {env.MUSHAK_MYAPP_SECRET_ACCESS_KEY}\n\t}\n}\n",
+		},
+		{
+			name:     "dns with several credentials stored without app prefix",
+			mode:     TLSModeDNS,
+			provider: "route53",
+			keys:     []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"},
+			want:     "tls {\n\tdns route53\n}\n",
+		},
+		{
+			name:    "dns without provider",
+			mode:    TLSModeDNS,
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			mode:    "letsencrypt",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateTLSDirective("myapp", tt.mode, tt.provider, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateTLSDirective() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateTLSDirective() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCertificateInfo(t *testing.T) {
+	output := `issuer=C = US, O = Let's Encrypt, CN = R3
+notAfter=Mar 14 12:00:00 2025 GMT
+`
+	issuer, notAfter := parseCertificateInfo(output)
+
+	if !strings.Contains(issuer, "Let's Encrypt") {
+		t.Errorf("issuer = %q, want Let's Encrypt issuer", issuer)
+	}
+	if notAfter != "Mar 14 12:00:00 2025 GMT" {
+		t.Errorf("notAfter = %q, want Mar 14 12:00:00 2025 GMT", notAfter)
+	}
+
+	issuer, notAfter = parseCertificateInfo("")
+	if issuer != "" || notAfter != "" {
+		t.Errorf("parseCertificateInfo(\"\") = %q, %q, want empty", issuer, notAfter)
+	}
+}
+
+func TestParseCredentialKeys(t *testing.T) {
+	content := "# comment\nAWS_ACCESS_KEY_ID=abc\n\nAWS_SECRET_ACCESS_KEY=def=ghi\n"
+	keys := parseCredentialKeys(content)
+
+	want := []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}
+	if len(keys) != len(want) {
+		t.Fatalf("parseCredentialKeys() = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("keys[%d] = %q, want %q", i, keys[i], want[i])
+		}
+	}
+}
+
+func TestDNSCredentialPrefix(t *testing.T) {
+	tests := map[string]string{
+		"myapp":  "MUSHAK_MYAPP_",
+		"my-app": "MUSHAK_MY_APP_",
+		"api.v2": "MUSHAK_API_V2_",
+	}
+	for app, want := range tests {
+		if got := dnsCredentialPrefix(app); got != want {
+			t.Errorf("dnsCredentialPrefix(%q) = %q, want %q", app, got, want)
+		}
+	}
+}
+
+func TestAppSnippetDir(t *testing.T) {
+	if got := appSnippetDir("myapp"); got != "/etc/caddy/apps/myapp.d" {
+		t.Errorf("appSnippetDir() = %q, want /etc/caddy/apps/myapp.d", got)
+	}
+}
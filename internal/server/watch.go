@@ -0,0 +1,217 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hmontazeri/mushak/internal/config"
+	"github.com/hmontazeri/mushak/internal/ssh"
+	"github.com/hmontazeri/mushak/internal/ui"
+)
+
+// watchPollInterval is how often a release is checked during the watch window
+const watchPollInterval = 5 * time.Second
+
+// containerState is the part of `docker inspect` the watch looks at
+type containerState struct {
+	RestartCount int
+	Status       string
+	ExitCode     int
+}
+
+// WatchRelease monitors the current release for the watch window configured in
+// mushak.yaml. If its containers crash or too many requests fail with 5xx, it
+// rolls back to the previous release and returns an error describing why.
+func WatchRelease(executor *ssh.Executor, cfg *config.DeployConfig, watch config.WatchConfig) error {
+	window, err := watch.Window()
+	if err != nil || window == 0 {
+		return err
+	}
+
+	sha, err := getCurrentSHA(executor, cfg.AppName)
+	if err != nil {
+		return fmt.Errorf("failed to find the new release: %w", err)
+	}
+
+	// Compare log timestamps with the server's clock, not ours
+	now, err := executor.Run("date +%s")
+	if err != nil {
+		return fmt.Errorf("failed to read server time: %w", err)
+	}
+	epoch, err := strconv.ParseInt(strings.TrimSpace(now), 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to read server time: %w", err)
+	}
+	since := time.Unix(epoch, 0)
+
+	baseline, err := releaseContainers(executor, cfg.AppName, sha)
+	if err != nil {
+		return err
+	}
+	if len(baseline) == 0 {
+		return fmt.Errorf("no containers found for release %s", sha)
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Watching release %s for %s (Ctrl+C to stop watching)...", sha, window))
+
+	deadline := time.Now().Add(window)
+	for time.Now().Before(deadline) {
+		wait := watchPollInterval
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+		time.Sleep(wait)
+
+		current, err := releaseContainers(executor, cfg.AppName, sha)
+		if err != nil {
+			ui.PrintWarning(fmt.Sprintf("Failed to check containers: %v", err))
+			continue
+		}
+
+		problem := containerProblem(baseline, current, watch.MaxRestarts)
+		if problem == "" {
+			entries, err := readAccessLogSince(executor, cfg.AppName, since)
+			if err != nil {
+				ui.PrintWarning(fmt.Sprintf("Failed to read access log: %v", err))
+				continue
+			}
+			problem = errorRateProblem(SummarizeAccessLog(entries), watch.ErrorRateLimit(), watch.RequestThreshold())
+		}
+
+		if problem != "" {
+			return rollbackUnhealthyRelease(executor, cfg, sha, problem)
+		}
+	}
+
+	ui.PrintSuccess(fmt.Sprintf("Release %s stayed healthy for %s", sha, window))
+	return nil
+}
+
+// rollbackUnhealthyRelease rolls back to the release that was live before sha.
+// Versions are ordered by their latest manifest entry, so a release that was
+// rolled back to counts as live from the rollback on.
+func rollbackUnhealthyRelease(executor *ssh.Executor, cfg *config.DeployConfig, sha, problem string) error {
+	ui.PrintError(fmt.Sprintf("Release %s is unhealthy: %s", sha, problem))
+
+	versions, err := ListVersions(executor, cfg.AppName)
+	if err != nil {
+		return fmt.Errorf("release %s is unhealthy (%s) and listing releases failed: %w", sha, problem, err)
+	}
+
+	previous := ""
+	for _, v := range versions {
+		if v.SHA != sha && v.HasDir {
+			previous = v.SHA
+			break
+		}
+	}
+	if previous == "" {
+		return fmt.Errorf("release %s is unhealthy (%s) and there is no previous release to roll back to", sha, problem)
+	}
+
+	ui.PrintWarning(fmt.Sprintf("Rolling back automatically to %s", previous))
+	if err := ExecuteRollback(executor, cfg, previous); err != nil {
+		return fmt.Errorf("release %s is unhealthy (%s) and the automatic rollback failed: %w", sha, problem, err)
+	}
+
+	return fmt.Errorf("release %s was rolled back to %s: %s", sha, previous, problem)
+}
+
+// releaseContainers returns the state of every container of a release
+func releaseContainers(executor *ssh.Executor, appName, sha string) (map[string]containerState, error) {
+	cmd := fmt.Sprintf("docker ps -aq --filter 'name=^mushak-%s-%s' | xargs -r docker inspect -f '{{.Name}} {{.RestartCount}} {{.State.Status}} {{.State.ExitCode}}'", appName, sha)
+	output, err := executor.Run(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect containers: %w", err)
+	}
+	return parseContainerStates(output), nil
+}
+
+// parseContainerStates parses lines of "NAME RESTARTS STATUS EXITCODE"
+func parseContainerStates(output string) map[string]containerState {
+	states := make(map[string]containerState)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+
+		restarts, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		exitCode, _ := strconv.Atoi(fields[3])
+
+		states[strings.TrimPrefix(fields[0], "/")] = containerState{
+			RestartCount: restarts,
+			Status:       fields[2],
+			ExitCode:     exitCode,
+		}
+	}
+	return states
+}
+
+// containerProblem describes why a release's containers are unhealthy, or returns ""
+func containerProblem(baseline, current map[string]containerState, maxRestarts int) string {
+	names := make([]string, 0, len(baseline))
+	for name := range baseline {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		state, ok := current[name]
+		if !ok {
+			return fmt.Sprintf("container %s disappeared", name)
+		}
+
+		if restarts := state.RestartCount - baseline[name].RestartCount; restarts > maxRestarts {
+			return fmt.Sprintf("container %s restarted %d time%s", name, restarts, pluralS(restarts))
+		}
+
+		// Containers that finish their job and exit cleanly are fine
+		if (state.Status == "exited" || state.Status == "dead") && state.ExitCode != 0 {
+			return fmt.Sprintf("container %s exited with code %d", name, state.ExitCode)
+		}
+	}
+	return ""
+}
+
+// errorRateProblem describes a 5xx rate above the limit, or returns "" while
+// there are too few requests to judge
+func errorRateProblem(s AccessLogSummary, maxRate float64, minRequests int) string {
+	if s.Requests < minRequests {
+		return ""
+	}
+	if rate := s.ErrorRate(); rate > maxRate {
+		return fmt.Sprintf("%.1f%% of %d requests failed with 5xx (limit %.1f%%)", rate, s.Requests, maxRate)
+	}
+	return ""
+}
+
+// readAccessLogSince returns the app's access log entries logged at or after since
+func readAccessLogSince(executor *ssh.Executor, appName string, since time.Time) ([]*AccessLogEntry, error) {
+	output, err := executor.RunSudo(fmt.Sprintf("tail -n 10000 %s 2>/dev/null || true", AccessLogPath(appName)))
+	if err != nil {
+		return nil, err
+	}
+
+	filter := AccessLogFilter{Since: since}
+	var entries []*AccessLogEntry
+	for _, line := range strings.Split(output, "\n") {
+		if entry, err := ParseAccessLogLine(line); err == nil && filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func pluralS(count int) string {
+	if count == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseContainerStates(t *testing.T) {
+	output := "/mushak-app-abc1234-web 2 running 0\n/mushak-app-abc1234-migrate 0 exited 0\ngarbage\n"
+
+	states := parseContainerStates(output)
+	if len(states) != 2 {
+		t.Fatalf("parseContainerStates() returned %d states, want 2: %v", len(states), states)
+	}
+
+	web := states["mushak-app-abc1234-web"]
+	if web.RestartCount != 2 || web.Status != "running" {
+		t.Errorf("web state = %+v", web)
+	}
+}
+
+func TestContainerProblem(t *testing.T) {
+	baseline := map[string]containerState{
+		"web":     {RestartCount: 0, Status: "running"},
+		"migrate": {RestartCount: 0, Status: "exited"},
+	}
+
+	tests := []struct {
+		name        string
+		current     map[string]containerState
+		maxRestarts int
+		want        string
+	}{
+		{
+			name:    "healthy",
+			current: map[string]containerState{"web": {Status: "running"}, "migrate": {Status: "exited"}},
+		},
+		{
+			name:    "restarted",
+			current: map[string]containerState{"web": {RestartCount: 1, Status: "running"}, "migrate": {Status: "exited"}},
+			want:    "container web restarted 1 time",
+		},
+		{
+			name:        "restarts tolerated",
+			current:     map[string]containerState{"web": {RestartCount: 2, Status: "running"}, "migrate": {Status: "exited"}},
+			maxRestarts: 2,
+		},
+		{
+			name:    "crashed",
+			current: map[string]containerState{"web": {Status: "exited", ExitCode: 137}, "migrate": {Status: "exited"}},
+			want:    "container web exited with code 137",
+		},
+		{
+			name:    "disappeared",
+			current: map[string]containerState{"migrate": {Status: "exited"}},
+			want:    "container web disappeared",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containerProblem(baseline, tt.current, tt.maxRestarts); got != tt.want {
+				t.Errorf("containerProblem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorRateProblem(t *testing.T) {
+	summary := AccessLogSummary{Requests: 10, StatusClasses: map[string]int{"2xx": 5, "5xx": 5}}
+
+	if got := errorRateProblem(summary, 5, 20); got != "" {
+		t.Errorf("errorRateProblem() = %q, want no verdict below min requests", got)
+	}
+
+	if got := errorRateProblem(summary, 5, 10); !strings.Contains(got, "50.0% of 10 requests") {
+		t.Errorf("errorRateProblem() = %q, want the failure rate", got)
+	}
+
+	if got := errorRateProblem(summary, 60, 10); got != "" {
+		t.Errorf("errorRateProblem() = %q, want no problem under the limit", got)
+	}
+}
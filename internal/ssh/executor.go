@@ -5,14 +5,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 // Executor handles remote command execution
 type Executor struct {
-	client *Client
+	client       *Client
+	forwardAgent bool
 }
 
 // NewExecutor creates a new executor for the given client
@@ -20,6 +23,20 @@ func NewExecutor(client *Client) *Executor {
 	return &Executor{client: client}
 }
 
+// ForwardAgent forwards the local SSH agent to commands started with StreamRun,
+// so the server can use the local keys, e.g. for SSH mounts in docker builds
+func (e *Executor) ForwardAgent() error {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return fmt.Errorf("no SSH agent is running (SSH_AUTH_SOCK is not set)")
+	}
+	if err := agent.ForwardToRemote(e.client.client, socket); err != nil {
+		return fmt.Errorf("failed to forward SSH agent: %w", err)
+	}
+	e.forwardAgent = true
+	return nil
+}
+
 // Run executes a command and returns stdout
 func (e *Executor) Run(cmd string) (string, error) {
 	return e.RunWithContext(context.Background(), cmd)
@@ -71,6 +88,12 @@ func (e *Executor) StreamRun(cmd string, stdout, stderr io.Writer) error {
 	}
 	defer session.Close()
 
+	if e.forwardAgent {
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return fmt.Errorf("failed to request SSH agent forwarding: %w", err)
+		}
+	}
+
 	session.Stdout = stdout
 	session.Stderr = stderr
 
@@ -81,6 +104,27 @@ func (e *Executor) StreamRun(cmd string, stdout, stderr io.Writer) error {
 	return nil
 }
 
+// RunWithInput executes a command with stdin read from input and returns stdout.
+// Unlike RunInteractive no PTY is requested, so binary input is passed through unchanged.
+func (e *Executor) RunWithInput(cmd string, input io.Reader) (string, error) {
+	session, err := e.client.client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = input
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	if err := session.Run(cmd); err != nil {
+		return "", fmt.Errorf("command failed: %w\nstderr: %s", err, stderr.String())
+	}
+
+	return stdout.String(), nil
+}
+
 // RunSudo executes a command with sudo
 func (e *Executor) RunSudo(cmd string) (string, error) {
 	return e.Run("sudo " + cmd)
@@ -2,18 +2,22 @@ package utils
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// DetectInternalPort attempts to find the port the application listens on
-func DetectInternalPort() int {
+// DetectInternalPort attempts to find the port the application listens on.
+// dockerfile is the Dockerfile's path relative to the repository root, and
+// target the stage that is built, if any.
+func DetectInternalPort(dockerfile, target string) int {
 	// 1. Check Dockerfile
-	if port := detectFromDockerfile(); port > 0 {
+	if port := detectFromDockerfile(dockerfile, target); port > 0 {
 		return port
 	}
 
@@ -25,33 +29,160 @@ func DetectInternalPort() int {
 	return 0
 }
 
-func detectFromDockerfile() int {
-	f, err := os.Open("Dockerfile")
+// detectFromDockerfile returns the port of the first EXPOSE in the target
+// stage, or in the whole Dockerfile if the stage has none
+func detectFromDockerfile(dockerfile, target string) int {
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+
+	f, err := os.Open(dockerfile)
 	if err != nil {
 		return 0
 	}
 	defer f.Close()
 
 	scanner := bufio.NewScanner(f)
-	re := regexp.MustCompile(`(?i)^EXPOSE\s+(\d+)`)
+	exposeRe := regexp.MustCompile(`(?i)^EXPOSE\s+(\d+)`)
+	fromRe := regexp.MustCompile(`(?i)^FROM\s.*\sAS\s+(\S+)`)
+
+	firstPort, targetPort := 0, 0
+	inTarget := false
 	for scanner.Scan() {
-		matches := re.FindStringSubmatch(scanner.Text())
-		if len(matches) > 1 {
-			port, _ := strconv.Atoi(matches[1])
-			return port
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(strings.ToUpper(line), "FROM") {
+			matches := fromRe.FindStringSubmatch(line)
+			inTarget = target != "" && len(matches) > 1 && strings.EqualFold(matches[1], target)
+			continue
+		}
+
+		matches := exposeRe.FindStringSubmatch(line)
+		if len(matches) < 2 {
+			continue
+		}
+		port, _ := strconv.Atoi(matches[1])
+		if firstPort == 0 {
+			firstPort = port
+		}
+		if inTarget && targetPort == 0 {
+			targetPort = port
 		}
 	}
-	return 0
+
+	if targetPort > 0 {
+		return targetPort
+	}
+	return firstPort
 }
 
-func detectFromCompose() int {
-	filename := ""
+// composeFile returns the name of the local compose file, or "" if there is none
+func composeFile() string {
 	if _, err := os.Stat("docker-compose.yml"); err == nil {
-		filename = "docker-compose.yml"
+		return "docker-compose.yml"
 	} else if _, err := os.Stat("docker-compose.yaml"); err == nil {
-		filename = "docker-compose.yaml"
+		return "docker-compose.yaml"
+	}
+	return ""
+}
+
+// HasComposeFile reports whether the project has a local compose file
+func HasComposeFile() bool {
+	return composeFile() != ""
+}
+
+// ComposeServices returns the service names defined in the local compose file,
+// sorted. It returns nil without error if the project has no compose file.
+func ComposeServices() ([]string, error) {
+	filename := composeFile()
+	if filename == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	var config struct {
+		Services map[string]interface{} `yaml:"services"`
 	}
 
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	services := make([]string, 0, len(config.Services))
+	for name := range config.Services {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+
+	return services, nil
+}
+
+// ComposeBuildServices returns the services of a compose file that are built
+// from source rather than run from an image, sorted
+func ComposeBuildServices(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var config struct {
+		Services map[string]struct {
+			Build interface{} `yaml:"build"`
+		} `yaml:"services"`
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var services []string
+	for name, svc := range config.Services {
+		if svc.Build != nil {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+
+	return services, nil
+}
+
+// ComposeWebService returns the service the post-receive hook routes traffic
+// to: the first service with "web" in its name, otherwise the first service
+func ComposeWebService(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	// Decode into nodes to keep the services in file order
+	var config struct {
+		Services yaml.Node `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	var services []string
+	for i := 0; i+1 < len(config.Services.Content); i += 2 {
+		services = append(services, config.Services.Content[i].Value)
+	}
+	if len(services) == 0 {
+		return "", fmt.Errorf("no services found in %s", path)
+	}
+
+	for _, service := range services {
+		if strings.Contains(service, "web") {
+			return service, nil
+		}
+	}
+	return services[0], nil
+}
+
+func detectFromCompose() int {
+	filename := composeFile()
 	if filename == "" {
 		return 0
 	}
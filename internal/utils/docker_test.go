@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestComposeServices(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := ComposeServices()
+	if err != nil || services != nil {
+		t.Errorf("ComposeServices() without compose file = %v, %v, want nil, nil", services, err)
+	}
+
+	compose := "services:\n  web:\n    build: .\n  worker:\n    build: .\n  postgres:\n    image: postgres:16\n"
+	if err := os.WriteFile("docker-compose.yaml", []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err = ComposeServices()
+	if err != nil {
+		t.Fatalf("ComposeServices() error = %v", err)
+	}
+	if want := []string{"postgres", "web", "worker"}; !reflect.DeepEqual(services, want) {
+		t.Errorf("ComposeServices() = %v, want %v", services, want)
+	}
+}
+
+func TestComposeBuildServices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	compose := `services:
+  web:
+    build: .
+  worker:
+    build:
+      context: ./worker
+  db:
+    image: postgres:16
+`
+	if err := os.WriteFile(path, []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := ComposeBuildServices(path)
+	if err != nil {
+		t.Fatalf("ComposeBuildServices() error = %v", err)
+	}
+	if len(services) != 2 || services[0] != "web" || services[1] != "worker" {
+		t.Errorf("ComposeBuildServices() = %v, want [web worker]", services)
+	}
+}
+
+func TestDetectInternalPort(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if port := DetectInternalPort("", ""); port != 0 {
+		t.Errorf("DetectInternalPort() without Dockerfile = %d, want 0", port)
+	}
+
+	dockerfile := "FROM node:20 AS build\nEXPOSE 9229\n\nFROM node:20-slim AS runner\nEXPOSE 3000\n"
+	if err := os.MkdirAll("docker", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("docker", "Dockerfile.prod"), []byte(dockerfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dockerfile string
+		target     string
+		want       int
+	}{
+		{dockerfile: "", target: "", want: 0},
+		{dockerfile: "docker/Dockerfile.prod", target: "", want: 9229},
+		{dockerfile: "docker/Dockerfile.prod", target: "runner", want: 3000},
+		{dockerfile: "docker/Dockerfile.prod", target: "BUILD", want: 9229},
+		{dockerfile: "docker/Dockerfile.prod", target: "missing", want: 9229},
+	}
+
+	for _, tt := range tests {
+		if got := DetectInternalPort(tt.dockerfile, tt.target); got != tt.want {
+			t.Errorf("DetectInternalPort(%q, %q) = %d, want %d", tt.dockerfile, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestComposeWebService(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		compose string
+		want    string
+	}{
+		{compose: "services:\n  worker:\n    build: .\n  api-web:\n    build: .\n", want: "api-web"},
+		{compose: "services:\n  worker:\n    build: .\n  api:\n    build: .\n", want: "worker"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, "docker-compose.yml")
+		if err := os.WriteFile(path, []byte(tt.compose), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ComposeWebService(path)
+		if err != nil {
+			t.Fatalf("ComposeWebService() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("ComposeWebService() = %q, want %q", got, tt.want)
+		}
+	}
+}
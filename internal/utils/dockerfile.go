@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Stack is a kind of project Mushak can generate a Dockerfile for
+type Stack string
+
+const (
+	StackNode   Stack = "node"
+	StackGo     Stack = "go"
+	StackPython Stack = "python"
+	StackStatic Stack = "static"
+)
+
+// Name returns the stack's display name
+func (s Stack) Name() string {
+	switch s {
+	case StackNode:
+		return "Node.js"
+	case StackGo:
+		return "Go"
+	case StackPython:
+		return "Python"
+	case StackStatic:
+		return "static site"
+	}
+	return string(s)
+}
+
+// DetectStack returns the stack of the project in dir, or "" if it isn't one
+// Mushak can generate a Dockerfile for
+func DetectStack(dir string) Stack {
+	switch {
+	case fileExists(dir, "package.json"):
+		return StackNode
+	case fileExists(dir, "go.mod"):
+		return StackGo
+	case fileExists(dir, "requirements.txt"), fileExists(dir, "pyproject.toml"):
+		return StackPython
+	case fileExists(dir, "index.html"):
+		return StackStatic
+	}
+	return ""
+}
+
+// GenerateDockerfile returns a Dockerfile for the project in dir, which is
+// the build context
+func GenerateDockerfile(dir string, stack Stack) (string, error) {
+	switch stack {
+	case StackNode:
+		return generateNodeDockerfile(dir)
+	case StackGo:
+		return generateGoDockerfile(dir)
+	case StackPython:
+		return generatePythonDockerfile(dir)
+	case StackStatic:
+		return staticDockerfile, nil
+	}
+	return "", fmt.Errorf("no Dockerfile can be generated for %s projects", stack)
+}
+
+// generatedHeader marks generated Dockerfiles, so they are recognizable once committed
+const generatedHeader = "# Generated by mushak. Review it and adjust it to your app\n"
+
+// nodeMajorPattern matches the major version an engines.node constraint starts with
+var nodeMajorPattern = regexp.MustCompile(`^[\^~>=v ]*(\d+)`)
+
+// generateNodeDockerfile installs the dependencies with the package manager
+// of the lockfile, runs the build script if there is one and starts the app
+// with the start script, or with node and the main file
+func generateNodeDockerfile(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var pkg struct {
+		Main    string            `json:"main"`
+		Scripts map[string]string `json:"scripts"`
+		Engines struct {
+			Node string `json:"node"`
+		} `json:"engines"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return "", fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	version := "lts"
+	if matches := nodeMajorPattern.FindStringSubmatch(pkg.Engines.Node); matches != nil {
+		version = matches[1]
+	}
+
+	manager, lockfile, install := "npm", "", "npm install"
+	switch {
+	case fileExists(dir, "pnpm-lock.yaml"):
+		manager, lockfile, install = "pnpm", "pnpm-lock.yaml", "corepack enable && pnpm install --frozen-lockfile"
+	case fileExists(dir, "yarn.lock"):
+		manager, lockfile, install = "yarn", "yarn.lock", "corepack enable && yarn install --frozen-lockfile"
+	case fileExists(dir, "package-lock.json"):
+		lockfile, install = "package-lock.json", "npm ci"
+	}
+
+	var b strings.Builder
+	b.WriteString(generatedHeader)
+	fmt.Fprintf(&b, "FROM node:%s-alpine\n", version)
+	b.WriteString("WORKDIR /app\n\n")
+	fmt.Fprintf(&b, "COPY %s ./\n", strings.TrimSpace("package.json "+lockfile))
+	fmt.Fprintf(&b, "RUN %s\n\n", install)
+	b.WriteString("COPY . .\n")
+	if _, ok := pkg.Scripts["build"]; ok {
+		fmt.Fprintf(&b, "RUN %s run build\n", manager)
+	}
+	b.WriteString("\nENV NODE_ENV=production\n")
+	b.WriteString("ENV PORT=3000\n")
+	b.WriteString("EXPOSE 3000\n")
+
+	switch {
+	case pkg.Scripts["start"] != "":
+		fmt.Fprintf(&b, "CMD [\"%s\", \"start\"]\n", manager)
+	case pkg.Main != "":
+		fmt.Fprintf(&b, "CMD [\"node\", %q]\n", pkg.Main)
+	default:
+		b.WriteString("CMD [\"node\", \"index.js\"]\n")
+	}
+	return b.String(), nil
+}
+
+// goVersionPattern matches the Go version in go.mod
+var goVersionPattern = regexp.MustCompile(`(?m)^go\s+(\d+\.\d+)`)
+
+// generateGoDockerfile builds a static binary of the main package, at the
+// root or the first one under cmd/, and runs it on Alpine
+func generateGoDockerfile(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	version := "1"
+	if matches := goVersionPattern.FindSubmatch(data); matches != nil {
+		version = string(matches[1])
+	}
+
+	pkg := "."
+	if !isMainPackage(dir) {
+		dirs, _ := filepath.Glob(filepath.Join(dir, "cmd", "*"))
+		for _, cmdDir := range dirs {
+			if isMainPackage(cmdDir) {
+				pkg = "./cmd/" + filepath.Base(cmdDir)
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(generatedHeader)
+	fmt.Fprintf(&b, "FROM golang:%s-alpine AS build\n", version)
+	b.WriteString("WORKDIR /src\n\n")
+	b.WriteString("COPY go.mod go.sum* ./\n")
+	b.WriteString("RUN go mod download\n\n")
+	b.WriteString("COPY . .\n")
+	fmt.Fprintf(&b, "RUN CGO_ENABLED=0 go build -trimpath -ldflags=\"-s -w\" -o /out/app %s\n\n", pkg)
+	b.WriteString("FROM alpine:3\n")
+	b.WriteString("RUN apk add --no-cache ca-certificates tzdata\n")
+	b.WriteString("COPY --from=build /out/app /usr/local/bin/app\n\n")
+	b.WriteString("ENV PORT=8080\n")
+	b.WriteString("EXPOSE 8080\n")
+	b.WriteString("CMD [\"app\"]\n")
+	return b.String(), nil
+}
+
+// mainPackagePattern matches the package clause of package main
+var mainPackagePattern = regexp.MustCompile(`(?m)^package main\b`)
+
+// isMainPackage reports whether dir has Go files in package main
+func isMainPackage(dir string) bool {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err == nil && mainPackagePattern.Match(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// pythonVersionPattern matches the minor version in .python-version
+var pythonVersionPattern = regexp.MustCompile(`^(\d+\.\d+)`)
+
+// generatePythonDockerfile installs requirements.txt, or the project itself
+// from pyproject.toml, and serves Django with gunicorn, FastAPI with uvicorn
+// and Flask with gunicorn. Other apps are run with python.
+func generatePythonDockerfile(dir string) (string, error) {
+	version := "3.13"
+	if data, err := os.ReadFile(filepath.Join(dir, ".python-version")); err == nil {
+		if matches := pythonVersionPattern.FindSubmatch(data); matches != nil {
+			version = string(matches[1])
+		}
+	}
+
+	var deps string
+	for _, name := range []string{"requirements.txt", "pyproject.toml"} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			deps += strings.ToLower(string(data))
+		}
+	}
+	hasDep := func(name string) bool {
+		return regexp.MustCompile(`(^|[^a-z0-9_-])` + name + `([^a-z0-9_-]|$)`).MatchString(deps)
+	}
+
+	// The module with the app, for frameworks that serve module:app
+	module := "main"
+	for _, name := range []string{"main", "app", "server", "wsgi"} {
+		if fileExists(dir, name+".py") {
+			module = name
+			break
+		}
+	}
+
+	var server, cmd string
+	switch {
+	case fileExists(dir, "manage.py"):
+		project := "app"
+		if matches, _ := filepath.Glob(filepath.Join(dir, "*", "wsgi.py")); len(matches) > 0 {
+			project = filepath.Base(filepath.Dir(matches[0]))
+		}
+		server = "gunicorn"
+		cmd = fmt.Sprintf(`["gunicorn", "--bind", "0.0.0.0:8000", "%s.wsgi"]`, project)
+	case hasDep("fastapi"):
+		server = "uvicorn"
+		cmd = fmt.Sprintf(`["uvicorn", "%s:app", "--host", "0.0.0.0", "--port", "8000"]`, module)
+	case hasDep("flask"):
+		server = "gunicorn"
+		cmd = fmt.Sprintf(`["gunicorn", "--bind", "0.0.0.0:8000", "%s:app"]`, module)
+	default:
+		cmd = fmt.Sprintf(`["python", "%s.py"]`, module)
+	}
+
+	var b strings.Builder
+	b.WriteString(generatedHeader)
+	fmt.Fprintf(&b, "FROM python:%s-slim\n", version)
+	b.WriteString("WORKDIR /app\n")
+	b.WriteString("ENV PYTHONDONTWRITEBYTECODE=1 PYTHONUNBUFFERED=1\n\n")
+	if fileExists(dir, "requirements.txt") {
+		b.WriteString("COPY requirements.txt ./\n")
+		b.WriteString("RUN pip install --no-cache-dir -r requirements.txt\n")
+		if server != "" && !hasDep(server) {
+			fmt.Fprintf(&b, "RUN pip install --no-cache-dir %s\n", server)
+		}
+		b.WriteString("\nCOPY . .\n")
+	} else {
+		b.WriteString("COPY . .\n")
+		b.WriteString("RUN pip install --no-cache-dir .\n")
+		if server != "" && !hasDep(server) {
+			fmt.Fprintf(&b, "RUN pip install --no-cache-dir %s\n", server)
+		}
+	}
+	b.WriteString("\nENV PORT=8000\n")
+	b.WriteString("EXPOSE 8000\n")
+	fmt.Fprintf(&b, "CMD %s\n", cmd)
+	return b.String(), nil
+}
+
+// staticDockerfile serves the files with Caddy, whose image serves
+// /usr/share/caddy on port 80
+const staticDockerfile = generatedHeader + `FROM caddy:2-alpine
+COPY . /usr/share/caddy
+EXPOSE 80
+`
+
+// GeneratedDockerignore keeps env files, secrets and Mushak's own files out
+// of images built with a generated Dockerfile, whose COPY . takes the whole
+// build context. Apps get their environment when the container starts.
+const GeneratedDockerignore = `# Added by mushak: env files and secrets are passed at runtime, not baked into the image
+.env*
+secrets.env.enc
+mushak.yaml
+.mushak*
+.git
+Dockerfile
+.dockerignore
+`
+
+// AddGeneratedDockerignore adds the entries of GeneratedDockerignore that the
+// .dockerignore in dir is missing, creating it if needed. It reports whether
+// the file was changed.
+func AddGeneratedDockerignore(dir string) (bool, error) {
+	path := filepath.Join(dir, ".dockerignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+
+	var missing []string
+	for _, line := range strings.Split(strings.TrimSpace(GeneratedDockerignore), "\n") {
+		if !strings.HasPrefix(line, "#") && !existing[line] {
+			missing = append(missing, line)
+		}
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+
+	content := GeneratedDockerignore
+	if len(data) > 0 {
+		content = strings.TrimSuffix(string(data), "\n") + "\n" + strings.Join(missing, "\n") + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, fmt.Errorf("failed to write .dockerignore: %w", err)
+	}
+	return true, nil
+}
+
+// fileExists reports whether name exists in dir
+func fileExists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
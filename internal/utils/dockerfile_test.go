@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeProject creates a project directory with the given files
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDetectStack(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  Stack
+	}{
+		{"node", map[string]string{"package.json": "{}", "index.html": ""}, StackNode},
+		{"go", map[string]string{"go.mod": "module example.com/app\n"}, StackGo},
+		{"python requirements", map[string]string{"requirements.txt": "flask\n"}, StackPython},
+		{"python pyproject", map[string]string{"pyproject.toml": "[project]\n"}, StackPython},
+		{"static", map[string]string{"index.html": "<html></html>"}, StackStatic},
+		{"unknown", map[string]string{"README.md": "# app"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectStack(writeProject(t, tt.files)); got != tt.want {
+				t.Errorf("DetectStack() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateDockerfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		stack   Stack
+		files   map[string]string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:  "node with npm lockfile",
+			stack: StackNode,
+			files: map[string]string{
+				"package.json":      `{"engines": {"node": ">=20"}, "scripts": {"build": "next build", "start": "next start"}}`,
+				"package-lock.json": "{}",
+			},
+			want: []string{
+				"FROM node:20-alpine",
+				"COPY package.json package-lock.json ./",
+				"RUN npm ci",
+				"RUN npm run build",
+				"EXPOSE 3000",
+				`CMD ["npm", "start"]`,
+			},
+		},
+		{
+			name:  "node with pnpm and no build",
+			stack: StackNode,
+			files: map[string]string{
+				"package.json":   `{"main": "server.js"}`,
+				"pnpm-lock.yaml": "",
+			},
+			want: []string{
+				"FROM node:lts-alpine",
+				"RUN corepack enable && pnpm install --frozen-lockfile",
+				`CMD ["node", "server.js"]`,
+			},
+			notWant: []string{"run build"},
+		},
+		{
+			name:  "go main package under cmd",
+			stack: StackGo,
+			files: map[string]string{
+				"go.mod":              "module example.com/app\n\ngo 1.22.3\n",
+				"internal/app/app.go": "package app\n",
+				"cmd/server/main.go":  "package main\n",
+			},
+			want: []string{
+				"FROM golang:1.22-alpine AS build",
+				"-o /out/app ./cmd/server",
+				"EXPOSE 8080",
+			},
+		},
+		{
+			name:  "go main package at the root",
+			stack: StackGo,
+			files: map[string]string{
+				"go.mod":  "module example.com/app\n",
+				"main.go": "// Command app\npackage main\n",
+			},
+			want: []string{"FROM golang:1-alpine AS build", "-o /out/app .\n"},
+		},
+		{
+			name:  "django",
+			stack: StackPython,
+			files: map[string]string{
+				"requirements.txt": "Django==5.0\n",
+				"manage.py":        "",
+				"mysite/wsgi.py":   "",
+				".python-version":  "3.11.4\n",
+			},
+			want: []string{
+				"FROM python:3.11-slim",
+				"RUN pip install --no-cache-dir -r requirements.txt",
+				"RUN pip install --no-cache-dir gunicorn",
+				`"mysite.wsgi"`,
+				"EXPOSE 8000",
+			},
+		},
+		{
+			name:  "fastapi from pyproject",
+			stack: StackPython,
+			files: map[string]string{
+				"pyproject.toml": "[project]\ndependencies = [\"fastapi\", \"uvicorn[standard]\"]\n",
+				"app.py":         "",
+			},
+			want: []string{
+				"RUN pip install --no-cache-dir .",
+				`CMD ["uvicorn", "app:app", "--host", "0.0.0.0", "--port", "8000"]`,
+			},
+			notWant: []string{"pip install --no-cache-dir uvicorn"},
+		},
+		{
+			name:  "flask with gunicorn",
+			stack: StackPython,
+			files: map[string]string{"requirements.txt": "flask\ngunicorn\nflask-login\n", "app.py": ""},
+			want:  []string{`CMD ["gunicorn", "--bind", "0.0.0.0:8000", "app:app"]`},
+			notWant: []string{
+				"pip install --no-cache-dir gunicorn",
+			},
+		},
+		{
+			name:  "static",
+			stack: StackStatic,
+			files: map[string]string{"index.html": ""},
+			want:  []string{"FROM caddy:2-alpine", "COPY . /usr/share/caddy", "EXPOSE 80"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateDockerfile(writeProject(t, tt.files), tt.stack)
+			if err != nil {
+				t.Fatalf("GenerateDockerfile() error = %v", err)
+			}
+			if !strings.HasPrefix(got, generatedHeader) {
+				t.Error("GenerateDockerfile() should start with the generated header")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("GenerateDockerfile() missing %q in:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("GenerateDockerfile() should not contain %q in:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateDockerfileInvalidPackageJSON(t *testing.T) {
+	dir := writeProject(t, map[string]string{"package.json": "{"})
+	if _, err := GenerateDockerfile(dir, StackNode); err == nil {
+		t.Error("GenerateDockerfile() should fail on invalid package.json")
+	}
+}
+
+func TestAddGeneratedDockerignore(t *testing.T) {
+	// A new .dockerignore gets all entries
+	dir := t.TempDir()
+	changed, err := AddGeneratedDockerignore(dir)
+	if err != nil || !changed {
+		t.Fatalf("AddGeneratedDockerignore() = %v, %v, want true", changed, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".dockerignore"))
+	if string(data) != GeneratedDockerignore {
+		t.Errorf(".dockerignore = %q, want %q", data, GeneratedDockerignore)
+	}
+	for _, entry := range []string{".env*", "secrets.env.enc", "mushak.yaml", ".git"} {
+		if !strings.Contains(string(data), entry+"\n") {
+			t.Errorf(".dockerignore missing %q", entry)
+		}
+	}
+
+	// Running it again changes nothing
+	if changed, err := AddGeneratedDockerignore(dir); err != nil || changed {
+		t.Errorf("AddGeneratedDockerignore() again = %v, %v, want false", changed, err)
+	}
+
+	// An existing .dockerignore keeps its entries and gets the missing ones
+	dir = writeProject(t, map[string]string{".dockerignore": "node_modules\n.git"})
+	if changed, err := AddGeneratedDockerignore(dir); err != nil || !changed {
+		t.Fatalf("AddGeneratedDockerignore() = %v, %v, want true", changed, err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, ".dockerignore"))
+	if !strings.HasPrefix(string(data), "node_modules\n.git\n.env*\n") {
+		t.Errorf(".dockerignore = %q, want the existing entries followed by the missing ones", data)
+	}
+	if strings.Count(string(data), ".git\n") != 1 {
+		t.Errorf(".dockerignore = %q, should not repeat .git", data)
+	}
+}
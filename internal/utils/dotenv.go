@@ -0,0 +1,431 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvFile is a parsed dotenv file. It keeps comments, blank lines, ordering
+// and the original formatting of untouched entries, so editing a file and
+// writing it back only changes the variables that were edited.
+type EnvFile struct {
+	lines  []envLine
+	docker bool // values are literal, as read by docker run --env-file
+}
+
+// envLine is one logical line of a dotenv file. Quoted values may span
+// several physical lines.
+type envLine struct {
+	raw    string // original text, written back unchanged unless edited
+	key    string // empty for blank lines, comments and unparseable lines
+	value  string // value as written, without quotes and inline comment
+	quote  byte   // 0 (unquoted), '\'' or '"'
+	export bool   // line starts with "export "
+}
+
+// ParseDotenv parses dotenv content. Supported syntax:
+//   - KEY=value, with an optional "export " prefix
+//   - inline comments after unquoted values ("KEY=value # comment")
+//   - 'single quoted' values, taken literally
+//   - "double quoted" values with \n, \t, \", \\ and \$ escapes
+//   - quoted values spanning several lines (e.g. PEM keys)
+//   - ${VAR}, ${VAR:-default} and $VAR references to variables defined earlier
+//     in the file, in unquoted and double quoted values
+//
+// Lines that are not KEY=VALUE assignments are kept as they are.
+func ParseDotenv(content string) (*EnvFile, error) {
+	f := &EnvFile{}
+
+	lines := strings.Split(content, "\n")
+	// A trailing newline does not start another line
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		start := i
+
+		key, rest, export, ok := splitAssignment(line)
+		if !ok {
+			f.lines = append(f.lines, envLine{raw: line})
+			continue
+		}
+
+		entry := envLine{key: key, export: export}
+		rest = strings.TrimLeft(rest, " \t")
+
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			entry.quote = rest[0]
+			body := rest[1:]
+			raw := line
+
+			// Keep consuming lines until the closing quote
+			for {
+				if end := closingQuote(body, entry.quote); end >= 0 {
+					entry.value += body[:end]
+					break
+				}
+				if i+1 >= len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated quoted value for %s", start+1, key)
+				}
+				entry.value += body + "\n"
+				i++
+				body = strings.TrimSuffix(lines[i], "\r")
+				raw += "\n" + body
+			}
+			entry.raw = raw
+		} else {
+			entry.raw = line
+			entry.value = stripInlineComment(rest)
+		}
+
+		f.lines = append(f.lines, entry)
+	}
+
+	return f, nil
+}
+
+// ParseDockerEnv parses content in the format read by `docker run --env-file`:
+// everything after the first "=" is the value, taken literally. There are no
+// quotes, escapes, inline comments or references, and a value cannot span
+// several lines. Dockerfile apps are run with their env file passed this way.
+func ParseDockerEnv(content string) *EnvFile {
+	f := &EnvFile{docker: true}
+
+	lines := strings.Split(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimLeft(line, " \t")
+
+		key, value, ok := strings.Cut(trimmed, "=")
+		if !ok || strings.HasPrefix(trimmed, "#") || !isValidEnvKey(key) {
+			f.lines = append(f.lines, envLine{raw: line})
+			continue
+		}
+		f.lines = append(f.lines, envLine{raw: line, key: key, value: value})
+	}
+
+	return f
+}
+
+// splitAssignment splits "[export ]KEY=rest" and validates the key
+func splitAssignment(line string) (key, rest string, export, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return "", "", false, false
+	}
+
+	if strings.HasPrefix(trimmed, "export ") {
+		export = true
+		trimmed = strings.TrimLeft(strings.TrimPrefix(trimmed, "export "), " \t")
+	}
+
+	parts := strings.SplitN(trimmed, "=", 2)
+	if len(parts) != 2 {
+		return "", "", false, false
+	}
+
+	key = strings.TrimSpace(parts[0])
+	if !isValidEnvKey(key) {
+		return "", "", false, false
+	}
+
+	return key, parts[1], export, true
+}
+
+func isValidEnvKey(key string) bool {
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for _, c := range key {
+		if !(c == '_' || c == '.' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// closingQuote returns the index of the closing quote in s, or -1
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+// stripInlineComment removes a " # comment" suffix and surrounding whitespace
+func stripInlineComment(value string) string {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '#' && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t') {
+			value = value[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// Keys returns the variable names in the order they first appear
+func (f *EnvFile) Keys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, l := range f.lines {
+		if l.key != "" && !seen[l.key] {
+			seen[l.key] = true
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Vars returns all variables with quotes, escapes and references resolved.
+// A key defined several times takes its last value.
+func (f *EnvFile) Vars() map[string]string {
+	vars := make(map[string]string)
+	for _, l := range f.lines {
+		if l.key == "" {
+			continue
+		}
+		if f.docker {
+			vars[l.key] = l.value
+		} else {
+			vars[l.key] = l.resolve(vars)
+		}
+	}
+	return vars
+}
+
+// Get returns the resolved value of a variable
+func (f *EnvFile) Get(key string) (string, bool) {
+	value, ok := f.Vars()[key]
+	return value, ok
+}
+
+// Set updates every definition of key in place, or appends it if it is not defined
+func (f *EnvFile) Set(key, value string) {
+	found := false
+	for i, l := range f.lines {
+		if l.key != key {
+			continue
+		}
+		f.lines[i] = f.newLine(key, value, l.export)
+		found = true
+	}
+
+	if !found {
+		f.lines = append(f.lines, f.newLine(key, value, false))
+	}
+}
+
+// Unset removes every definition of key and reports whether it was defined
+func (f *EnvFile) Unset(key string) bool {
+	lines := f.lines[:0]
+	removed := false
+	for _, l := range f.lines {
+		if l.key == key {
+			removed = true
+			continue
+		}
+		lines = append(lines, l)
+	}
+	f.lines = lines
+	return removed
+}
+
+// String serializes the file. Untouched lines are written exactly as parsed.
+func (f *EnvFile) String() string {
+	if len(f.lines) == 0 {
+		return ""
+	}
+
+	raws := make([]string, len(f.lines))
+	for i, l := range f.lines {
+		raws[i] = l.raw
+	}
+	return strings.Join(raws, "\n") + "\n"
+}
+
+// Format renders the file's variables in the given format, resolving quotes,
+// escapes and references. Comments and blank lines are kept. Values spanning
+// several lines cannot be written for docker run --env-file and are rejected.
+func (f *EnvFile) Format(format EnvFormat) (string, error) {
+	out := &EnvFile{docker: format == EnvFormatDocker}
+	vars := make(map[string]string)
+
+	for _, l := range f.lines {
+		if l.key == "" {
+			out.lines = append(out.lines, l)
+			continue
+		}
+
+		value := l.value
+		if !f.docker {
+			value = l.resolve(vars)
+		}
+		vars[l.key] = value
+
+		if out.docker {
+			if err := CheckDockerEnvValue(l.key, value); err != nil {
+				return "", err
+			}
+		}
+		out.lines = append(out.lines, out.newLine(l.key, value, l.export && !out.docker))
+	}
+
+	return out.String(), nil
+}
+
+// CheckDockerEnvValue rejects values that docker run --env-file cannot pass
+// to the container. It reads one variable per line, so a value cannot
+// contain a line break.
+func CheckDockerEnvValue(key, value string) error {
+	if strings.ContainsAny(value, "\n\r") {
+		return fmt.Errorf("%s: multi-line values are not supported for Dockerfile apps, which read their env file with docker run --env-file", key)
+	}
+	return nil
+}
+
+// newLine renders an assignment in the file's format
+func (f *EnvFile) newLine(key, value string, export bool) envLine {
+	if f.docker {
+		return envLine{raw: key + "=" + value, key: key, value: value}
+	}
+	return newEnvLine(key, value, export)
+}
+
+func newEnvLine(key, value string, export bool) envLine {
+	formatted := FormatEnvValue(value)
+
+	l := envLine{key: key, export: export, value: value}
+	switch {
+	case strings.HasPrefix(formatted, "'"):
+		l.quote = '\''
+		l.value = formatted[1 : len(formatted)-1]
+	case strings.HasPrefix(formatted, `"`):
+		l.quote = '"'
+		l.value = formatted[1 : len(formatted)-1]
+	}
+
+	l.raw = key + "=" + formatted
+	if export {
+		l.raw = "export " + l.raw
+	}
+	return l
+}
+
+// FormatEnvValue renders a value for a dotenv file. Values are only quoted
+// when they would not survive unquoted, so files stay readable. Files read
+// with `docker run --env-file` never quote values; see ParseDockerEnv.
+func FormatEnvValue(value string) string {
+	needsQuotes := value != strings.TrimSpace(value) ||
+		strings.ContainsAny(value, "\n\r$\\") ||
+		strings.HasPrefix(value, "'") || strings.HasPrefix(value, `"`) ||
+		strings.HasPrefix(value, "#") || strings.Contains(value, " #") || strings.Contains(value, "\t#")
+
+	if !needsQuotes {
+		return value
+	}
+
+	// Single quotes are literal, so they need no escaping at all
+	if !strings.ContainsAny(value, "'\r") {
+		return "'" + value + "'"
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\r", `\r`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// resolve returns the value with escapes and references resolved against vars
+func (l envLine) resolve(vars map[string]string) string {
+	if l.quote == '\'' {
+		return l.value
+	}
+
+	var b strings.Builder
+	s := l.value
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c == '\\' && i+1 < len(s) {
+			next := s[i+1]
+			if l.quote == '"' {
+				switch next {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\', '$':
+					b.WriteByte(next)
+				default:
+					b.WriteByte(c)
+					b.WriteByte(next)
+				}
+				i++
+				continue
+			}
+			if next == '$' {
+				b.WriteByte('$')
+				i++
+				continue
+			}
+		}
+
+		if c == '$' {
+			if name, def, n := parseReference(s[i:]); n > 0 {
+				if v, ok := vars[name]; ok && v != "" {
+					b.WriteString(v)
+				} else {
+					b.WriteString(def)
+				}
+				i += n - 1
+				continue
+			}
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// parseReference parses "${NAME}", "${NAME:-default}" or "$NAME" at the start
+// of s and returns the name, default and length consumed (0 if none).
+func parseReference(s string) (name, def string, n int) {
+	if strings.HasPrefix(s, "${") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return "", "", 0
+		}
+		inner := s[2:end]
+		if i := strings.Index(inner, ":-"); i >= 0 {
+			name, def = inner[:i], inner[i+2:]
+		} else {
+			name = inner
+		}
+		if !isValidEnvKey(name) {
+			return "", "", 0
+		}
+		return name, def, end + 1
+	}
+
+	i := 1
+	for i < len(s) && (s[i] == '_' || (s[i] >= 'a' && s[i] <= 'z') || (s[i] >= 'A' && s[i] <= 'Z') || (i > 1 && s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	if i == 1 {
+		return "", "", 0
+	}
+	return s[1:i], "", i
+}
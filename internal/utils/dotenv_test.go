@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv_Values(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string]string
+	}{
+		{
+			name:     "plain",
+			content:  "A=1\nB=two words\n",
+			expected: map[string]string{"A": "1", "B": "two words"},
+		},
+		{
+			name:     "export prefix",
+			content:  "export A=1\nexport  B=2\n",
+			expected: map[string]string{"A": "1", "B": "2"},
+		},
+		{
+			name:     "inline comment",
+			content:  "A=value # comment\nB=pa#ss\n",
+			expected: map[string]string{"A": "value", "B": "pa#ss"},
+		},
+		{
+			name:     "single quoted is literal",
+			content:  `A='a $B \n # c'` + "\n",
+			expected: map[string]string{"A": `a $B \n # c`},
+		},
+		{
+			name:     "double quoted escapes",
+			content:  `A="line1\nline2 \"quoted\" \$HOME \\"` + "\n",
+			expected: map[string]string{"A": "line1\nline2 \"quoted\" $HOME \\"},
+		},
+		{
+			name:     "quoted with comment after",
+			content:  `A="x # not a comment" # comment` + "\n",
+			expected: map[string]string{"A": "x # not a comment"},
+		},
+		{
+			name:    "multiline PEM",
+			content: "KEY=\"-----BEGIN KEY-----\nabc\n-----END KEY-----\"\nNEXT=1\n",
+			expected: map[string]string{
+				"KEY":  "-----BEGIN KEY-----\nabc\n-----END KEY-----",
+				"NEXT": "1",
+			},
+		},
+		{
+			name:     "interpolation",
+			content:  "HOST=db\nPORT=5432\nURL=postgres://${HOST}:$PORT/app\nQ=\"${HOST}\"\nS='${HOST}'\n",
+			expected: map[string]string{"HOST": "db", "PORT": "5432", "URL": "postgres://db:5432/app", "Q": "db", "S": "${HOST}"},
+		},
+		{
+			name:     "interpolation default and unknown",
+			content:  "A=${MISSING:-fallback}\nB=x${MISSING}y\n",
+			expected: map[string]string{"A": "fallback", "B": "xy"},
+		},
+		{
+			name:     "later definition wins",
+			content:  "A=1\nA=2\n",
+			expected: map[string]string{"A": "2"},
+		},
+		{
+			name:     "ignores junk and CRLF",
+			content:  "# comment\r\nNOT AN ASSIGNMENT\r\n1BAD=x\r\nA=1\r\nEMPTY=\r\n",
+			expected: map[string]string{"A": "1", "EMPTY": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseDotenv(tt.content)
+			if err != nil {
+				t.Fatalf("ParseDotenv() error = %v", err)
+			}
+			if got := f.Vars(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Vars() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseDotenv_Unterminated(t *testing.T) {
+	if _, err := ParseDotenv("A=1\nB=\"never closed\nC=3\n"); err == nil {
+		t.Error("ParseDotenv() should fail on an unterminated quote")
+	}
+}
+
+func TestEnvFile_RoundTrip(t *testing.T) {
+	content := "# Database\nexport DB_HOST=db   # primary\nDB_PASS='p@ss word'\n\nKEY=\"-----BEGIN-----\nabc\n-----END-----\"\nJUNK LINE\n"
+
+	f, err := ParseDotenv(content)
+	if err != nil {
+		t.Fatalf("ParseDotenv() error = %v", err)
+	}
+
+	if got := f.String(); got != content {
+		t.Errorf("String() = %q, want unchanged %q", got, content)
+	}
+
+	wantKeys := []string{"DB_HOST", "DB_PASS", "KEY"}
+	if got := f.Keys(); !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("Keys() = %v, want %v", got, wantKeys)
+	}
+}
+
+func TestEnvFile_SetUnset(t *testing.T) {
+	f, err := ParseDotenv("# comment\nexport A=1\nB=2\n")
+	if err != nil {
+		t.Fatalf("ParseDotenv() error = %v", err)
+	}
+
+	f.Set("A", "changed")
+	f.Set("C", "new value")
+	if !f.Unset("B") {
+		t.Error("Unset(B) should report the key was defined")
+	}
+	if f.Unset("MISSING") {
+		t.Error("Unset(MISSING) should report the key was not defined")
+	}
+
+	want := "# comment\nexport A=changed\nC=new value\n"
+	if got := f.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatEnvValue_RoundTrip(t *testing.T) {
+	values := []string{
+		"plain",
+		"two words",
+		"",
+		" padded ",
+		"has $dollar",
+		"it's",
+		"it's $both \"quotes\" \\ too",
+		"line1\nline2",
+		"#starts with hash",
+		"x # comment-like",
+		"C:\\path",
+		"'quoted'",
+	}
+
+	for _, value := range values {
+		f := &EnvFile{}
+		f.Set("K", value)
+
+		parsed, err := ParseDotenv(f.String())
+		if err != nil {
+			t.Fatalf("ParseDotenv(%q) error = %v", f.String(), err)
+		}
+		if got, _ := parsed.Get("K"); got != value {
+			t.Errorf("value %q round-tripped as %q (serialized %q)", value, got, f.String())
+		}
+	}
+
+	// Simple values stay unquoted for `docker run --env-file`
+	if got := FormatEnvValue("two words"); got != "two words" {
+		t.Errorf("FormatEnvValue(two words) = %q, want unquoted", got)
+	}
+}
+
+func TestParseDockerEnv(t *testing.T) {
+	content := "# comment\n  A='quoted'\nB=x # not a comment\nC=$HOME\\n\nD= padded \nJUNK LINE\nexport E=1\n"
+	f := ParseDockerEnv(content)
+
+	want := map[string]string{
+		"A": "'quoted'",
+		"B": "x # not a comment",
+		"C": "$HOME\\n",
+		"D": " padded ",
+	}
+	if got := f.Vars(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Vars() = %v, want %v", got, want)
+	}
+	if got := f.String(); got != content {
+		t.Errorf("String() = %q, want unchanged %q", got, content)
+	}
+
+	f.Set("A", "it's $raw")
+	f.Set("F", " x # y")
+	want = map[string]string{"A": "it's $raw", "F": " x # y"}
+	for k, v := range want {
+		if got, _ := ParseDockerEnv(f.String()).Get(k); got != v {
+			t.Errorf("%s round-tripped as %q, want %q", k, got, v)
+		}
+	}
+	if !strings.Contains(f.String(), "A=it's $raw\n") {
+		t.Errorf("Set() should write the value unquoted, got %q", f.String())
+	}
+}
+
+func TestEnvFile_Format(t *testing.T) {
+	f, err := ParseDotenv("# db\nexport HOST=db\nPASS='p$ss # 1'\nURL=\"postgres://${HOST}/app\"\n")
+	if err != nil {
+		t.Fatalf("ParseDotenv() error = %v", err)
+	}
+
+	got, err := f.Format(EnvFormatDocker)
+	if err != nil {
+		t.Fatalf("Format(docker) error = %v", err)
+	}
+	want := "# db\nHOST=db\nPASS=p$ss # 1\nURL=postgres://db/app\n"
+	if got != want {
+		t.Errorf("Format(docker) = %q, want %q", got, want)
+	}
+
+	// Converting back keeps the values
+	back, err := ParseDockerEnv(got).Format(EnvFormatDotenv)
+	if err != nil {
+		t.Fatalf("Format(dotenv) error = %v", err)
+	}
+	parsed, err := ParseDotenv(back)
+	if err != nil {
+		t.Fatalf("ParseDotenv(%q) error = %v", back, err)
+	}
+	if !reflect.DeepEqual(parsed.Vars(), f.Vars()) {
+		t.Errorf("dotenv round trip = %v, want %v", parsed.Vars(), f.Vars())
+	}
+
+	multiline, _ := ParseDotenv("KEY=\"a\nb\"\n")
+	if _, err := multiline.Format(EnvFormatDocker); err == nil {
+		t.Error("Format(docker) should reject multi-line values")
+	}
+}
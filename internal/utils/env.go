@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -26,27 +27,39 @@ func ParseEnvFile(path string) (map[string]string, error) {
 		return nil, err
 	}
 
-	vars := make(map[string]string)
-	lines := strings.Split(string(content), "\n")
+	vars, err := ParseEnvContent(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// ParseEnvContent parses .env formatted content into a map of key-value pairs
+func ParseEnvContent(content string) (map[string]string, error) {
+	f, err := ParseDotenv(content)
+	if err != nil {
+		return nil, err
+	}
+	return f.Vars(), nil
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+// EnvFormat is the syntax an env file on the server is read with
+type EnvFormat int
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+const (
+	// EnvFormatDotenv is read by docker compose, which unquotes values and
+	// resolves references
+	EnvFormatDotenv EnvFormat = iota
+	// EnvFormatDocker is read by docker run --env-file, which takes values literally
+	EnvFormatDocker
+)
 
-		// Parse KEY=VALUE
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			vars[key] = value
-		}
+// ParseEnv parses env file content in the given format
+func ParseEnv(content string, format EnvFormat) (*EnvFile, error) {
+	if format == EnvFormatDocker {
+		return ParseDockerEnv(content), nil
 	}
-
-	return vars, nil
+	return ParseDotenv(content)
 }
 
 // CountEnvVars returns the number of variables in an env file
@@ -58,16 +71,48 @@ func CountEnvVars(path string) (int, error) {
 	return len(vars), nil
 }
 
-// GetEnvVarKeys returns the keys (variable names) from an env file
+// GetEnvVarKeys returns the keys (variable names) from an env file in file order
 func GetEnvVarKeys(path string) ([]string, error) {
-	vars, err := ParseEnvFile(path)
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	keys := make([]string, 0, len(vars))
-	for key := range vars {
-		keys = append(keys, key)
+	f, err := ParseDotenv(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Keys(), nil
+}
+
+// DiffEnvVars returns the sorted keys added, removed and changed between two sets of variables
+func DiffEnvVars(before, after map[string]string) (added, removed, changed []string) {
+	for key, value := range after {
+		if old, ok := before[key]; !ok {
+			added = append(added, key)
+		} else if old != value {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+// MaskEnvValue hides a variable's value, keeping a short prefix of long values as a hint
+func MaskEnvValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) <= 8 {
+		return "********"
 	}
-	return keys, nil
+	return value[:3] + "********"
 }
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvContent(t *testing.T) {
+	content := "# comment\nA=1\n\nB = two \nINVALID\nC=x=y\n"
+
+	got, err := ParseEnvContent(content)
+	if err != nil {
+		t.Fatalf("ParseEnvContent() error = %v", err)
+	}
+	want := map[string]string{"A": "1", "B": "two", "C": "x=y"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseEnvContent() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffEnvVars(t *testing.T) {
+	before := map[string]string{"A": "1", "B": "2", "C": "3"}
+	after := map[string]string{"A": "1", "B": "changed", "D": "4", "E": "5"}
+
+	added, removed, changed := DiffEnvVars(before, after)
+
+	if !reflect.DeepEqual(added, []string{"D", "E"}) {
+		t.Errorf("added = %v, want [D E]", added)
+	}
+	if !reflect.DeepEqual(removed, []string{"C"}) {
+		t.Errorf("removed = %v, want [C]", removed)
+	}
+	if !reflect.DeepEqual(changed, []string{"B"}) {
+		t.Errorf("changed = %v, want [B]", changed)
+	}
+}
+
+func TestMaskEnvValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", ""},
+		{"short", "********"},
+		{"postgres://user:pass@db/app", "pos********"},
+	}
+
+	for _, tt := range tests {
+		if got := MaskEnvValue(tt.value); got != tt.expected {
+			t.Errorf("MaskEnvValue(%q) = %q, want %q", tt.value, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+)
+
+// OutputWatcher passes output through to a writer and records whether it
+// contained a given text, e.g. a notice printed by the deployment hook
+type OutputWatcher struct {
+	w     io.Writer
+	text  []byte
+	tail  []byte
+	found bool
+}
+
+// NewOutputWatcher returns an OutputWatcher writing to w that looks for text
+func NewOutputWatcher(w io.Writer, text string) *OutputWatcher {
+	return &OutputWatcher{w: w, text: []byte(text)}
+}
+
+// Write writes p to the underlying writer. The text is found even if it is
+// split across writes
+func (o *OutputWatcher) Write(p []byte) (int, error) {
+	if !o.found && len(o.text) > 0 {
+		buf := append(o.tail, p...)
+		if bytes.Contains(buf, o.text) {
+			o.found = true
+			o.tail = nil
+		} else {
+			if keep := len(o.text) - 1; len(buf) > keep {
+				buf = buf[len(buf)-keep:]
+			}
+			o.tail = append([]byte(nil), buf...)
+		}
+	}
+	return o.w.Write(p)
+}
+
+// Found reports whether the text was written
+func (o *OutputWatcher) Found() bool {
+	return o.found
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutputWatcher(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   bool
+	}{
+		{"single write", []string{"remote: No new release deployed\n"}, true},
+		{"split across writes", []string{"remote: No new rel", "ease dep", "loyed\n"}, true},
+		{"not written", []string{"remote: → Deploying abc1234\n", "remote: ✓ Deployment complete\n"}, false},
+		{"no output", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			watcher := NewOutputWatcher(&out, "No new release deployed")
+			var want string
+			for _, w := range tt.writes {
+				if _, err := watcher.Write([]byte(w)); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				want += w
+			}
+			if watcher.Found() != tt.want {
+				t.Errorf("Found() = %v, want %v", watcher.Found(), tt.want)
+			}
+			if out.String() != want {
+				t.Errorf("output = %q, want %q", out.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// SecretsFile is the encrypted env file committed to the repository
+	SecretsFile = "secrets.env.enc"
+	// SecretsKeyFile holds the team key locally. It must never be committed.
+	SecretsKeyFile = ".mushak/secrets.key"
+	// SecretsKeyEnv overrides SecretsKeyFile, e.g. in CI
+	SecretsKeyEnv = "MUSHAK_SECRETS_KEY"
+
+	// The format is that of `openssl enc -aes-256-cbc -pbkdf2 -iter 100000 -md sha256 -a`,
+	// so the server can decrypt with openssl alone, followed by an hmac= line
+	// authenticating the ciphertext. Keep in sync with the deploy scripts.
+	secretsIterations = 100000
+	secretsSaltMagic  = "Salted__"
+	secretsMACPrefix  = "hmac="
+	secretsMACLabel   = "mushak-secrets-mac:"
+)
+
+// GenerateSecretsKey returns a new random team key
+func GenerateSecretsKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// LoadSecretsKey returns the team key from MUSHAK_SECRETS_KEY or .mushak/secrets.key
+func LoadSecretsKey() (string, error) {
+	if key := strings.TrimSpace(os.Getenv(SecretsKeyEnv)); key != "" {
+		return key, nil
+	}
+
+	data, err := os.ReadFile(SecretsKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("no secrets key found. Set %s or run 'mushak secrets init'", SecretsKeyEnv)
+	}
+
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("%s is empty", SecretsKeyFile)
+	}
+	return key, nil
+}
+
+// EncryptSecrets encrypts plaintext with the team key
+func EncryptSecrets(plaintext []byte, key string) ([]byte, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	block, iv, err := secretsCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS#7 padding
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	raw := append(append([]byte(secretsSaltMagic), salt...), ciphertext...)
+	encoded := base64.StdEncoding.EncodeToString(raw)
+
+	// Wrap at 64 columns like openssl does
+	var out bytes.Buffer
+	for len(encoded) > 64 {
+		out.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	out.WriteString(encoded + "\n")
+
+	// Encrypt-then-MAC, so a modified file is rejected before it is decrypted
+	out.WriteString(secretsMACPrefix + secretsMAC(key, out.String()) + "\n")
+
+	return out.Bytes(), nil
+}
+
+// DecryptSecrets verifies and decrypts data produced by EncryptSecrets
+func DecryptSecrets(data []byte, key string) ([]byte, error) {
+	var body strings.Builder
+	var mac string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, secretsMACPrefix) {
+			mac = strings.TrimSpace(strings.TrimPrefix(line, secretsMACPrefix))
+			continue
+		}
+		body.WriteString(line + "\n")
+	}
+
+	if mac == "" {
+		return nil, fmt.Errorf("invalid secrets file: missing %s line", strings.TrimSuffix(secretsMACPrefix, "="))
+	}
+	if !hmac.Equal([]byte(mac), []byte(secretsMAC(key, body.String()))) {
+		return nil, fmt.Errorf("failed to decrypt secrets: wrong key or modified file")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body.String()), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid secrets file: %w", err)
+	}
+
+	if len(raw) < 16 || string(raw[:8]) != secretsSaltMagic {
+		return nil, fmt.Errorf("invalid secrets file: missing header")
+	}
+
+	ciphertext := raw[16:]
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid secrets file: truncated")
+	}
+
+	block, iv, err := secretsCipher(key, raw[8:16])
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, fmt.Errorf("failed to decrypt secrets: wrong key or corrupted file")
+	}
+
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// ReadSecretsFile decrypts the secrets file at path with the team key
+func ReadSecretsFile(path, key string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := DecryptSecrets(data, key)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// secretsMAC returns the hex HMAC-SHA256 of the encoded ciphertext. Whitespace
+// is ignored, so line ending conversions don't invalidate the file. The MAC key
+// is derived from the team key with a label, separate from the encryption key.
+func secretsMAC(key, encoded string) string {
+	macKey := sha256.Sum256([]byte(secretsMACLabel + key))
+	mac := hmac.New(sha256.New, macKey[:])
+	mac.Write([]byte(strings.Join(strings.Fields(encoded), "")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// secretsCipher derives the AES key and IV from the team key and salt
+func secretsCipher(key string, salt []byte) (cipher.Block, []byte, error) {
+	derived, err := pbkdf2.Key(sha256.New, key, salt, secretsIterations, 32+aes.BlockSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return block, derived[32:], nil
+}
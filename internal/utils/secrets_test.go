@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecryptSecrets(t *testing.T) {
+	key, err := GenerateSecretsKey()
+	if err != nil {
+		t.Fatalf("GenerateSecretsKey() error = %v", err)
+	}
+
+	tests := []string{
+		"",
+		"DB_PASSWORD=secret\n",
+		"API_KEY=0123456789abcdef\nSTRIPE_SECRET=sk_live_" + strings.Repeat("x", 100) + "\n",
+	}
+
+	for _, plaintext := range tests {
+		encrypted, err := EncryptSecrets([]byte(plaintext), key)
+		if err != nil {
+			t.Fatalf("EncryptSecrets() error = %v", err)
+		}
+
+		if plaintext != "" && bytes.Contains(encrypted, []byte(plaintext)) {
+			t.Error("EncryptSecrets() output contains the plaintext")
+		}
+
+		decrypted, err := DecryptSecrets(encrypted, key)
+		if err != nil {
+			t.Fatalf("DecryptSecrets() error = %v", err)
+		}
+
+		if string(decrypted) != plaintext {
+			t.Errorf("DecryptSecrets() = %q, want %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestDecryptSecrets_WrongKey(t *testing.T) {
+	encrypted, err := EncryptSecrets([]byte("DB_PASSWORD=secret\n"), "right-key")
+	if err != nil {
+		t.Fatalf("EncryptSecrets() error = %v", err)
+	}
+
+	if _, err := DecryptSecrets(encrypted, "wrong-key"); err == nil {
+		t.Error("DecryptSecrets() with the wrong key should fail")
+	}
+
+	if _, err := DecryptSecrets([]byte("not encrypted"), "right-key"); err == nil {
+		t.Error("DecryptSecrets() of garbage should fail")
+	}
+}
+
+func TestDecryptSecrets_Authenticated(t *testing.T) {
+	encrypted, err := EncryptSecrets([]byte("DB_PASSWORD=secret\n"), "right-key")
+	if err != nil {
+		t.Fatalf("EncryptSecrets() error = %v", err)
+	}
+
+	// Flip a bit of the ciphertext
+	tampered := []byte(string(encrypted))
+	if tampered[20] == 'A' {
+		tampered[20] = 'B'
+	} else {
+		tampered[20] = 'A'
+	}
+	if _, err := DecryptSecrets(tampered, "right-key"); err == nil {
+		t.Error("DecryptSecrets() of a modified file should fail")
+	}
+
+	// Stripping the MAC must not downgrade to unauthenticated decryption
+	var stripped []string
+	for _, line := range strings.Split(string(encrypted), "\n") {
+		if !strings.HasPrefix(line, "hmac=") {
+			stripped = append(stripped, line)
+		}
+	}
+	if _, err := DecryptSecrets([]byte(strings.Join(stripped, "\n")), "right-key"); err == nil {
+		t.Error("DecryptSecrets() without a MAC should fail")
+	}
+
+	// Line endings converted by Git don't invalidate the file
+	crlf := strings.ReplaceAll(string(encrypted), "\n", "\r\n")
+	if _, err := DecryptSecrets([]byte(crlf), "right-key"); err != nil {
+		t.Errorf("DecryptSecrets() with CRLF line endings error = %v", err)
+	}
+}
+
+// The server decrypts with openssl, so the formats must stay compatible
+func TestDecryptSecrets_OpenSSLCompatible(t *testing.T) {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl not installed")
+	}
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "secrets.key")
+	key := "test-team-key"
+	plaintext := "DB_PASSWORD=secret\nAPI_KEY=" + strings.Repeat("k", 80) + "\n"
+
+	if err := os.WriteFile(keyPath, []byte(key+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := EncryptSecrets([]byte(plaintext), key)
+	if err != nil {
+		t.Fatalf("EncryptSecrets() error = %v", err)
+	}
+
+	// The deploy scripts check the MAC and strip it before decrypting
+	var body, mac string
+	for _, line := range strings.SplitAfter(string(encrypted), "\n") {
+		if strings.HasPrefix(line, "hmac=") {
+			mac = strings.TrimSpace(strings.TrimPrefix(line, "hmac="))
+		} else {
+			body += line
+		}
+	}
+	macKey, err := exec.Command("sh", "-c", "printf 'mushak-secrets-mac:%s' \"$(cat "+keyPath+")\" | openssl dgst -sha256 -r | cut -c1-64").Output()
+	if err != nil {
+		t.Fatalf("failed to derive MAC key: %v", err)
+	}
+	cmd := exec.Command("sh", "-c", "tr -d '[:space:]' | openssl dgst -sha256 -mac HMAC -macopt hexkey:"+strings.TrimSpace(string(macKey))+" -r | cut -c1-64")
+	cmd.Stdin = strings.NewReader(body)
+	opensslMAC, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("openssl failed to compute MAC: %v", err)
+	}
+	if strings.TrimSpace(string(opensslMAC)) != mac {
+		t.Errorf("openssl MAC = %q, want %q", strings.TrimSpace(string(opensslMAC)), mac)
+	}
+
+	cmd = exec.Command("openssl", "enc", "-d", "-aes-256-cbc", "-pbkdf2", "-iter", "100000", "-md", "sha256", "-a",
+		"-pass", "file:"+keyPath)
+	cmd.Stdin = strings.NewReader(body)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("openssl failed to decrypt: %v", err)
+	}
+	if string(out) != plaintext {
+		t.Errorf("openssl decrypted %q, want %q", out, plaintext)
+	}
+
+	// And the other way round
+	cmd = exec.Command("openssl", "enc", "-aes-256-cbc", "-pbkdf2", "-iter", "100000", "-md", "sha256", "-a",
+		"-pass", "file:"+keyPath)
+	cmd.Stdin = strings.NewReader(plaintext)
+	opensslEncrypted, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("openssl failed to encrypt: %v", err)
+	}
+
+	opensslEncrypted = append(opensslEncrypted, []byte("hmac="+secretsMAC(key, string(opensslEncrypted))+"\n")...)
+	decrypted, err := DecryptSecrets(opensslEncrypted, key)
+	if err != nil {
+		t.Fatalf("DecryptSecrets() error = %v", err)
+	}
+	if string(decrypted) != plaintext {
+		t.Errorf("DecryptSecrets() = %q, want %q", decrypted, plaintext)
+	}
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// DeployUser returns who is deploying, for release metadata: the git user
+// name, or the OS user if git has none configured
+func DeployUser() string {
+	if out, err := exec.Command("git", "config", "user.name").Output(); err == nil {
+		if name := strings.TrimSpace(string(out)); name != "" {
+			return name
+		}
+	}
+
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "unknown"
+}
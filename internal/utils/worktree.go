@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// WorkingTreeCommit commits the working tree of the repository in dir,
+// including uncommitted and untracked files but not ignored ones, without
+// touching the index, HEAD or any branch. It returns the new commit's SHA.
+func WorkingTreeCommit(dir, message string) (string, error) {
+	index, err := os.CreateTemp("", "mushak-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	index.Close()
+	// git refuses to read an empty index file, so let it create its own
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+
+	env := append(os.Environ(), "GIT_INDEX_FILE="+index.Name())
+	if _, err := runGit(dir, env, "add", "-A"); err != nil {
+		return "", fmt.Errorf("failed to stage working tree: %w", err)
+	}
+	tree, err := runGit(dir, env, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("failed to write tree: %w", err)
+	}
+
+	args := []string{"commit-tree", tree, "-m", message}
+	if head, err := runGit(dir, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil && head != "" {
+		args = append(args, "-p", head)
+	}
+	commit, err := runGit(dir, nil, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit working tree: %w", err)
+	}
+	return commit, nil
+}
+
+// WriteWorkingTreeArchive writes a gzipped tarball of the working tree of the
+// repository in dir to w: tracked and untracked files as they are on disk,
+// without ignored files
+func WriteWorkingTreeArchive(dir string, w io.Writer) error {
+	out, err := exec.Command("git", "-C", dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(string(out), "\x00") {
+		// Files with unmerged changes are listed once per stage
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if err := addArchiveFile(tw, dir, name); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// addArchiveFile adds one file of the working tree to the archive
+func addArchiveFile(tw *tar.Writer, dir, name string) error {
+	path := filepath.Join(dir, name)
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		// Deleted but not yet committed
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	// Submodules are listed as directories
+	if info.IsDir() {
+		return nil
+	}
+
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	header.Name = filepath.ToSlash(name)
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	return nil
+}
+
+// ExportCommit writes the files of commit in the repository in dir to dest,
+// like a checkout that doesn't touch the repository
+func ExportCommit(dir, commit, dest string) error {
+	cmd := exec.Command("git", "-C", dir, "archive", "--format=tar", commit)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to export %s: %w", commit, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to export %s: %w", commit, err)
+	}
+
+	extractErr := extractTar(out, dest)
+	// Drain what is left so git can exit
+	io.Copy(io.Discard, out)
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to export %s: %w: %s", commit, err, strings.TrimSpace(stderr.String()))
+	}
+	return extractErr
+}
+
+// extractTar extracts the directories, files and symlinks of a tar stream into dest
+func extractTar(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		path := filepath.Join(dest, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry %s is outside the destination", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0755)
+		case tar.TypeSymlink:
+			if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+				err = os.Symlink(header.Linkname, path)
+			}
+		case tar.TypeReg:
+			err = writeArchiveFile(tr, path, os.FileMode(header.Mode).Perm())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", header.Name, err)
+		}
+	}
+}
+
+func writeArchiveFile(r io.Reader, path string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runGit runs a git command in dir and returns its trimmed output
+func runGit(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
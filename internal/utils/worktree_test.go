@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// newTestRepo creates a repository with one commit, an uncommitted change,
+// an untracked file and an ignored file
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write(".gitignore", "secret.txt\n")
+	write("app.txt", "v1\n")
+	write("old.txt", "old\n")
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	write("app.txt", "v2\n")
+	write("new.txt", "new\n")
+	write("secret.txt", "secret\n")
+	if err := os.Remove(filepath.Join(dir, "old.txt")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestWorkingTreeCommit(t *testing.T) {
+	dir := newTestRepo(t)
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	head, _ := runGit(dir, nil, "rev-parse", "HEAD")
+
+	commit, err := WorkingTreeCommit(dir, "Uncommitted changes")
+	if err != nil {
+		t.Fatalf("WorkingTreeCommit() error = %v", err)
+	}
+
+	files, _ := runGit(dir, nil, "ls-tree", "--name-only", commit)
+	if files != ".gitignore\napp.txt\nnew.txt" {
+		t.Errorf("commit files = %q, want .gitignore, app.txt and new.txt", files)
+	}
+	if content, _ := runGit(dir, nil, "show", commit+":app.txt"); content != "v2" {
+		t.Errorf("app.txt = %q, want the uncommitted content", content)
+	}
+	if parent, _ := runGit(dir, nil, "rev-parse", commit+"^"); parent != head {
+		t.Errorf("parent = %q, want HEAD %q", parent, head)
+	}
+
+	// The repository itself is left alone
+	if after, _ := runGit(dir, nil, "rev-parse", "HEAD"); after != head {
+		t.Error("HEAD should not move")
+	}
+	if status, _ := runGit(dir, nil, "status", "--porcelain"); status == "" {
+		t.Error("changes should still be uncommitted")
+	}
+}
+
+func TestWriteWorkingTreeArchive(t *testing.T) {
+	dir := newTestRepo(t)
+
+	var buf bytes.Buffer
+	if err := WriteWorkingTreeArchive(dir, &buf); err != nil {
+		t.Fatalf("WriteWorkingTreeArchive() error = %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(tr)
+		files[header.Name] = string(content)
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	want := []string{".gitignore", "app.txt", "new.txt"}
+	if len(names) != len(want) {
+		t.Fatalf("archive files = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("archive files = %v, want %v", names, want)
+			break
+		}
+	}
+	if files["app.txt"] != "v2\n" {
+		t.Errorf("app.txt = %q, want the uncommitted content", files["app.txt"])
+	}
+}
+
+func TestExportCommit(t *testing.T) {
+	dir := newTestRepo(t)
+	dest := t.TempDir()
+
+	if err := ExportCommit(dir, "HEAD", dest); err != nil {
+		t.Fatalf("ExportCommit() error = %v", err)
+	}
+
+	// The committed files, not the working tree
+	content, err := os.ReadFile(filepath.Join(dest, "app.txt"))
+	if err != nil || string(content) != "v1\n" {
+		t.Errorf("app.txt = %q (%v), want the committed content", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "old.txt")); err != nil {
+		t.Errorf("old.txt should be exported: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "new.txt")); !os.IsNotExist(err) {
+		t.Error("untracked new.txt should not be exported")
+	}
+}